@@ -462,6 +462,24 @@ func main() {
 			log.Fatal(err)
 		}
 
+		if fileHistorian, ok := repo.(vcs.FileHistorian); ok {
+			entries, err := fileHistorian.FileHistory(path, commitID, &vcs.FileHistoryOptions{N: 10})
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			fmt.Printf("# History (%d shown):\n", len(entries))
+			for _, e := range entries {
+				if e.OrigPath != "" {
+					fmt.Printf("%s %s -> %s\n", e.Status, e.OrigPath, e.Path)
+				} else {
+					fmt.Printf("%s %s\n", e.Status, e.Path)
+				}
+				printCommit(e.Commit)
+			}
+			break
+		}
+
 		commits, total, err := repo.Commits(vcs.CommitsOptions{Head: commitID, N: 10, Path: path})
 		if err != nil {
 			log.Fatal(err)
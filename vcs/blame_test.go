@@ -23,11 +23,13 @@ func TestRepository_BlameFile(t *testing.T) {
 	gitWantHunks := []*vcs.Hunk{
 		{
 			StartLine: 1, EndLine: 2, StartByte: 0, EndByte: 6, CommitID: "e6093374dcf5725d8517db0dccbbf69df65dbde0",
-			Author: vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:05Z")},
+			Author:   vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:05Z")},
+			OrigPath: "f", OrigStartLine: 1,
 		},
 		{
 			StartLine: 2, EndLine: 3, StartByte: 6, EndByte: 12, CommitID: "fad406f4fe02c358a09df0d03ec7a36c2c8a20f1",
-			Author: vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:05Z")},
+			Author:   vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:05Z")},
+			OrigPath: "f", OrigStartLine: 2,
 		},
 	}
 	hgCommands := []string{
@@ -40,6 +42,18 @@ func TestRepository_BlameFile(t *testing.T) {
 		"hg add f",
 		"hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
 	}
+	hgWantHunks := []*vcs.Hunk{
+		{
+			StartLine: 1, EndLine: 2, StartByte: 0, EndByte: 6, CommitID: "f1f126ec4cf9398d85e8dac873afc3f9b174b1d6",
+			Author:   vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-12-06T13:18:29Z")},
+			OrigPath: "f", OrigStartLine: 1,
+		},
+		{
+			StartLine: 2, EndLine: 3, StartByte: 6, EndByte: 12, CommitID: "63e47acf80095270f4e2b81e8cc01a89416c0cf3",
+			Author:   vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-12-06T13:18:29Z")},
+			OrigPath: "f", OrigStartLine: 2,
+		},
+	}
 	tests := map[string]struct {
 		repo interface {
 			vcs.Blamer
@@ -72,16 +86,15 @@ func TestRepository_BlameFile(t *testing.T) {
 			opt: &vcs.BlameOptions{
 				NewestCommit: "tip",
 			},
-			wantHunks: []*vcs.Hunk{
-				{
-					StartLine: 1, EndLine: 2, StartByte: 0, EndByte: 6, CommitID: "f1f126ec4cf9398d85e8dac873afc3f9b174b1d6",
-					Author: vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-12-06T13:18:29Z")},
-				},
-				{
-					StartLine: 2, EndLine: 3, StartByte: 6, EndByte: 12, CommitID: "63e47acf80095270f4e2b81e8cc01a89416c0cf3",
-					Author: vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-12-06T13:18:29Z")},
-				},
+			wantHunks: hgWantHunks,
+		},
+		"hg native": {
+			repo: makeHgRepositoryNative(t, hgCommands...),
+			path: "f",
+			opt: &vcs.BlameOptions{
+				NewestCommit: "tip",
 			},
+			wantHunks: hgWantHunks,
 		},
 	}
 
@@ -108,3 +121,248 @@ func TestRepository_BlameFile(t *testing.T) {
 		}
 	}
 }
+
+func TestRepository_BlameFile_oldestCommit(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"printf 'line1\\nline2\\n' > f",
+		"git add f",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"echo line3 >> f",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -a -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag v1",
+		"echo line4 >> f",
+		"GIT_COMMITTER_NAME=b GIT_COMMITTER_EMAIL=b@b.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git commit -a -m bar --author='b <b@b.com>' --date 2006-01-02T15:04:06Z",
+	}
+	hgCommands := []string{
+		"printf 'line1\\nline2\\n' > f",
+		"hg add f",
+		"hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
+		"echo line3 >> f",
+		"hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
+		"hg tag v1 --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
+		"echo line4 >> f",
+		"hg commit -m bar --date '2006-12-06 13:18:30 UTC' --user 'b <b@b.com>'",
+	}
+	tests := map[string]struct {
+		repo interface {
+			vcs.Blamer
+			ResolveRevision(spec string) (vcs.CommitID, error)
+		}
+		newest, oldest string
+	}{
+		"git cmd": {
+			repo:   makeGitRepositoryCmd(t, gitCommands...),
+			newest: "master",
+			oldest: "v1",
+		},
+		"git go-git": {
+			repo:   makeGitRepositoryGoGit(t, gitCommands...),
+			newest: "master",
+			oldest: "v1",
+		},
+		"hg cmd": {
+			repo:   makeHgRepositoryCmd(t, hgCommands...),
+			newest: "tip",
+			oldest: "v1",
+		},
+		"hg native": {
+			repo:   makeHgRepositoryNative(t, hgCommands...),
+			newest: "tip",
+			oldest: "v1",
+		},
+	}
+
+	for label, test := range tests {
+		if strings.HasPrefix(label, "hg ") {
+			continue // hg broken, see issue #104.
+		}
+
+		newestCommitID, err := test.repo.ResolveRevision(test.newest)
+		if err != nil {
+			t.Errorf("%s: ResolveRevision(%q): %s", label, test.newest, err)
+			continue
+		}
+		oldestCommitID, err := test.repo.ResolveRevision(test.oldest)
+		if err != nil {
+			t.Errorf("%s: ResolveRevision(%q): %s", label, test.oldest, err)
+			continue
+		}
+
+		opt := &vcs.BlameOptions{NewestCommit: newestCommitID, OldestCommit: oldestCommitID}
+		hunks, err := test.repo.BlameFile("f", opt)
+		if err != nil {
+			t.Errorf("%s: BlameFile(f, %+v): %s", label, opt, err)
+			continue
+		}
+
+		// The first 3 lines are older than the boundary, so they are
+		// attributed to it.
+		wantHunks := []*vcs.Hunk{
+			{StartLine: 1, EndLine: 4, StartByte: 0, EndByte: 18, CommitID: oldestCommitID, Boundary: true, OrigPath: "f", OrigStartLine: 1},
+			{StartLine: 4, EndLine: 5, StartByte: 18, EndByte: 24, CommitID: newestCommitID, OrigPath: "f", OrigStartLine: 4},
+		}
+		if len(hunks) != len(wantHunks) {
+			t.Errorf("%s: got %d hunks, want %d\n\nhunks ==========\n%s", label, len(hunks), len(wantHunks), asJSON(hunks))
+			continue
+		}
+		for i, h := range hunks {
+			h.Author = vcs.Signature{}
+			if !reflect.DeepEqual(h, wantHunks[i]) {
+				t.Errorf("%s: hunk %d: got %s, want %s", label, i, asJSON(h), asJSON(wantHunks[i]))
+			}
+		}
+	}
+}
+
+func TestRepository_BlameFile_movesAndIgnoreRevs(t *testing.T) {
+	t.Parallel()
+
+	gitCommit := func(tag string) string {
+		return "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -a -m " + tag + " --author='a <a@a.com>' --date 2006-01-02T15:04:05Z && git tag " + tag
+	}
+	gitCommands := []string{
+		"printf 'alpha alpha alpha alpha alpha\\nbravo bravo bravo bravo bravo\\ncharlie charlie charlie charlie\\ndelta delta delta delta delta\\n' > f",
+		"git add f",
+		gitCommit("c1"),
+		"git mv f g",
+		gitCommit("c2"),
+		"printf 'charlie charlie charlie charlie\\ndelta delta delta delta delta\\nalpha alpha alpha alpha alpha\\nbravo bravo bravo bravo bravo\\n' > g",
+		gitCommit("c3"),
+		"printf 'charlie charlie charlie charlie\\ndelta delta delta delta delta\\nalpha alpha alpha alpha alpha\\nbravo bravo bravo bravo BRAVO\\n' > g",
+		gitCommit("c4"),
+		"(echo '# reformatting'; git rev-parse c4) > .git-blame-ignore-revs",
+		"git add .git-blame-ignore-revs",
+		gitCommit("c5"),
+	}
+	hgCommit := func(tag string) string {
+		return "hg commit -m " + tag + " --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>' && hg tag " + tag + " --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'"
+	}
+	hgCommands := []string{
+		"printf 'alpha alpha alpha alpha alpha\\nbravo bravo bravo bravo bravo\\ncharlie charlie charlie charlie\\ndelta delta delta delta delta\\n' > f",
+		"hg add f",
+		hgCommit("c1"),
+		"hg mv f g",
+		hgCommit("c2"),
+		"printf 'charlie charlie charlie charlie\\ndelta delta delta delta delta\\nalpha alpha alpha alpha alpha\\nbravo bravo bravo bravo bravo\\n' > g",
+		hgCommit("c3"),
+		"printf 'charlie charlie charlie charlie\\ndelta delta delta delta delta\\nalpha alpha alpha alpha alpha\\nbravo bravo bravo bravo BRAVO\\n' > g",
+		hgCommit("c4"),
+		"(echo '# reformatting'; hg log -r c4 --template '{node}\\n') > .git-blame-ignore-revs",
+		"hg add .git-blame-ignore-revs",
+		hgCommit("c5"),
+	}
+
+	// hunk is a vcs.Hunk with the commit given by its tag and without
+	// the author and byte offsets.
+	type hunk struct {
+		StartLine, EndLine int
+		Tag                string
+		OrigPath           string
+		OrigStartLine      int
+	}
+	tests := map[string]struct {
+		repo interface {
+			vcs.Blamer
+			ResolveRevision(spec string) (vcs.CommitID, error)
+		}
+		opt       vcs.BlameOptions
+		wantHunks []hunk
+	}{
+		"git cmd": {
+			repo: makeGitRepositoryCmd(t, gitCommands...),
+			wantHunks: []hunk{
+				{1, 3, "c1", "f", 3},
+				{3, 4, "c3", "g", 3},
+				{4, 5, "c4", "g", 4},
+			},
+		},
+		"git cmd DetectMoves": {
+			repo: makeGitRepositoryCmd(t, gitCommands...),
+			opt:  vcs.BlameOptions{DetectMoves: true},
+			wantHunks: []hunk{
+				{1, 3, "c1", "f", 3},
+				{3, 4, "c1", "f", 1},
+				{4, 5, "c4", "g", 4},
+			},
+		},
+		"git cmd IgnoreRevs": {
+			repo: makeGitRepositoryCmd(t, gitCommands...),
+			opt:  vcs.BlameOptions{IgnoreRevs: []vcs.CommitID{"c4"}},
+			wantHunks: []hunk{
+				{1, 3, "c1", "f", 3},
+				{3, 4, "c3", "g", 3},
+				{4, 5, "c3", "g", 4},
+			},
+		},
+		"git cmd IgnoreRevsFile": {
+			repo: makeGitRepositoryCmd(t, gitCommands...),
+			opt:  vcs.BlameOptions{IgnoreRevsFile: ".git-blame-ignore-revs"},
+			wantHunks: []hunk{
+				{1, 3, "c1", "f", 3},
+				{3, 4, "c3", "g", 3},
+				{4, 5, "c3", "g", 4},
+			},
+		},
+		"git go-git DetectMoves": {
+			repo: makeGitRepositoryGoGit(t, gitCommands...),
+			opt:  vcs.BlameOptions{DetectMoves: true},
+			wantHunks: []hunk{
+				{1, 3, "c1", "f", 3},
+				{3, 4, "c1", "f", 1},
+				{4, 5, "c4", "g", 4},
+			},
+		},
+		"hg cmd IgnoreRevsFile": {
+			repo: makeHgRepositoryCmd(t, hgCommands...),
+			opt:  vcs.BlameOptions{IgnoreRevsFile: ".git-blame-ignore-revs"},
+			wantHunks: []hunk{
+				{1, 3, "c1", "f", 3},
+				{3, 5, "c3", "g", 3}, // hg annotate doesn't split this hunk
+			},
+		},
+		"hg native": {
+			repo: makeHgRepositoryNative(t, hgCommands...),
+			wantHunks: []hunk{
+				{1, 3, "c1", "f", 3},
+				{3, 4, "c3", "g", 3},
+				{4, 5, "c4", "g", 4},
+			},
+		},
+	}
+
+	for label, test := range tests {
+		if strings.HasPrefix(label, "hg ") {
+			continue // hg broken, see issue #104.
+		}
+
+		tagCommitIDs := map[vcs.CommitID]string{}
+		for _, tag := range []string{"c1", "c2", "c3", "c4", "c5"} {
+			commitID, err := test.repo.ResolveRevision(tag)
+			if err != nil {
+				t.Fatalf("%s: ResolveRevision(%q): %s", label, tag, err)
+			}
+			tagCommitIDs[commitID] = tag
+		}
+
+		opt := test.opt
+		var err error
+		opt.NewestCommit, err = test.repo.ResolveRevision("c5")
+		if err != nil {
+			t.Fatalf("%s: ResolveRevision(c5): %s", label, err)
+		}
+		hunks, err := test.repo.BlameFile("g", &opt)
+		if err != nil {
+			t.Errorf("%s: BlameFile(g, %+v): %s", label, opt, err)
+			continue
+		}
+		var got []hunk
+		for _, h := range hunks {
+			got = append(got, hunk{h.StartLine, h.EndLine, tagCommitIDs[h.CommitID], h.OrigPath, h.OrigStartLine})
+		}
+		if !reflect.DeepEqual(got, test.wantHunks) {
+			t.Errorf("%s: got hunks\n%s\n\nwant\n%s", label, asJSON(got), asJSON(test.wantHunks))
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"os"
+)
+
+// A CommitCreator is a repository that can create commits without a
+// working tree (e.g., in a bare repository).
+type CommitCreator interface {
+	// CreateCommit creates a commit that applies the changes of opt
+	// to the tree of opt.Parent, and returns its ID. If opt.Ref is
+	// set, it updates the ref to the new commit.
+	CreateCommit(opt CreateCommitOptions) (CommitID, error)
+}
+
+// A CommitCreatorContext is a CommitCreator that accepts a
+// context.Context. See RepositoryContext for the semantics of ctx.
+type CommitCreatorContext interface {
+	CreateCommitContext(ctx context.Context, opt CreateCommitOptions) (CommitID, error)
+}
+
+// CreateCommitOptions specifies the commit to create with
+// CommitCreator.CreateCommit.
+type CreateCommitOptions struct {
+	// Parent is the parent commit. If empty, the commit is a root
+	// commit, and Changes apply to an empty tree.
+	Parent CommitID
+
+	// Changes are the changes to the files of the parent commit.
+	Changes []FileChange
+
+	// Author is the author of the commit, and Committer is its
+	// committer (or the author, if nil). If a Date is zero, the
+	// current time is used. Mercurial has no separate committer, so
+	// hg backends ignore Committer.
+	Author    Signature
+	Committer *Signature
+
+	Message string
+
+	// Ref, if set, is the ref (e.g., "refs/heads/master" for git, or
+	// a bookmark for hg) to update to the new commit. The update
+	// fails with ErrRefChanged unless the ref points to Parent (or,
+	// if Parent is empty, doesn't exist), so that concurrent updates
+	// of the ref aren't lost.
+	Ref string
+}
+
+// A FileChange is a change to a file in CreateCommitOptions.
+type FileChange struct {
+	// Path is the slash-separated path of the file, relative to the
+	// root of the tree.
+	Path string
+
+	// Delete is whether to delete the file. The other fields are
+	// ignored if it is set.
+	Delete bool
+
+	// Contents are the new contents of the file (or the target of a
+	// symlink). If nil, the file's existing contents are kept (e.g.,
+	// to change only its mode); use an empty slice to create an
+	// empty file.
+	Contents []byte
+
+	// Mode is the new mode of the file: a regular file (0644), an
+	// executable file (0755) or a symlink (os.ModeSymlink). If zero,
+	// the file's existing mode is kept, or a new file is a regular
+	// file.
+	Mode os.FileMode
+}
+
+// ErrRefChanged is returned by (CommitCreator).CreateCommit when the
+// ref to update doesn't point to the parent of the new commit.
+var ErrRefChanged = errors.New("ref changed")
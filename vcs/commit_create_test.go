@@ -0,0 +1,171 @@
+package vcs_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/godoc/vfs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/gitcmd"
+)
+
+func TestCommitCreator_CreateCommit(t *testing.T) {
+	t.Parallel()
+
+	gitCommit := "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z"
+	cmds := []string{
+		"echo x > f",
+		"echo y > g",
+		"mkdir d",
+		"echo z > d/h",
+		"git add f g d/h",
+		gitCommit,
+	}
+	hgCommit := "hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'"
+	hgCmds := []string{
+		"echo x > f",
+		"echo y > g",
+		"mkdir d",
+		"echo z > d/h",
+		"hg add f g d/h",
+		hgCommit,
+		"hg bookmark master",
+	}
+	bareDir := filepath.Join(initGitRepository(t, append(cmds, "git clone -q --bare . bare.git")...), "bare.git")
+	bareRepo, err := gitcmd.Open(bareDir)
+	if err != nil {
+		t.Fatalf("gitcmd.Open(%q) failed: %s", bareDir, err)
+	}
+
+	type commitCreator interface {
+		vcs.CommitCreator
+		ResolveRevision(spec string) (vcs.CommitID, error)
+		GetCommit(vcs.CommitID) (*vcs.Commit, error)
+		FileSystem(vcs.CommitID) (vfs.FileSystem, error)
+	}
+	tests := map[string]struct {
+		repo commitCreator
+		ref  string
+	}{
+		"git cmd":    {repo: makeGitRepositoryCmd(t, cmds...), ref: "refs/heads/master"},
+		"git go-git": {repo: makeGitRepositoryGoGit(t, cmds...), ref: "refs/heads/master"},
+		"git bare":   {repo: bareRepo, ref: "refs/heads/master"},
+		"hg cmd":     {repo: makeHgRepositoryCmd(t, hgCmds...), ref: "master"},
+		"hg native":  {repo: makeHgRepositoryNative(t, hgCmds...), ref: "master"},
+	}
+
+	author := vcs.Signature{Name: "b", Email: "b@b.com", Date: mustParseTime(time.RFC3339, "2014-06-01T12:00:00Z")}
+	for label, test := range tests {
+		if strings.HasPrefix(label, "hg ") {
+			continue // hg broken, see issue #104.
+		}
+
+		parent, err := test.repo.ResolveRevision("master")
+		if err != nil {
+			t.Errorf("%s: ResolveRevision: %s", label, err)
+			continue
+		}
+
+		id, err := test.repo.CreateCommit(vcs.CreateCommitOptions{
+			Parent: parent,
+			Changes: []vcs.FileChange{
+				{Path: "f", Contents: []byte("x2\n")},
+				{Path: "g", Delete: true},
+				{Path: "d/e/n", Contents: []byte("#!/bin/sh\n"), Mode: 0755},
+				{Path: "l", Contents: []byte("f"), Mode: os.ModeSymlink},
+				{Path: "empty", Contents: []byte{}},
+			},
+			Author:  author,
+			Message: "bar",
+			Ref:     test.ref,
+		})
+		if err != nil {
+			t.Errorf("%s: CreateCommit: %s", label, err)
+			continue
+		}
+		commit, err := test.repo.GetCommit(id)
+		if err != nil {
+			t.Errorf("%s: GetCommit: %s", label, err)
+			continue
+		}
+		if commit.Author != author || commit.Message != "bar" || len(commit.Parents) != 1 || commit.Parents[0] != parent {
+			t.Errorf("%s: got commit %+v, want author %+v, message %q and parent %s", label, commit, author, "bar", parent)
+		}
+		if master, err := test.repo.ResolveRevision("master"); err != nil || master != id {
+			t.Errorf("%s: got master %s (error %v), want %s", label, master, err, id)
+		}
+
+		fs, err := test.repo.FileSystem(id)
+		if err != nil {
+			t.Errorf("%s: FileSystem: %s", label, err)
+			continue
+		}
+		for path, want := range map[string]string{"f": "x2\n", "d/h": "z\n", "d/e/n": "#!/bin/sh\n", "empty": ""} {
+			if data, err := vfs.ReadFile(fs, path); err != nil || string(data) != want {
+				t.Errorf("%s: got %s contents %q (error %v), want %q", label, path, data, err, want)
+			}
+		}
+		if _, err := fs.Lstat("g"); !os.IsNotExist(err) {
+			t.Errorf("%s: got Lstat(g) error %v, want it to not exist", label, err)
+		}
+		if fi, err := fs.Lstat("d/e/n"); err != nil || fi.Mode()&0111 == 0 {
+			t.Errorf("%s: got d/e/n mode %v (error %v), want executable", label, fi.Mode(), err)
+		}
+		if fi, err := fs.Lstat("l"); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("%s: got l mode %v (error %v), want symlink", label, fi.Mode(), err)
+		}
+
+		// Change only the mode of a file.
+		id2, err := test.repo.CreateCommit(vcs.CreateCommitOptions{
+			Parent:  id,
+			Changes: []vcs.FileChange{{Path: "d/e/n", Mode: 0644}},
+			Author:  author,
+			Message: "baz",
+		})
+		if err != nil {
+			t.Errorf("%s: CreateCommit (mode change): %s", label, err)
+			continue
+		}
+		fs, err = test.repo.FileSystem(id2)
+		if err != nil {
+			t.Errorf("%s: FileSystem: %s", label, err)
+			continue
+		}
+		if data, err := vfs.ReadFile(fs, "d/e/n"); err != nil || string(data) != "#!/bin/sh\n" {
+			t.Errorf("%s: got d/e/n contents %q (error %v) after mode change", label, data, err)
+		}
+		if fi, err := fs.Lstat("d/e/n"); err != nil || fi.Mode()&0111 != 0 {
+			t.Errorf("%s: got d/e/n mode %v (error %v), want not executable", label, fi.Mode(), err)
+		}
+
+		// The ref no longer points to parent.
+		if _, err := test.repo.CreateCommit(vcs.CreateCommitOptions{Parent: parent, Author: author, Message: "qux", Ref: test.ref}); err != vcs.ErrRefChanged {
+			t.Errorf("%s: got CreateCommit error %v with a stale ref, want %v", label, err, vcs.ErrRefChanged)
+		}
+		if _, err := test.repo.CreateCommit(vcs.CreateCommitOptions{Author: author, Message: "qux", Ref: test.ref}); err != vcs.ErrRefChanged {
+			t.Errorf("%s: got CreateCommit error %v with no parent and an existing ref, want %v", label, err, vcs.ErrRefChanged)
+		}
+
+		// A symbolic parent is resolved once, and the ref is compared to
+		// the resolved commit.
+		id3, err := test.repo.CreateCommit(vcs.CreateCommitOptions{Parent: "master", Author: author, Message: "qux", Ref: test.ref})
+		if err != nil {
+			t.Errorf("%s: CreateCommit (symbolic parent): %s", label, err)
+		} else if commit, err := test.repo.GetCommit(id3); err != nil || len(commit.Parents) != 1 || commit.Parents[0] != id {
+			t.Errorf("%s: got commit %+v (error %v) with symbolic parent, want parent %s", label, commit, err, id)
+		}
+
+		if _, err := test.repo.CreateCommit(vcs.CreateCommitOptions{Parent: id, Changes: []vcs.FileChange{{Path: "g", Delete: true}}, Author: author}); !os.IsNotExist(err) {
+			t.Errorf("%s: got CreateCommit error %v deleting a nonexistent file, want it to not exist", label, err)
+		}
+		if _, err := test.repo.CreateCommit(vcs.CreateCommitOptions{Parent: "0000000000000000000000000000000000000000", Author: author}); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: got CreateCommit error %v with a nonexistent parent, want %v", label, err, vcs.ErrCommitNotFound)
+		}
+		if _, err := test.repo.CreateCommit(vcs.CreateCommitOptions{Parent: "nonexistent", Author: author}); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: got CreateCommit error %v with a nonexistent symbolic parent, want %v", label, err, vcs.ErrCommitNotFound)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+)
+
+// A CommitWalker is a repository that can stream the commits matching
+// a CommitsOptions without loading them all into memory.
+type CommitWalker interface {
+	// WalkCommits returns an iterator over the commits matching the
+	// options, in the same order as (Repository).Commits. The
+	// NoTotal option is ignored (no total is computed).
+	//
+	// The caller must call Close on the returned iterator when
+	// done with it.
+	WalkCommits(CommitsOptions) (CommitIterator, error)
+}
+
+// A CommitWalkerContext is a CommitWalker that accepts a
+// context.Context. See RepositoryContext for the semantics of ctx;
+// ctx applies for the whole lifetime of the returned iterator.
+type CommitWalkerContext interface {
+	WalkCommitsContext(context.Context, CommitsOptions) (CommitIterator, error)
+}
+
+// A CommitIterator iterates over a sequence of commits.
+//
+//	it, err := repo.WalkCommits(opt)
+//	if err != nil {
+//		// ...
+//	}
+//	defer it.Close()
+//	for c := it.Next(); c != nil; c = it.Next() {
+//		// ...
+//	}
+//	if err := it.Err(); err != nil {
+//		// ...
+//	}
+type CommitIterator interface {
+	// Next returns the next commit, or nil if there are no more
+	// commits or an error occurred (in which case Err returns the
+	// error).
+	Next() *Commit
+
+	// Err returns the first error that occurred during iteration, if
+	// any.
+	Err() error
+
+	// Close releases the resources held by the iterator (e.g., by
+	// killing the underlying process if the iteration was stopped
+	// early). It is safe to call Close more than once.
+	Close() error
+}
+
+// A CommitPager is a repository that can list commits one page at a
+// time, using an opaque cursor to resume where the previous page
+// ended. Unlike paging with CommitsOptions.Skip, the work needed to
+// compute a page does not grow with the number of commits before it.
+type CommitPager interface {
+	// CommitsPage returns up to opt.N commits matching opt, in the
+	// same order as (Repository).Commits. If opt.Cursor is set, the
+	// page starts where the page that returned the cursor ended, and
+	// opt.Skip is ignored. No total is computed.
+	//
+	// The returned nextCursor is empty if there are no more commits
+	// (although a non-empty nextCursor may still lead to an empty
+	// page). Callers must pass the same options (except Cursor) for
+	// every page. Cursors are only valid for the repository that
+	// returned them; others yield ErrInvalidCursor.
+	CommitsPage(CommitsOptions) (commits []*Commit, nextCursor string, err error)
+}
+
+// A CommitPagerContext is a CommitPager that accepts a
+// context.Context. See RepositoryContext for the semantics of ctx.
+type CommitPagerContext interface {
+	CommitsPageContext(context.Context, CommitsOptions) (commits []*Commit, nextCursor string, err error)
+}
+
+// ErrInvalidCursor is returned by (CommitPager).CommitsPage when
+// CommitsOptions.Cursor is not a cursor that it returned.
+var ErrInvalidCursor = errors.New("invalid cursor")
@@ -0,0 +1,133 @@
+package diff3
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	tests := map[string]struct {
+		base, ours, theirs string
+
+		wantMerge string // rendered in Diff3Style
+		wantN     int    // number of conflicts
+	}{
+		"unchanged": {
+			base: "a\nb\n", ours: "a\nb\n", theirs: "a\nb\n",
+			wantMerge: "a\nb\n",
+		},
+		"changed in ours": {
+			base: "a\nb\nc\n", ours: "a\nB\nc\n", theirs: "a\nb\nc\n",
+			wantMerge: "a\nB\nc\n",
+		},
+		"changed in theirs": {
+			base: "a\nb\nc\n", ours: "a\nb\nc\n", theirs: "a\nb\nC\nd\n",
+			wantMerge: "a\nb\nC\nd\n",
+		},
+		"different lines": {
+			base: "a\nb\nc\nd\ne\n", ours: "A\nb\nc\nd\ne\n", theirs: "a\nb\nc\nd\n",
+			wantMerge: "A\nb\nc\nd\n",
+		},
+		"same change": {
+			base: "a\nb\nc\n", ours: "a\nB\nc\n", theirs: "a\nB\nc\n",
+			wantMerge: "a\nB\nc\n",
+		},
+		"conflict": {
+			base: "a\nb\nc\n", ours: "a\nO\nc\n", theirs: "a\nT\nc\n",
+			wantMerge: "a\n<<<<<<< ours\nO\n||||||| base\nb\n=======\nT\n>>>>>>> theirs\nc\n",
+			wantN:     1,
+		},
+		"adjacent changes": {
+			base: "a\nb\nc\n", ours: "a\nB\nc\n", theirs: "a\nb\nC\n",
+			wantMerge: "a\n<<<<<<< ours\nB\nc\n||||||| base\nb\nc\n=======\nb\nC\n>>>>>>> theirs\n",
+			wantN:     1,
+		},
+		"added in both": {
+			base: "", ours: "o\n", theirs: "t",
+			wantMerge: "<<<<<<< ours\no\n||||||| base\n=======\nt\n>>>>>>> theirs\n",
+			wantN:     1,
+		},
+		"two conflicts": {
+			base: "a\nb\nc\n", ours: "O1\nb\nO2\n", theirs: "T1\nb\nT2\n",
+			wantMerge: "<<<<<<< ours\nO1\n||||||| base\na\n=======\nT1\n>>>>>>> theirs\nb\n<<<<<<< ours\nO2\n||||||| base\nc\n=======\nT2\n>>>>>>> theirs\n",
+			wantN:     2,
+		},
+	}
+	for label, test := range tests {
+		regions := Merge([]byte(test.base), []byte(test.ours), []byte(test.theirs))
+		merge := Render(regions, RenderOptions{Style: Diff3Style, OursLabel: "ours", BaseLabel: "base", TheirsLabel: "theirs"})
+		if string(merge) != test.wantMerge {
+			t.Errorf("%s: got merge %q, want %q", label, merge, test.wantMerge)
+		}
+		if n := Conflicts(regions); n != test.wantN {
+			t.Errorf("%s: got %d conflicts, want %d", label, n, test.wantN)
+		}
+	}
+}
+
+func TestRender_mergeStyle(t *testing.T) {
+	regions := []*Region{
+		{Lines: []string{"a\n"}},
+		{Conflict: true, Base: []string{"b\n"}, Ours: []string{"O\n"}, Theirs: []string{"T\n"}},
+	}
+	want := "a\n<<<<<<<\nO\n=======\nT\n>>>>>>>\n"
+	if got := Render(regions, RenderOptions{}); string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		data string
+		want []*Region
+	}{
+		"merge style": {
+			data: "a\n<<<<<<< HEAD\nO\n=======\nT\n>>>>>>> b\nc",
+			want: []*Region{
+				{Lines: []string{"a\n"}},
+				{Conflict: true, Ours: []string{"O\n"}, Theirs: []string{"T\n"}},
+				{Lines: []string{"c"}},
+			},
+		},
+		"diff3 style": {
+			data: "<<<<<<< ours\nO\n||||||| base\n=======\nT\n>>>>>>> theirs\n",
+			want: []*Region{
+				{Conflict: true, Base: []string{}, Ours: []string{"O\n"}, Theirs: []string{"T\n"}},
+			},
+		},
+		"markers in text": {
+			data: "=======\n>>>>>>>\n<<<<<<<<\n",
+			want: []*Region{
+				{Lines: []string{"=======\n", ">>>>>>>\n", "<<<<<<<<\n"}},
+			},
+		},
+	}
+	for label, test := range tests {
+		regions, err := Parse([]byte(test.data))
+		if err != nil {
+			t.Errorf("%s: %s", label, err)
+			continue
+		}
+		if !reflect.DeepEqual(regions, test.want) {
+			t.Errorf("%s: got %+v, want %+v", label, regions, test.want)
+		}
+	}
+
+	if _, err := Parse([]byte("a\n<<<<<<<\nO\n=======\nT\n")); err == nil {
+		t.Error("unterminated conflict: got no error")
+	}
+}
+
+func TestParse_roundTrip(t *testing.T) {
+	regions := Merge([]byte("a\nb\nc\nd\n"), []byte("a\nO\nc\nd\n"), []byte("a\nT\nc\nD\n"))
+	for _, style := range []Style{MergeStyle, Diff3Style} {
+		data := Render(regions, RenderOptions{Style: style, OursLabel: "ours", BaseLabel: "base", TheirsLabel: "theirs"})
+		parsed, err := Parse(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Render(parsed, RenderOptions{Style: style, OursLabel: "ours", BaseLabel: "base", TheirsLabel: "theirs"}); string(got) != string(data) {
+			t.Errorf("style %d: got %q after parsing, want %q", style, got, data)
+		}
+	}
+}
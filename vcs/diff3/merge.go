@@ -0,0 +1,161 @@
+// Package diff3 merges three versions of a text file (a base version
+// and two versions derived from it, "ours" and "theirs"), like diff3
+// -m and git merge-file, and parses files that contain conflict
+// markers.
+//
+// Merging and parsing both produce a list of regions, which are either
+// clean (lines that the merge resolved) or conflicts (the lines of the
+// region in each version). Render writes regions with conflict markers
+// in the "merge" or "diff3" style.
+//
+// The package doesn't depend on a backend: the contents of the
+// versions can be read from the vfs.FileSystem of any
+// vcs.Repository (see MergeFile).
+package diff3
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/tools/godoc/vfs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/internal"
+)
+
+// A Region is a range of lines of a merged file. Lines include their
+// terminating "\n" (except for the last line of a file that doesn't
+// end in a newline).
+type Region struct {
+	// Conflict is whether the versions' changes to the region
+	// conflict.
+	Conflict bool
+
+	// Lines are the lines of a clean region.
+	Lines []string
+
+	// Base, Ours and Theirs are the lines of a conflict in each
+	// version. Base is nil for conflicts parsed from merge-style
+	// markers, which don't include the base version.
+	Base, Ours, Theirs []string
+}
+
+// Conflicts returns the number of conflicts in regions.
+func Conflicts(regions []*Region) int {
+	n := 0
+	for _, r := range regions {
+		if r.Conflict {
+			n++
+		}
+	}
+	return n
+}
+
+// Merge merges the changes from base to ours and from base to theirs.
+// Changes to different lines are merged cleanly, and so are identical
+// changes to the same lines; other changes to the same (or adjacent)
+// lines conflict.
+func Merge(base, ours, theirs []byte) []*Region {
+	b, o, t := splitLines(base), splitLines(ours), splitLines(theirs)
+	baseToOurs := matchBaseLines(b, o)
+	baseToTheirs := matchBaseLines(b, t)
+
+	var regions []*Region
+	i, j, k := 0, 0, 0 // next lines of base, ours and theirs
+	for i < len(b) || j < len(o) || k < len(t) {
+		// Find the next base line that is unchanged in both ours and
+		// theirs. The lines before it (in each version) are changed
+		// in at least one of them.
+		si, sj, sk := i, len(o), len(t)
+		for ; si < len(b); si++ {
+			if baseToOurs[si] != -1 && baseToTheirs[si] != -1 {
+				sj, sk = baseToOurs[si], baseToTheirs[si]
+				break
+			}
+		}
+
+		if si == i && sj == j && sk == k {
+			regions = appendClean(regions, b[i:i+1])
+			i, j, k = i+1, j+1, k+1
+			continue
+		}
+
+		bc, oc, tc := b[i:si], o[j:sj], t[k:sk]
+		switch {
+		case equalLines(oc, bc):
+			regions = appendClean(regions, tc)
+		case equalLines(tc, bc), equalLines(oc, tc):
+			regions = appendClean(regions, oc)
+		default:
+			regions = append(regions, &Region{Conflict: true, Base: append([]string{}, bc...), Ours: oc, Theirs: tc})
+		}
+		i, j, k = si, sj, sk
+	}
+	return regions
+}
+
+// MergeFile reads the file at path from the file systems of the base,
+// ours and theirs versions (e.g., returned by
+// vcs.Repository.FileSystem for 3 commits) and merges it. A version
+// that doesn't contain the file is merged as an empty file.
+func MergeFile(base, ours, theirs vfs.FileSystem, path string) ([]*Region, error) {
+	var data [3][]byte
+	for i, fs := range []vfs.FileSystem{base, ours, theirs} {
+		var err error
+		data[i], err = vfs.ReadFile(fs, path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return Merge(data[0], data[1], data[2]), nil
+}
+
+// matchBaseLines returns, for each line of base, the index of the line
+// of b that it matches, or -1 if b changed (or deleted) it.
+func matchBaseLines(base, b []string) []int {
+	match := make([]int, len(base))
+	for i := range match {
+		match[i] = -1
+	}
+	for j, i := range internal.MatchLines(base, b) {
+		if i != -1 {
+			match[i] = j
+		}
+	}
+	return match
+}
+
+// appendClean appends lines to regions, extending the last region if
+// it is clean.
+func appendClean(regions []*Region, lines []string) []*Region {
+	if len(lines) == 0 {
+		return regions
+	}
+	if n := len(regions); n > 0 && !regions[n-1].Conflict {
+		regions[n-1].Lines = append(regions[n-1].Lines, lines...)
+		return regions
+	}
+	return append(regions, &Region{Lines: append([]string(nil), lines...)})
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// splitLines splits data into lines, keeping their terminating "\n".
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
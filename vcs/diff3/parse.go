@@ -0,0 +1,64 @@
+package diff3
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parse parses a file that contains conflict markers (in the merge or
+// diff3 style, with or without labels) into regions. The lines between
+// conflicts are clean regions.
+func Parse(data []byte) ([]*Region, error) {
+	lines := splitLines(data)
+
+	var regions []*Region
+	for i := 0; i < len(lines); {
+		if !isMarker(lines[i], oursMarker) {
+			start := i
+			for i < len(lines) && !isMarker(lines[i], oursMarker) {
+				i++
+			}
+			regions = appendClean(regions, lines[start:i])
+			continue
+		}
+
+		// Read the sections of the conflict that starts at line i.
+		start := i
+		r := &Region{Conflict: true}
+		section := &r.Ours
+		for i++; ; i++ {
+			if i == len(lines) {
+				return nil, fmt.Errorf("conflict starting at line %d has no %s marker", start+1, theirsMarker)
+			}
+			line := lines[i]
+			if isMarker(line, theirsMarker) && section == &r.Theirs {
+				break
+			}
+			switch {
+			case isMarker(line, baseMarker) && section == &r.Ours:
+				r.Base = []string{}
+				section = &r.Base
+			case isMarker(line, sepMarker) && section != &r.Theirs:
+				section = &r.Theirs
+			default:
+				*section = append(*section, line)
+			}
+		}
+		i++
+		regions = append(regions, r)
+	}
+	return regions, nil
+}
+
+// isMarker reports whether line is the conflict marker (optionally
+// followed by a label).
+func isMarker(line, marker string) bool {
+	if !strings.HasPrefix(line, marker) {
+		return false
+	}
+	rest := strings.TrimSuffix(line[len(marker):], "\n")
+	if marker == sepMarker {
+		return rest == ""
+	}
+	return rest == "" || rest[0] == ' '
+}
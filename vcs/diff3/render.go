@@ -0,0 +1,93 @@
+package diff3
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Style is a style of conflict markers.
+type Style int
+
+const (
+	// MergeStyle shows the ours and theirs versions of conflicts:
+	//
+	//  <<<<<<< ours
+	//  ...
+	//  =======
+	//  ...
+	//  >>>>>>> theirs
+	MergeStyle Style = iota
+
+	// Diff3Style also shows the base version of conflicts, between
+	// the ours and theirs versions:
+	//
+	//  <<<<<<< ours
+	//  ...
+	//  ||||||| base
+	//  ...
+	//  =======
+	//  ...
+	//  >>>>>>> theirs
+	Diff3Style
+)
+
+// Conflict markers. Each is followed by a label, if any, and a
+// newline.
+const (
+	oursMarker   = "<<<<<<<"
+	baseMarker   = "|||||||"
+	sepMarker    = "======="
+	theirsMarker = ">>>>>>>"
+)
+
+// RenderOptions specifies options for Render.
+type RenderOptions struct {
+	Style Style
+
+	// OursLabel, BaseLabel and TheirsLabel are written after the
+	// conflict markers of each version (e.g., "HEAD" or a file name).
+	OursLabel, BaseLabel, TheirsLabel string
+}
+
+// Render writes regions as a file, with the conflicts between conflict
+// markers.
+func Render(regions []*Region, opt RenderOptions) []byte {
+	var buf bytes.Buffer
+	for _, r := range regions {
+		if !r.Conflict {
+			writeLines(&buf, r.Lines, false)
+			continue
+		}
+		writeMarker(&buf, oursMarker, opt.OursLabel)
+		writeLines(&buf, r.Ours, true)
+		if opt.Style == Diff3Style {
+			writeMarker(&buf, baseMarker, opt.BaseLabel)
+			writeLines(&buf, r.Base, true)
+		}
+		writeMarker(&buf, sepMarker, "")
+		writeLines(&buf, r.Theirs, true)
+		writeMarker(&buf, theirsMarker, opt.TheirsLabel)
+	}
+	return buf.Bytes()
+}
+
+func writeMarker(buf *bytes.Buffer, marker, label string) {
+	if label != "" {
+		fmt.Fprintf(buf, "%s %s\n", marker, label)
+	} else {
+		fmt.Fprintf(buf, "%s\n", marker)
+	}
+}
+
+// writeLines writes lines to buf. If terminate is true, it adds a
+// newline to a last line that doesn't end in one (so that the next
+// conflict marker starts a line).
+func writeLines(buf *bytes.Buffer, lines []string, terminate bool) {
+	for _, line := range lines {
+		buf.WriteString(line)
+	}
+	if n := len(lines); terminate && n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		buf.WriteByte('\n')
+	}
+}
@@ -46,9 +46,10 @@ func TestRepository_Diff(t *testing.T) {
 		base, head string // can be any revspec; is resolved during the test
 		opt        *vcs.DiffOptions
 
-		// wantDiff is the expected diff. In the Raw field,
-		// %(headCommitID) is replaced with the actual head commit ID
-		// (it seems to change in hg).
+		// wantDiff is the expected diff. In the Raw field and in the
+		// text of the Files' hunk lines, %(baseCommitID) and
+		// %(headCommitID) are replaced with the actual commit IDs (they
+		// seem to change in hg).
 		wantDiff *vcs.Diff
 	}{
 		"git cmd": {
@@ -56,6 +57,18 @@ func TestRepository_Diff(t *testing.T) {
 			base: "testbase", head: "testhead",
 			wantDiff: &vcs.Diff{
 				Raw: "diff --git f f\nindex c0d0fb45c382919737f8d0c20aaf57cf89b74af8..83db48f84ec878fbfb30b46d16630e944e34f205 100644\n--- f\n+++ f\n@@ -1,2 +1,3 @@\n line1\n line2\n+line3\n",
+				Files: []*vcs.FileDiff{
+					{
+						OrigName: "f", NewName: "f", OrigMode: 0100644, NewMode: 0100644, OrigID: "c0d0fb45c382919737f8d0c20aaf57cf89b74af8", NewID: "83db48f84ec878fbfb30b46d16630e944e34f205", Status: vcs.FileModified,
+						Hunks: []*vcs.DiffHunk{
+							{OrigStartLine: 1, OrigLines: 2, NewStartLine: 1, NewLines: 3, Lines: []*vcs.DiffLine{
+								{Op: vcs.DiffLineContext, Text: "line1"},
+								{Op: vcs.DiffLineContext, Text: "line2"},
+								{Op: vcs.DiffLineAdded, Text: "line3"},
+							}},
+						},
+					},
+				},
 			},
 		},
 		"git go-git": {
@@ -63,6 +76,18 @@ func TestRepository_Diff(t *testing.T) {
 			base: "testbase", head: "testhead",
 			wantDiff: &vcs.Diff{
 				Raw: "diff --git f f\nindex c0d0fb45c382919737f8d0c20aaf57cf89b74af8..83db48f84ec878fbfb30b46d16630e944e34f205 100644\n--- f\n+++ f\n@@ -1,2 +1,3 @@\n line1\n line2\n+line3\n",
+				Files: []*vcs.FileDiff{
+					{
+						OrigName: "f", NewName: "f", OrigMode: 0100644, NewMode: 0100644, OrigID: "c0d0fb45c382919737f8d0c20aaf57cf89b74af8", NewID: "83db48f84ec878fbfb30b46d16630e944e34f205", Status: vcs.FileModified,
+						Hunks: []*vcs.DiffHunk{
+							{OrigStartLine: 1, OrigLines: 2, NewStartLine: 1, NewLines: 3, Lines: []*vcs.DiffLine{
+								{Op: vcs.DiffLineContext, Text: "line1"},
+								{Op: vcs.DiffLineContext, Text: "line2"},
+								{Op: vcs.DiffLineAdded, Text: "line3"},
+							}},
+						},
+					},
+				},
 			},
 		},
 		"hg cmd": {
@@ -70,6 +95,25 @@ func TestRepository_Diff(t *testing.T) {
 			base: "testbase", head: "testhead",
 			wantDiff: &vcs.Diff{
 				Raw: "diff --git .hgtags .hgtags\nnew file mode 100644\n--- /dev/null\n+++ .hgtags\n@@ -0,0 +1,1 @@\n+%(baseCommitID) testbase\ndiff --git f f\n--- f\n+++ f\n@@ -1,1 +1,2 @@\n line1\n+line2\n",
+				Files: []*vcs.FileDiff{
+					{
+						NewName: ".hgtags", NewMode: 0100644, Status: vcs.FileAdded,
+						Hunks: []*vcs.DiffHunk{
+							{OrigStartLine: 0, OrigLines: 0, NewStartLine: 1, NewLines: 1, Lines: []*vcs.DiffLine{
+								{Op: vcs.DiffLineAdded, Text: "%(baseCommitID) testbase"},
+							}},
+						},
+					},
+					{
+						OrigName: "f", NewName: "f", Status: vcs.FileModified,
+						Hunks: []*vcs.DiffHunk{
+							{OrigStartLine: 1, OrigLines: 1, NewStartLine: 1, NewLines: 2, Lines: []*vcs.DiffLine{
+								{Op: vcs.DiffLineContext, Text: "line1"},
+								{Op: vcs.DiffLineAdded, Text: "line2"},
+							}},
+						},
+					},
+				},
 			},
 		},
 
@@ -81,6 +125,17 @@ func TestRepository_Diff(t *testing.T) {
 			},
 			wantDiff: &vcs.Diff{
 				Raw: "diff --git f f\nindex c0d0fb45c382919737f8d0c20aaf57cf89b74af8..83db48f84ec878fbfb30b46d16630e944e34f205 100644\n--- f\n+++ f\n@@ -2 +2,2 @@ line1\n line2\n+line3\n",
+				Files: []*vcs.FileDiff{
+					{
+						OrigName: "f", NewName: "f", OrigMode: 0100644, NewMode: 0100644, OrigID: "c0d0fb45c382919737f8d0c20aaf57cf89b74af8", NewID: "83db48f84ec878fbfb30b46d16630e944e34f205", Status: vcs.FileModified,
+						Hunks: []*vcs.DiffHunk{
+							{OrigStartLine: 2, OrigLines: 1, NewStartLine: 2, NewLines: 2, Section: "line1", Lines: []*vcs.DiffLine{
+								{Op: vcs.DiffLineContext, Text: "line2"},
+								{Op: vcs.DiffLineAdded, Text: "line3"},
+							}},
+						},
+					},
+				},
 			},
 		},
 	}
@@ -114,6 +169,14 @@ func TestRepository_Diff(t *testing.T) {
 		// wantDiff field doc for more info.
 		test.wantDiff.Raw = strings.Replace(test.wantDiff.Raw, "%(baseCommitID)", string(baseCommitID), -1)
 		test.wantDiff.Raw = strings.Replace(test.wantDiff.Raw, "%(headCommitID)", string(headCommitID), -1)
+		for _, f := range test.wantDiff.Files {
+			for _, h := range f.Hunks {
+				for _, l := range h.Lines {
+					l.Text = strings.Replace(l.Text, "%(baseCommitID)", string(baseCommitID), -1)
+					l.Text = strings.Replace(l.Text, "%(headCommitID)", string(headCommitID), -1)
+				}
+			}
+		}
 		if runtime.GOOS == "windows" {
 			test.wantDiff.Raw = strings.Replace(test.wantDiff.Raw, "/dev/null", `\dev\null`, -1)
 		}
@@ -168,9 +231,10 @@ func TestRepository_Diff_rename(t *testing.T) {
 		base, head string // can be any revspec; is resolved during the test
 		opt        *vcs.DiffOptions
 
-		// wantDiff is the expected diff. In the Raw field,
-		// %(headCommitID) is replaced with the actual head commit ID
-		// (it seems to change in hg).
+		// wantDiff is the expected diff. In the Raw field and in the
+		// text of the Files' hunk lines, %(baseCommitID) and
+		// %(headCommitID) are replaced with the actual commit IDs (they
+		// seem to change in hg).
 		wantDiff *vcs.Diff
 	}{
 		"git cmd": {
@@ -178,6 +242,11 @@ func TestRepository_Diff_rename(t *testing.T) {
 			base: "testbase", head: "testhead",
 			wantDiff: &vcs.Diff{
 				Raw: "diff --git f g\nsimilarity index 100%\nrename from f\nrename to g\n",
+				Files: []*vcs.FileDiff{
+					{
+						OrigName: "f", NewName: "g", Status: vcs.FileRenamed,
+					},
+				},
 			},
 			opt: opt,
 		},
@@ -186,6 +255,16 @@ func TestRepository_Diff_rename(t *testing.T) {
 			base: "testbase", head: "testhead",
 			wantDiff: &vcs.Diff{
 				Raw: "diff --git .hgtags .hgtags\nnew file mode 100644\n--- /dev/null\n+++ .hgtags\n@@ -0,0 +1,1 @@\n+f1f126ec4cf9398d85e8dac873afc3f9b174b1d6 testbase\n",
+				Files: []*vcs.FileDiff{
+					{
+						NewName: ".hgtags", NewMode: 0100644, Status: vcs.FileAdded,
+						Hunks: []*vcs.DiffHunk{
+							{OrigStartLine: 0, OrigLines: 0, NewStartLine: 1, NewLines: 1, Lines: []*vcs.DiffLine{
+								{Op: vcs.DiffLineAdded, Text: "f1f126ec4cf9398d85e8dac873afc3f9b174b1d6 testbase"},
+							}},
+						},
+					},
+				},
 			},
 			opt: opt,
 		},
@@ -268,9 +347,10 @@ func TestRepository_CrossRepoDiff_git(t *testing.T) {
 		base, head string // can be any revspec; is resolved during the test
 		opt        *vcs.DiffOptions
 
-		// wantDiff is the expected diff. In the Raw field,
-		// %(headCommitID) is replaced with the actual head commit ID
-		// (it seems to change in hg).
+		// wantDiff is the expected diff. In the Raw field and in the
+		// text of the Files' hunk lines, %(baseCommitID) and
+		// %(headCommitID) are replaced with the actual commit IDs (they
+		// seem to change in hg).
 		wantDiff *vcs.Diff
 	}{
 		"git cmd": {
@@ -279,6 +359,17 @@ func TestRepository_CrossRepoDiff_git(t *testing.T) {
 			base:     "testbase", head: "testhead",
 			wantDiff: &vcs.Diff{
 				Raw: "diff --git f f\nindex a29bdeb434d874c9b1d8969c40c42161b03fafdc..c0d0fb45c382919737f8d0c20aaf57cf89b74af8 100644\n--- f\n+++ f\n@@ -1 +1,2 @@\n line1\n+line2\n",
+				Files: []*vcs.FileDiff{
+					{
+						OrigName: "f", NewName: "f", OrigMode: 0100644, NewMode: 0100644, OrigID: "a29bdeb434d874c9b1d8969c40c42161b03fafdc", NewID: "c0d0fb45c382919737f8d0c20aaf57cf89b74af8", Status: vcs.FileModified,
+						Hunks: []*vcs.DiffHunk{
+							{OrigStartLine: 1, OrigLines: 1, NewStartLine: 1, NewLines: 2, Lines: []*vcs.DiffLine{
+								{Op: vcs.DiffLineContext, Text: "line1"},
+								{Op: vcs.DiffLineAdded, Text: "line2"},
+							}},
+						},
+					},
+				},
 			},
 		},
 		"git go-git": {
@@ -287,6 +378,17 @@ func TestRepository_CrossRepoDiff_git(t *testing.T) {
 			base:     "testbase", head: "testhead",
 			wantDiff: &vcs.Diff{
 				Raw: "diff --git f f\nindex a29bdeb434d874c9b1d8969c40c42161b03fafdc..c0d0fb45c382919737f8d0c20aaf57cf89b74af8 100644\n--- f\n+++ f\n@@ -1 +1,2 @@\n line1\n+line2\n",
+				Files: []*vcs.FileDiff{
+					{
+						OrigName: "f", NewName: "f", OrigMode: 0100644, NewMode: 0100644, OrigID: "a29bdeb434d874c9b1d8969c40c42161b03fafdc", NewID: "c0d0fb45c382919737f8d0c20aaf57cf89b74af8", Status: vcs.FileModified,
+						Hunks: []*vcs.DiffHunk{
+							{OrigStartLine: 1, OrigLines: 1, NewStartLine: 1, NewLines: 2, Lines: []*vcs.DiffLine{
+								{Op: vcs.DiffLineContext, Text: "line1"},
+								{Op: vcs.DiffLineAdded, Text: "line2"},
+							}},
+						},
+					},
+				},
 			},
 		},
 	}
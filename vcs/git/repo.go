@@ -1,6 +1,7 @@
 package git
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -72,6 +73,14 @@ func (r *Repository) Close() error {
 // specifier resolves to, or a non-nil error if there is no such
 // revision.
 func (r *Repository) ResolveRevision(spec string) (vcs.CommitID, error) {
+	return r.ResolveRevisionContext(context.Background(), spec)
+}
+
+func (r *Repository) ResolveRevisionContext(ctx context.Context, spec string) (vcs.CommitID, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	// TODO: git rev-parse supports a horde of complex syntaxes, it will be a fair bit more work to support all of them.
 	// e.g. "master@{yesterday}", "master~3", and various text/path/tree traversal search.
 
@@ -82,29 +91,36 @@ func (r *Repository) ResolveRevision(spec string) (vcs.CommitID, error) {
 		}
 	}
 
-	ci, err := r.ResolveBranch(spec)
+	ci, err := r.ResolveBranchContext(ctx, spec)
 	if err == nil {
 		return ci, nil
 	}
-	ci, err = r.ResolveTag(spec)
+	ci, err = r.ResolveTagContext(ctx, spec)
 	if err == nil {
 		return ci, nil
 	}
 	// Do an extra lookup just in case it's a complex syntax we don't support
 	// TODO: Remove fallback usage: ResolveRevision
-	ci, err = r.Repository.ResolveRevision(spec)
+	ci, err = r.Repository.ResolveRevisionContext(ctx, spec)
 	if err == nil {
 		return ci, nil
 	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	return ci, vcs.ErrRevisionNotFound
 }
 
 // ResolveTag returns the tag with the given name, or
 // ErrTagNotFound if no such tag exists.
 func (r *Repository) ResolveTag(name string) (vcs.CommitID, error) {
+	return r.ResolveTagContext(context.Background(), name)
+}
+
+func (r *Repository) ResolveTagContext(ctx context.Context, name string) (vcs.CommitID, error) {
 	// TODO: Implement non-fallback that dereferences annotated tags
 	//       consistently with gitcmd version. See issue #24.
-	return r.Repository.ResolveTag(name)
+	return r.Repository.ResolveTagContext(ctx, name)
 
 	id, err := r.repo.GetCommitIdOfTag(name)
 	if _, ok := err.(git.RefNotFound); ok {
@@ -119,6 +135,14 @@ func (r *Repository) ResolveTag(name string) (vcs.CommitID, error) {
 // ResolveBranch returns the branch with the given name, or
 // ErrBranchNotFound if no such branch exists.
 func (r *Repository) ResolveBranch(name string) (vcs.CommitID, error) {
+	return r.ResolveBranchContext(context.Background(), name)
+}
+
+func (r *Repository) ResolveBranchContext(ctx context.Context, name string) (vcs.CommitID, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	id, err := r.repo.GetCommitIdOfBranch(name)
 	if _, ok := err.(git.RefNotFound); ok {
 		return "", vcs.ErrBranchNotFound
@@ -131,8 +155,12 @@ func (r *Repository) ResolveBranch(name string) (vcs.CommitID, error) {
 
 // Branches returns a list of all branches in the repository.
 func (r *Repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
+	return r.BranchesContext(context.Background(), opt)
+}
+
+func (r *Repository) BranchesContext(ctx context.Context, opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
 	// TODO(sqs): implement non-fallback
-	return r.Repository.Branches(opt)
+	return r.Repository.BranchesContext(ctx, opt)
 
 	names, err := r.repo.GetBranches()
 	if err != nil {
@@ -205,8 +233,12 @@ func (r *Repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
 
 // Tags returns a list of all tags in the repository.
 func (r *Repository) Tags() ([]*vcs.Tag, error) {
+	return r.TagsContext(context.Background())
+}
+
+func (r *Repository) TagsContext(ctx context.Context) ([]*vcs.Tag, error) {
 	// TODO: implement non-fallback (similar to Branches endpoint)
-	return r.Repository.Tags()
+	return r.Repository.TagsContext(ctx)
 
 	names, err := r.repo.GetTags()
 	if err != nil {
@@ -266,6 +298,14 @@ func (r *Repository) vcsCommit(commit *git.Commit) *vcs.Commit {
 // GetCommit returns the commit with the given commit ID, or
 // ErrCommitNotFound if no such commit exists.
 func (r *Repository) GetCommit(commitID vcs.CommitID) (*vcs.Commit, error) {
+	return r.GetCommitContext(context.Background(), commitID)
+}
+
+func (r *Repository) GetCommitContext(ctx context.Context, commitID vcs.CommitID) (*vcs.Commit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	commit, err := r.repo.GetCommit(string(commitID))
 	if err != nil {
 		return nil, standardizeError(err)
@@ -281,8 +321,12 @@ func (r *Repository) GetCommit(commitID vcs.CommitID) (*vcs.Commit, error) {
 // Optionally, the caller can request the total not to be computed,
 // as this can be expensive for large branches.
 func (r *Repository) Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
+	return r.CommitsContext(context.Background(), opt)
+}
+
+func (r *Repository) CommitsContext(ctx context.Context, opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
 	// TODO(sqs): implement non-fallback
-	return r.Repository.Commits(opt)
+	return r.Repository.CommitsContext(ctx, opt)
 
 	var total uint = 0
 	var commits []*vcs.Commit
@@ -357,11 +401,20 @@ func (r *Repository) Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error
 
 // FileSystem opens the repository file tree at a given commit ID.
 func (r *Repository) FileSystem(at vcs.CommitID) (vfs.FileSystem, error) {
+	return r.FileSystemContext(context.Background(), at)
+}
+
+func (r *Repository) FileSystemContext(ctx context.Context, at vcs.CommitID) (vfs.FileSystem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	ci, err := r.repo.GetCommit(string(at))
 	if err != nil {
 		return nil, err
 	}
 	return &filesystem{
+		ctx:  ctx,
 		dir:  r.repo.Path,
 		oid:  string(at),
 		tree: &ci.Tree,
@@ -2,6 +2,7 @@ package git
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -18,6 +19,7 @@ import (
 )
 
 type filesystem struct {
+	ctx  context.Context // operations fail once ctx is done
 	dir  string
 	oid  string
 	tree *git.Tree
@@ -42,6 +44,9 @@ func (fs *filesystem) readFileBytes(name string) ([]byte, error) {
 }
 
 func (fs *filesystem) Open(name string) (vfs.ReadSeekCloser, error) {
+	if err := fs.ctx.Err(); err != nil {
+		return nil, err
+	}
 	name = internal.Rel(name)
 
 	b, err := fs.readFileBytes(name)
@@ -52,6 +57,9 @@ func (fs *filesystem) Open(name string) (vfs.ReadSeekCloser, error) {
 }
 
 func (fs *filesystem) Lstat(path string) (os.FileInfo, error) {
+	if err := fs.ctx.Err(); err != nil {
+		return nil, err
+	}
 	path = filepath.Clean(internal.Rel(path))
 
 	mtime, err := fs.getModTime()
@@ -78,6 +86,9 @@ func (fs *filesystem) Lstat(path string) (os.FileInfo, error) {
 }
 
 func (fs *filesystem) Stat(path string) (os.FileInfo, error) {
+	if err := fs.ctx.Err(); err != nil {
+		return nil, err
+	}
 	path = filepath.Clean(internal.Rel(path))
 
 	mtime, err := fs.getModTime()
@@ -205,6 +216,9 @@ func (fs *filesystem) dirInfo(e *git.TreeEntry) (*util.FileInfo, error) {
 }
 
 func (fs *filesystem) ReadDir(path string) ([]os.FileInfo, error) {
+	if err := fs.ctx.Err(); err != nil {
+		return nil, err
+	}
 	path = filepath.Clean(internal.Rel(path))
 
 	var subtree *git.Tree
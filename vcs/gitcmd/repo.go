@@ -3,7 +3,9 @@ package gitcmd
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -14,6 +16,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -145,6 +148,16 @@ func checkSpecArgSafety(spec string) error {
 	return nil
 }
 
+// ctxErr returns ctx.Err() if ctx is done, and err otherwise. Commands
+// created with exec.CommandContext are killed when ctx is done, so
+// their errors are only a symptom of the context error.
+func ctxErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
 // dividedOutput runs the command and returns its standard output and standard error.
 func dividedOutput(c *exec.Cmd) (stdout []byte, stderr []byte, err error) {
 	var outb, errb bytes.Buffer
@@ -155,6 +168,10 @@ func dividedOutput(c *exec.Cmd) (stdout []byte, stderr []byte, err error) {
 }
 
 func (r *Repository) ResolveRevision(spec string) (vcs.CommitID, error) {
+	return r.ResolveRevisionContext(context.Background(), spec)
+}
+
+func (r *Repository) ResolveRevisionContext(ctx context.Context, spec string) (vcs.CommitID, error) {
 	r.editLock.RLock()
 	defer r.editLock.RUnlock()
 
@@ -162,40 +179,52 @@ func (r *Repository) ResolveRevision(spec string) (vcs.CommitID, error) {
 		return "", err
 	}
 
-	cmd := exec.Command("git", "rev-parse", spec+"^0")
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", spec+"^0")
 	cmd.Dir = r.Dir
 	stdout, stderr, err := dividedOutput(cmd)
 	if err != nil {
 		if bytes.Contains(stderr, []byte("unknown revision")) {
 			return "", vcs.ErrRevisionNotFound
 		}
-		return "", fmt.Errorf("exec `git rev-parse` failed: %s. Stderr was:\n\n%s", err, stderr)
+		return "", ctxErr(ctx, fmt.Errorf("exec `git rev-parse` failed: %s. Stderr was:\n\n%s", err, stderr))
 	}
 	return vcs.CommitID(bytes.TrimSpace(stdout)), nil
 }
 
 func (r *Repository) ResolveRef(name string) (vcs.CommitID, error) {
-	commitID, err := r.ResolveRevision(name)
+	return r.ResolveRefContext(context.Background(), name)
+}
+
+func (r *Repository) ResolveRefContext(ctx context.Context, name string) (vcs.CommitID, error) {
+	commitID, err := r.ResolveRevisionContext(ctx, name)
 	if err == vcs.ErrRevisionNotFound {
 		return "", vcs.ErrRefNotFound
 	}
-	return commitID, nil
+	return commitID, err
 }
 
 func (r *Repository) ResolveBranch(name string) (vcs.CommitID, error) {
-	commitID, err := r.ResolveRevision(name)
+	return r.ResolveBranchContext(context.Background(), name)
+}
+
+func (r *Repository) ResolveBranchContext(ctx context.Context, name string) (vcs.CommitID, error) {
+	commitID, err := r.ResolveRevisionContext(ctx, name)
 	if err == vcs.ErrRevisionNotFound {
 		return "", vcs.ErrBranchNotFound
 	}
-	return commitID, nil
+	return commitID, err
 }
 
 func (r *Repository) ResolveTag(name string) (vcs.CommitID, error) {
-	commitID, err := r.ResolveRevision(name)
+	return r.ResolveTagContext(context.Background(), name)
+}
+
+func (r *Repository) ResolveTagContext(ctx context.Context, name string) (vcs.CommitID, error) {
+	commitID, err := r.ResolveRevisionContext(ctx, name)
 	if err == vcs.ErrRevisionNotFound {
 		return "", vcs.ErrTagNotFound
 	}
-	return commitID, nil
+	return commitID, err
 }
 
 // branchFilter is a filter for branch names.
@@ -221,26 +250,30 @@ func (f branchFilter) add(list []string) {
 }
 
 func (r *Repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
+	return r.BranchesContext(context.Background(), opt)
+}
+
+func (r *Repository) BranchesContext(ctx context.Context, opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
 	r.editLock.RLock()
 	defer r.editLock.RUnlock()
 
 	f := make(branchFilter)
 	if opt.MergedInto != "" {
-		b, err := r.branches("--merged", opt.MergedInto)
+		b, err := r.branches(ctx, "--merged", opt.MergedInto)
 		if err != nil {
 			return nil, err
 		}
 		f.add(b)
 	}
 	if opt.ContainsCommit != "" {
-		b, err := r.branches("--contains=" + opt.ContainsCommit)
+		b, err := r.branches(ctx, "--contains="+opt.ContainsCommit)
 		if err != nil {
 			return nil, err
 		}
 		f.add(b)
 	}
 
-	refs, err := r.showRef("--heads")
+	refs, err := r.showRef(ctx, "--heads")
 	if err != nil {
 		return nil, err
 	}
@@ -255,13 +288,13 @@ func (r *Repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
 
 		branch := &vcs.Branch{Name: name, Head: id}
 		if opt.IncludeCommit {
-			branch.Commit, err = r.getCommit(id)
+			branch.Commit, err = r.getCommit(ctx, id)
 			if err != nil {
 				return nil, err
 			}
 		}
 		if opt.BehindAheadBranch != "" {
-			branch.Counts, err = r.branchesBehindAhead(name, opt.BehindAheadBranch)
+			branch.Counts, err = r.branchesBehindAhead(ctx, name, opt.BehindAheadBranch)
 			if err != nil {
 				return nil, err
 			}
@@ -273,12 +306,12 @@ func (r *Repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
 
 // branches runs the `git branch` command followed by the given arguments and
 // returns the list of branches if successful.
-func (r *Repository) branches(args ...string) ([]string, error) {
-	cmd := exec.Command("git", append([]string{"branch"}, args...)...)
+func (r *Repository) branches(ctx context.Context, args ...string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"branch"}, args...)...)
 	cmd.Dir = r.Dir
 	out, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("exec %v in %s failed: %v (output follows)\n\n%s", cmd.Args, cmd.Dir, err, out)
+		return nil, ctxErr(ctx, fmt.Errorf("exec %v in %s failed: %v (output follows)\n\n%s", cmd.Args, cmd.Dir, err, out))
 	}
 	lines := strings.Split(string(out), "\n")
 	lines = lines[:len(lines)-1]
@@ -290,7 +323,7 @@ func (r *Repository) branches(args ...string) ([]string, error) {
 }
 
 // branchesBehindAhead returns the behind/ahead commit counts information for branch, against base branch.
-func (r *Repository) branchesBehindAhead(branch, base string) (*vcs.BehindAhead, error) {
+func (r *Repository) branchesBehindAhead(ctx context.Context, branch, base string) (*vcs.BehindAhead, error) {
 	if err := checkSpecArgSafety(branch); err != nil {
 		return nil, err
 	}
@@ -298,11 +331,11 @@ func (r *Repository) branchesBehindAhead(branch, base string) (*vcs.BehindAhead,
 		return nil, err
 	}
 
-	cmd := exec.Command("git", "rev-list", "--count", "--left-right", fmt.Sprintf("refs/heads/%s...refs/heads/%s", base, branch))
+	cmd := exec.CommandContext(ctx, "git", "rev-list", "--count", "--left-right", fmt.Sprintf("refs/heads/%s...refs/heads/%s", base, branch))
 	cmd.Dir = r.Dir
 	out, err := cmd.Output()
 	if err != nil {
-		return nil, err
+		return nil, ctxErr(ctx, err)
 	}
 	behindAhead := strings.Split(strings.TrimSuffix(string(out), "\n"), "\t")
 	b, err := strconv.ParseUint(behindAhead[0], 10, 0)
@@ -317,41 +350,118 @@ func (r *Repository) branchesBehindAhead(branch, base string) (*vcs.BehindAhead,
 }
 
 func (r *Repository) Tags() ([]*vcs.Tag, error) {
+	return r.TagsContext(context.Background())
+}
+
+func (r *Repository) TagsContext(ctx context.Context) ([]*vcs.Tag, error) {
 	r.editLock.RLock()
 	defer r.editLock.RUnlock()
 
-	refs, err := r.showRef("--tags")
+	cmd := exec.CommandContext(ctx, "git", "for-each-ref", "--format="+tagRefFormat, "refs/tags/")
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+	}
+	tags, nested, err := parseTagRefs(out)
 	if err != nil {
 		return nil, err
 	}
-
-	var tags []*vcs.Tag
-	for _, ref := range refs {
-		if strings.HasSuffix(ref[1], "^{}") {
-			// A dereferenced tag always follows the non-dereferenced one,
-			// and is shown with "^{}" appended. E.g.:
-			//
-			// 	3521017556c5de4159da4615a39fa4d5d2c279b5 refs/tags/v0.99.9c
-			// 	6ddc0964034342519a87fe013781abf31c6db6ad refs/tags/v0.99.9c^{}
-			// 	055e4ae3ae6eb344cbabf2a5256a49ea66040131 refs/tags/v1.0rc4
-			// 	423325a2d24638ddcc82ce47be5e40be550f4507 refs/tags/v1.0rc4^{}
-			//
-			// So override CommitID of previous tag to its dereferenced value.
-			tags[len(tags)-1].CommitID = vcs.CommitID(ref[0])
-			continue
+	if len(nested) > 0 {
+		if err := r.peelNestedTags(ctx, nested); err != nil {
+			return nil, err
 		}
-		tags = append(tags, &vcs.Tag{
-			Name:     strings.TrimPrefix(ref[1], "refs/tags/"),
-			CommitID: vcs.CommitID(ref[0]),
-		})
 	}
 	return tags, nil
 }
 
+// tagRefFormat is the git for-each-ref format of a tag that
+// parseTagRefs parses: its ref name, object ID and type, the ID and
+// type of the object that an annotated tag points to (the "*" fields),
+// and an annotated tag's tagger and message, each \x00-terminated.
+const tagRefFormat = "%(refname)%00%(objectname)%00%(objecttype)%00%(*objectname)%00%(*objecttype)%00" +
+	"%(taggername)%00%(taggeremail)%00%(taggerdate:raw)%00%(contents)%00"
+
+// parseTagRefs parses the output of git for-each-ref
+// --format=tagRefFormat. Tags of annotated tags need to be peeled
+// further, so it returns them in nested without setting their
+// CommitID.
+func parseTagRefs(out []byte) (tags, nested []*vcs.Tag, err error) {
+	const numFields = 9
+	fields := strings.Split(string(out), "\x00")
+	for len(fields) >= numFields {
+		f := fields[:numFields]
+		fields = fields[numFields:]
+
+		tag := &vcs.Tag{
+			Name:       strings.TrimPrefix(strings.TrimPrefix(f[0], "\n"), "refs/tags/"),
+			ObjectID:   f[1],
+			TargetType: f[2],
+		}
+		peeledID := f[1]
+		if f[2] == "tag" {
+			tag.TargetType = f[4]
+			peeledID = f[3]
+			if f[5] != "" || f[6] != "" || f[7] != "" {
+				tag.Tagger = &vcs.Signature{
+					Name:  f[5],
+					Email: strings.TrimSuffix(strings.TrimPrefix(f[6], "<"), ">"),
+				}
+				if dateFields := strings.Fields(f[7]); len(dateFields) > 0 {
+					secs, err := strconv.ParseInt(dateFields[0], 10, 64)
+					if err != nil {
+						return nil, nil, fmt.Errorf("unexpected tagger date in git for-each-ref output: %q", f[7])
+					}
+					tag.Tagger.Date = pbtypes.NewTimestamp(time.Unix(secs, 0))
+				}
+			}
+			tag.Message = f[8]
+		}
+		switch tag.TargetType {
+		case "commit":
+			tag.CommitID = vcs.CommitID(peeledID)
+		case "tag":
+			nested = append(nested, tag)
+		}
+		tags = append(tags, tag)
+	}
+	if len(fields) != 1 || strings.TrimSpace(fields[0]) != "" {
+		return nil, nil, fmt.Errorf("unexpected git for-each-ref output: %q", fields)
+	}
+	return tags, nested, nil
+}
+
+// peelNestedTags sets the CommitID of tags of annotated tags, which
+// git for-each-ref only peels once, with a single git cat-file
+// --batch-check. The caller must be holding r.editLock.
+func (r *Repository) peelNestedTags(ctx context.Context, tags []*vcs.Tag) error {
+	var in bytes.Buffer
+	for _, tag := range tags {
+		fmt.Fprintf(&in, "%s^{}\n", tag.ObjectID)
+	}
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "--batch-check=%(objectname) %(objecttype)")
+	cmd.Dir = r.Dir
+	cmd.Stdin = &in
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+	}
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if len(lines) != len(tags) {
+		return fmt.Errorf("unexpected git cat-file output: %q", out)
+	}
+	for i, line := range lines {
+		if id := strings.TrimSuffix(line, " commit"); id != line {
+			tags[i].CommitID = vcs.CommitID(id)
+		}
+	}
+	return nil
+}
+
 // showRef calls "git show-ref {filter} --dereference" and splits
 // the output by line. filter can be one of "--heads" or "--tags".
-func (r *Repository) showRef(filter string) ([][2]string, error) {
-	cmd := exec.Command("git", "show-ref", filter, "--dereference")
+func (r *Repository) showRef(ctx context.Context, filter string) ([][2]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "show-ref", filter, "--dereference")
 	cmd.Dir = r.Dir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -360,7 +470,7 @@ func (r *Repository) showRef(filter string) ([][2]string, error) {
 		if exitStatus(err) == 1 && len(out) == 0 {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("exec `git show-ref %s --dereference` in %s failed: %s. Output was:\n\n%s", filter, r.Dir, err, out)
+		return nil, ctxErr(ctx, fmt.Errorf("exec `git show-ref %s --dereference` in %s failed: %s. Output was:\n\n%s", filter, r.Dir, err, out))
 	}
 
 	out = bytes.TrimSuffix(out, []byte("\n")) // remove trailing newline
@@ -392,12 +502,12 @@ func exitStatus(err error) int {
 }
 
 // getCommit returns the commit with the given id. The caller must be holding r.editLock.
-func (r *Repository) getCommit(id vcs.CommitID) (*vcs.Commit, error) {
+func (r *Repository) getCommit(ctx context.Context, id vcs.CommitID) (*vcs.Commit, error) {
 	if err := checkSpecArgSafety(string(id)); err != nil {
 		return nil, err
 	}
 
-	commits, _, err := r.commitLog(vcs.CommitsOptions{Head: id, N: 1, NoTotal: true})
+	commits, _, err := r.commitLog(ctx, vcs.CommitsOptions{Head: id, N: 1, NoTotal: true})
 	if err != nil {
 		return nil, err
 	}
@@ -410,13 +520,21 @@ func (r *Repository) getCommit(id vcs.CommitID) (*vcs.Commit, error) {
 }
 
 func (r *Repository) GetCommit(id vcs.CommitID) (*vcs.Commit, error) {
+	return r.GetCommitContext(context.Background(), id)
+}
+
+func (r *Repository) GetCommitContext(ctx context.Context, id vcs.CommitID) (*vcs.Commit, error) {
 	r.editLock.RLock()
 	defer r.editLock.RUnlock()
 
-	return r.getCommit(id)
+	return r.getCommit(ctx, id)
 }
 
 func (r *Repository) Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
+	return r.CommitsContext(context.Background(), opt)
+}
+
+func (r *Repository) CommitsContext(ctx context.Context, opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
 	r.editLock.RLock()
 	defer r.editLock.RUnlock()
 
@@ -427,7 +545,7 @@ func (r *Repository) Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error
 		return nil, 0, err
 	}
 
-	return r.commitLog(opt)
+	return r.commitLog(ctx, opt)
 }
 
 func isBadObjectErr(output, obj string) bool {
@@ -438,12 +556,18 @@ func isInvalidRevisionRangeError(output, obj string) bool {
 	return strings.HasPrefix(output, "fatal: Invalid revision range "+obj)
 }
 
-// commitLog returns a list of commits, and total number of commits
-// starting from Head until Base or beginning of branch (unless NoTotal is true).
-//
-// The caller is responsible for doing checkSpecArgSafety on opt.Head and opt.Base.
-func (r *Repository) commitLog(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
-	args := []string{"log", `--format=format:%H%x00%aN%x00%aE%x00%at%x00%cN%x00%cE%x00%ct%x00%B%x00%P%x00`}
+// commitLogFormat is the `git log` format of the commits parsed by
+// parseCommitLogEntry.
+const commitLogFormat = `--format=format:%H%x00%aN%x00%aE%x00%at%x00%cN%x00%cE%x00%ct%x00%B%x00%P%x00`
+
+// commitLogPartsPerCommit is the number of \x00-separated fields per
+// commit in commitLogFormat.
+const commitLogPartsPerCommit = 9
+
+// commitLogArgs returns the `git log` arguments that list the commits
+// matching opt, and the revision range they use.
+func commitLogArgs(opt vcs.CommitsOptions) (args []string, rng string, err error) {
+	args = []string{"log", commitLogFormat}
 	if opt.N != 0 {
 		args = append(args, "-n", strconv.FormatUint(uint64(opt.N), 10))
 	}
@@ -457,8 +581,19 @@ func (r *Repository) commitLog(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, err
 		args = append(args, "--follow")
 	}
 
+	switch opt.Order {
+	case "":
+	case vcs.CommitsOrderDate:
+		args = append(args, "--date-order")
+	case vcs.CommitsOrderTopo:
+		args = append(args, "--topo-order")
+	default:
+		return nil, "", fmt.Errorf("unrecognized Order: %q", opt.Order)
+	}
+	args = append(args, commitFilterArgs(opt)...)
+
 	// Range
-	rng := string(opt.Head)
+	rng = string(opt.Head)
 	if opt.Base != "" {
 		rng = string(opt.Base) + ".." + string(opt.Head)
 	}
@@ -469,7 +604,66 @@ func (r *Repository) commitLog(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, err
 		args = append(args, opt.Path)
 	}
 
-	cmd := exec.Command("git", args...)
+	return args, rng, nil
+}
+
+// commitFilterArgs returns the `git log` arguments for the commit
+// filters in opt (other than Path).
+func commitFilterArgs(opt vcs.CommitsOptions) []string {
+	var args []string
+	// The Author, Committer and Message patterns are matched in Go
+	// (see internal.CommitPatterns), so git only prefilters the
+	// commits whose message contains a string that every match
+	// contains. --author and --committer can't prefilter because they
+	// may match the names before .mailmap is applied (unlike %aN).
+	if lit, foldCase := internal.RequiredLiteral(opt.Message); lit != "" {
+		args = append(args, "--grep="+lit, "--fixed-strings")
+		if foldCase {
+			args = append(args, "--regexp-ignore-case")
+		}
+	}
+	if !opt.Since.IsZero() {
+		args = append(args, "--since="+opt.Since.UTC().Format(time.RFC3339))
+	}
+	if !opt.Until.IsZero() {
+		args = append(args, "--until="+opt.Until.UTC().Format(time.RFC3339))
+	}
+	if opt.FirstParent {
+		args = append(args, "--first-parent")
+	}
+	if opt.Merges {
+		args = append(args, "--merges")
+	}
+	if opt.NoMerges {
+		args = append(args, "--no-merges")
+	}
+	return args
+}
+
+// commitLog returns a list of commits, and total number of commits
+// starting from Head until Base or beginning of branch (unless NoTotal is true).
+//
+// The caller is responsible for doing checkSpecArgSafety on opt.Head and opt.Base.
+func (r *Repository) commitLog(ctx context.Context, opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
+	if opt.Author != "" || opt.Committer != "" || opt.Message != "" {
+		// The patterns are matched in Go, so count the matching
+		// commits while listing them instead of with git rev-list.
+		all := opt
+		all.N, all.Skip = 0, 0
+		it, err := r.walkCommits(ctx, all)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer it.Close()
+		return internal.ListCommits(it, opt)
+	}
+
+	args, rng, err := commitLogArgs(opt)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cmd := exec.CommandContext(ctx, "git", args...)
 	cmd.Dir = r.Dir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -477,51 +671,25 @@ func (r *Repository) commitLog(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, err
 		if isBadObjectErr(string(out), string(opt.Head)) {
 			return nil, 0, vcs.ErrCommitNotFound
 		}
-		return nil, 0, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out)
+		return nil, 0, ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
 	}
 
-	const partsPerCommit = 9 // number of \x00-separated fields per commit
 	allParts := bytes.Split(out, []byte{'\x00'})
-	numCommits := len(allParts) / partsPerCommit
+	numCommits := len(allParts) / commitLogPartsPerCommit
 	commits := make([]*vcs.Commit, numCommits)
 	for i := 0; i < numCommits; i++ {
-		parts := allParts[partsPerCommit*i : partsPerCommit*(i+1)]
-
-		// log outputs are newline separated, so all but the 1st commit ID part
-		// has an erroneous leading newline.
-		parts[0] = bytes.TrimPrefix(parts[0], []byte{'\n'})
-
-		authorTime, err := strconv.ParseInt(string(parts[3]), 10, 64)
-		if err != nil {
-			return nil, 0, fmt.Errorf("parsing git commit author time: %s", err)
-		}
-		committerTime, err := strconv.ParseInt(string(parts[6]), 10, 64)
+		commits[i], err = parseCommitLogEntry(allParts[commitLogPartsPerCommit*i : commitLogPartsPerCommit*(i+1)])
 		if err != nil {
-			return nil, 0, fmt.Errorf("parsing git commit committer time: %s", err)
-		}
-
-		var parents []vcs.CommitID
-		if parentPart := parts[8]; len(parentPart) > 0 {
-			parentIDs := bytes.Split(parentPart, []byte{' '})
-			parents = make([]vcs.CommitID, len(parentIDs))
-			for i, id := range parentIDs {
-				parents[i] = vcs.CommitID(id)
-			}
-		}
-
-		commits[i] = &vcs.Commit{
-			ID:        vcs.CommitID(parts[0]),
-			Author:    vcs.Signature{string(parts[1]), string(parts[2]), pbtypes.NewTimestamp(time.Unix(authorTime, 0))},
-			Committer: &vcs.Signature{string(parts[4]), string(parts[5]), pbtypes.NewTimestamp(time.Unix(committerTime, 0))},
-			Message:   string(bytes.TrimSuffix(parts[7], []byte{'\n'})),
-			Parents:   parents,
+			return nil, 0, err
 		}
 	}
 
 	// Count commits.
 	var total uint
 	if !opt.NoTotal {
-		cmd = exec.Command("git", "rev-list", "--count", rng)
+		cmd = exec.CommandContext(ctx, "git", "rev-list", "--count")
+		cmd.Args = append(cmd.Args, commitFilterArgs(opt)...)
+		cmd.Args = append(cmd.Args, rng)
 		if opt.Path != "" {
 			// This doesn't include --follow flag because rev-list doesn't support it, so the number may be slightly off.
 			cmd.Args = append(cmd.Args, "--", opt.Path)
@@ -529,7 +697,7 @@ func (r *Repository) commitLog(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, err
 		cmd.Dir = r.Dir
 		out, err = cmd.CombinedOutput()
 		if err != nil {
-			return nil, 0, fmt.Errorf("exec `git rev-list --count` failed: %s. Output was:\n\n%s", err, out)
+			return nil, 0, ctxErr(ctx, fmt.Errorf("exec `git rev-list --count` failed: %s. Output was:\n\n%s", err, out))
 		}
 		out = bytes.TrimSpace(out)
 		total, err = parseUint(string(out))
@@ -541,430 +709,2270 @@ func (r *Repository) commitLog(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, err
 	return commits, total, nil
 }
 
-func parseUint(s string) (uint, error) {
-	n, err := strconv.ParseUint(s, 10, 64)
-	return uint(n), err
+func (r *Repository) WalkCommits(opt vcs.CommitsOptions) (vcs.CommitIterator, error) {
+	return r.WalkCommitsContext(context.Background(), opt)
 }
 
-func (r *Repository) Diff(base, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.Diff, error) {
+func (r *Repository) WalkCommitsContext(ctx context.Context, opt vcs.CommitsOptions) (vcs.CommitIterator, error) {
 	r.editLock.RLock()
 	defer r.editLock.RUnlock()
 
-	if strings.HasPrefix(string(base), "-") || strings.HasPrefix(string(head), "-") {
-		// Protect against base or head that is interpreted as command-line option.
-		return nil, errors.New("diff revspecs must not start with '-'")
-	}
-
-	if opt == nil {
-		opt = &vcs.DiffOptions{}
-	}
-	args := []string{"diff", "--full-index"}
-	if opt.ContextLines != 0 {
-		args = append(args, fmt.Sprintf("-U%d", opt.ContextLines))
+	if err := checkSpecArgSafety(string(opt.Head)); err != nil {
+		return nil, err
 	}
-	if opt.DetectRenames {
-		args = append(args, "-M")
+	if err := checkSpecArgSafety(string(opt.Base)); err != nil {
+		return nil, err
 	}
-	args = append(args, "--src-prefix="+opt.OrigPrefix)
-	args = append(args, "--dst-prefix="+opt.NewPrefix)
 
-	rng := string(base)
-	if opt.ExcludeReachableFromBoth {
-		rng += "..." + string(head)
-	} else {
-		rng += ".." + string(head)
-	}
+	return r.walkCommits(ctx, opt)
+}
 
-	args = append(args, rng, "--")
-	cmd := exec.Command("git", args...)
-	if opt != nil {
-		cmd.Args = append(cmd.Args, opt.Paths...)
-	}
-	cmd.Dir = r.Dir
-	out, err := cmd.CombinedOutput()
+// walkCommits returns an iterator over the commits matching opt.
+//
+// The caller is responsible for doing checkSpecArgSafety on opt.Head and opt.Base.
+func (r *Repository) walkCommits(ctx context.Context, opt vcs.CommitsOptions) (vcs.CommitIterator, error) {
+	patterns, err := internal.CompileCommitPatterns(opt)
 	if err != nil {
-		out = bytes.TrimSpace(out)
-		if isBadObjectErr(string(out), string(base)) || isBadObjectErr(string(out), string(head)) || isInvalidRevisionRangeError(string(out), string(base)) || isInvalidRevisionRangeError(string(out), string(head)) {
-			return nil, vcs.ErrCommitNotFound
-		}
-		return nil, fmt.Errorf("exec `git diff` failed: %s. Output was:\n\n%s", err, out)
+		return nil, err
 	}
-	return &vcs.Diff{
-		Raw: string(out),
-	}, nil
-}
-
-// A CrossRepo is a git repository that can be used in cross-repo
-// operations (e.g., as the head repository for a cross-repo diff in
-// another git repository's CrossRepoDiff method, or as the 2nd repo
-// in a CrossRepoMergeBase call).
-type CrossRepo interface {
-	GitRootDir() string // the repo's root directory
-}
-
-func (r *Repository) GitRootDir() string { return r.Dir }
-
-func (r *Repository) CrossRepoDiff(base vcs.CommitID, headRepo vcs.Repository, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.Diff, error) {
-	var headDir string // path to head repo on local filesystem
-	if headRepo, ok := headRepo.(CrossRepo); ok {
-		headDir = headRepo.GitRootDir()
-	} else {
-		return nil, fmt.Errorf("git cross-repo diff not supported against head repo type %T", headRepo)
+	all := opt
+	if patterns != nil {
+		// git only prefilters the commits (see commitFilterArgs), so
+		// N and Skip apply to the commits that match in Go.
+		all.N, all.Skip = 0, 0
 	}
-
-	if headDir == r.Dir {
-		return r.Diff(base, head, opt)
+	args, _, err := commitLogArgs(all)
+	if err != nil {
+		return nil, err
 	}
-
-	if err := r.fetchRemote(headDir); err != nil {
+	it, err := r.startCommitLog(ctx, args, opt.Head, false)
+	if err != nil {
 		return nil, err
 	}
-
-	return r.Diff(base, head, opt)
+	if patterns == nil {
+		return it, nil
+	}
+	return internal.FilterCommits(it, patterns.Match, opt.Skip, opt.N), nil
 }
 
-func (r *Repository) fetchRemote(repoDir string) error {
-	r.editLock.Lock()
-	defer r.editLock.Unlock()
-
-	name := base64.URLEncoding.EncodeToString([]byte(repoDir))
-
-	// Fetch remote commit data.
-	cmd := exec.Command("git", "fetch", "-v", filepath.ToSlash(repoDir), "+refs/heads/*:refs/remotes/"+name+"/*")
+// startCommitLog starts `git log` with args (which must use
+// commitLogFormat) and returns an iterator over its output. Errors
+// about a nonexistent head are reported as vcs.ErrCommitNotFound, or,
+// if the log was started from a cursor, as vcs.ErrInvalidCursor.
+func (r *Repository) startCommitLog(ctx context.Context, args []string, head vcs.CommitID, fromCursor bool) (*commitIterator, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
 	cmd.Dir = r.Dir
-	out, err := cmd.CombinedOutput()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
 	if err != nil {
-		return fmt.Errorf("exec %v in %s failed: %s. Output was:\n\n%s", cmd.Args, cmd.Dir, err, out)
+		return nil, err
 	}
-	return nil
+	if err := cmd.Start(); err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+
+	it := &commitIterator{ctx: ctx, cmd: cmd, stderr: &stderr, rd: bufio.NewReader(out), head: head, fromCursor: fromCursor}
+	if _, err := it.rd.Peek(1); err == io.EOF {
+		// git log exited without output, either because no commits
+		// matched or because it failed (e.g., head doesn't exist).
+		// Report the latter here instead of from Err.
+		it.finish(nil)
+		if it.err != nil {
+			return nil, it.err
+		}
+	}
+	return it, nil
 }
 
-func (r *Repository) UpdateEverything(opt vcs.RemoteOpts) (*vcs.UpdateResult, error) {
-	r.editLock.Lock()
-	defer r.editLock.Unlock()
+// commitIterator reads commits from the output of a running `git log`
+// process, one at a time.
+type commitIterator struct {
+	ctx    context.Context
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+	rd     *bufio.Reader
 
-	cmd := exec.Command("git", "remote", "update", "--prune")
-	cmd.Dir = r.Dir
+	head       vcs.CommitID
+	fromCursor bool   // whether the heads came from a CommitsPage cursor
+	nextID     []byte // the next commit's ID, if nextDiff read it
 
-	if opt.SSH != nil {
-		gitSSHWrapper, gitSSHWrapperDir, keyFile, err := makeGitSSHWrapper(opt.SSH.PrivateKey)
-		defer func() {
-			if keyFile != "" {
-				if err := os.Remove(keyFile); err != nil {
-					log.Fatalf("Error removing SSH key file %s: %s.", keyFile, err)
-				}
-			}
-		}()
-		if err != nil {
-			return nil, err
+	done bool // whether cmd has exited
+	err  error
+}
+
+func (it *commitIterator) Next() *vcs.Commit {
+	if it.done {
+		return nil
+	}
+	parts := make([][]byte, commitLogPartsPerCommit)
+	for i := range parts {
+		part, err := it.rd.ReadBytes('\x00')
+		if err == io.EOF && i == 0 && len(bytes.TrimSpace(part)) == 0 {
+			it.finish(nil)
+			return nil
 		}
-		defer os.Remove(gitSSHWrapper)
-		if gitSSHWrapperDir != "" {
-			defer os.RemoveAll(gitSSHWrapperDir)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			it.finish(err)
+			return nil
 		}
-		cmd.Env = []string{"GIT_SSH=" + gitSSHWrapper}
+		parts[i] = part[:len(part)-1]
 	}
+	c, err := parseCommitLogEntry(parts)
+	if err != nil {
+		it.finish(err)
+		return nil
+	}
+	return c
+}
 
-	if opt.HTTPS != nil {
-		env := environ(os.Environ())
-		env.Unset("GIT_TERMINAL_PROMPT")
-
-		gitPassHelper, gitPassHelperDir, err := makeGitPassHelper(opt.HTTPS.Pass)
-		if err != nil {
-			return nil, err
+// nextDiff reads the next commit and its diff from the output of `git
+// log` with commitLogFormat and a diff option (see
+// parseCommitLogDiffs). It returns a nil commit if there are no more
+// commits or an error occurred.
+func (it *commitIterator) nextDiff() (*vcs.Commit, []byte) {
+	if it.done {
+		return nil, nil
+	}
+	parts := make([][]byte, commitLogPartsPerCommit)
+	i := 0
+	if it.nextID != nil {
+		parts[0], it.nextID = it.nextID, nil
+		i = 1
+	}
+	for ; i < len(parts); i++ {
+		part, err := it.rd.ReadBytes('\x00')
+		if err == io.EOF && i == 0 && len(bytes.TrimSpace(part)) == 0 {
+			it.finish(nil)
+			return nil, nil
 		}
-		defer os.Remove(gitPassHelper)
-		if gitPassHelperDir != "" {
-			defer os.RemoveAll(gitPassHelperDir)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			it.finish(err)
+			return nil, nil
 		}
-		env = append(env, "GIT_ASKPASS="+gitPassHelper)
-
-		cmd.Env = env
+		parts[i] = part[:len(part)-1]
 	}
+	c, err := parseCommitLogEntry(parts)
+	if err != nil {
+		it.finish(err)
+		return nil, nil
+	}
+
+	// The diff is in the same field as the next commit's first field
+	// (which contains no newlines).
+	diff, err := it.rd.ReadBytes('\x00')
+	switch {
+	case err == io.EOF:
+		it.finish(nil)
+	case err != nil:
+		it.finish(err)
+		return nil, nil
+	default:
+		diff = diff[:len(diff)-1]
+		i := bytes.LastIndexByte(diff, '\n')
+		diff, it.nextID = diff[:i+1], diff[i+1:]
+	}
+	if it.err != nil {
+		return nil, nil
+	}
+	return c, bytes.TrimSuffix(diff, []byte("\n"))
+}
 
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-	err := cmd.Run()
+// finish waits for the git log process to exit and records the first
+// error that occurred, if any. If err is non-nil, the process is
+// killed first because it may be blocked writing output that will
+// never be read.
+func (it *commitIterator) finish(err error) {
+	it.done = true
 	if err != nil {
-		return nil, fmt.Errorf("exec `git remote update` failed: %v. Stderr was:\n\n%s", err, stderr.String())
+		it.cmd.Process.Kill()
+	}
+	if waitErr := it.cmd.Wait(); err == nil && waitErr != nil {
+		out := bytes.TrimSpace(it.stderr.Bytes())
+		switch {
+		case it.fromCursor && bytes.HasPrefix(out, []byte("fatal: bad object ")):
+			err = vcs.ErrInvalidCursor
+		case isBadObjectErr(string(out), string(it.head)), bytes.HasPrefix(out, []byte("fatal: Invalid revision range ")):
+			err = vcs.ErrCommitNotFound
+		default:
+			err = fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", it.cmd.Args, waitErr, out)
+		}
 	}
-	result, err := parseRemoteUpdate(stderr.Bytes())
 	if err != nil {
-		return nil, fmt.Errorf("parsing output of `git remote update` failed: %v", err)
+		it.err = ctxErr(it.ctx, err)
 	}
-	return &result, nil
 }
 
-func (r *Repository) BlameFile(path string, opt *vcs.BlameOptions) ([]*vcs.Hunk, error) {
-	r.editLock.RLock()
-	defer r.editLock.RUnlock()
-
-	if opt == nil {
-		opt = &vcs.BlameOptions{}
+// nextPathCommit returns the next commit in the output of `git log
+// --format=%H -z` (with --name-status, if it follows renames) that
+// started with path, or "" if there are no more commits or an error
+// occurred. If the commit renamed path, it also returns path's old
+// name.
+func (it *commitIterator) nextPathCommit(path string) (id vcs.CommitID, from string) {
+	if it.done {
+		return "", ""
+	}
+	readField := func() (string, bool) {
+		b, err := it.rd.ReadBytes('\x00')
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			it.finish(err)
+			return "", false
+		}
+		return string(b[:len(b)-1]), true
 	}
-	if opt.OldestCommit != "" {
-		return nil, fmt.Errorf("OldestCommit not implemented")
+	if _, err := it.rd.Peek(1); err == io.EOF {
+		it.finish(nil)
+		return "", ""
 	}
-	if err := checkSpecArgSafety(string(opt.NewestCommit)); err != nil {
+	s, ok := readField()
+	if !ok {
+		return "", ""
+	}
+	id = vcs.CommitID(strings.TrimSpace(s))
+	// The --name-status entries start with "\n" and the status.
+	for {
+		if b, err := it.rd.Peek(1); err != nil || b[0] != '\n' {
+			break
+		}
+		status, ok := readField()
+		if !ok {
+			return "", ""
+		}
+		names := make([]string, 1)
+		if status = strings.TrimSpace(status); strings.HasPrefix(status, "R") || strings.HasPrefix(status, "C") {
+			names = make([]string, 2) // old and new name
+		}
+		for i := range names {
+			if names[i], ok = readField(); !ok {
+				return "", ""
+			}
+		}
+		if len(names) == 2 && names[1] == path {
+			from = names[0]
+		}
+	}
+	return id, from
+}
+
+func (it *commitIterator) Err() error { return it.err }
+
+func (it *commitIterator) Close() error {
+	if it.done {
+		return nil
+	}
+	it.done = true
+	it.cmd.Process.Kill()
+	it.cmd.Wait() // the exit status of a killed process is meaningless
+	return nil
+}
+
+func (r *Repository) CommitsPage(opt vcs.CommitsOptions) ([]*vcs.Commit, string, error) {
+	return r.CommitsPageContext(context.Background(), opt)
+}
+
+// CommitsPageContext resumes the revision walk of `git log` from the
+// walk state that the cursor records (see commitsCursor). The filters
+// that don't affect the walk (such as Author) are applied to the
+// commits it visits, instead of by `git log`, because the walk state
+// must be computed from all of them. When opt.Path is set, a second
+// `git log` with the path runs alongside the walk to select the
+// commits that Commits would return, which are a subsequence of the
+// commits that the walk visits.
+//
+// If the history has clock skew, a page may be computed by walking
+// from opt.Head again, so it takes as long as paging with opt.Skip.
+func (r *Repository) CommitsPageContext(ctx context.Context, opt vcs.CommitsOptions) ([]*vcs.Commit, string, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	if err := checkSpecArgSafety(string(opt.Head)); err != nil {
+		return nil, "", err
+	}
+	if err := checkSpecArgSafety(string(opt.Base)); err != nil {
+		return nil, "", err
+	}
+	if opt.Order != "" {
+		// Only the default order visits commits in walk order.
+		return nil, "", fmt.Errorf("CommitsPage: unsupported Order: %q", opt.Order)
+	}
+	match, err := commitMatcher(opt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var cur *commitsCursor
+	if opt.Cursor != "" {
+		cur, err = parseCommitsCursor(opt.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		opt.Skip = 0
+	}
+
+	commits, next, err := r.commitsPage(ctx, opt, match, cur)
+	if err == errCommitsRevisit {
+		// The resumed walk would have visited commits again, so
+		// redo it from opt.Head.
+		commits, next, err = r.commitsPage(ctx, opt, match, &commitsCursor{Pos: cur.Pos})
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	var nextCursor string
+	if next != nil {
+		nextCursor = next.String()
+	}
+	return commits, nextCursor, nil
+}
+
+// errCommitsRevisit is returned by commitsPage when it can't tell
+// whether the resumed walk visits a commit for the first time.
+var errCommitsRevisit = errors.New("resumed revision walk revisits commits")
+
+// commitsPage returns a page of commits and the cursor of the next
+// page (or nil if there are no more commits). If cur has no Queue (or
+// is nil), it starts the walk from opt.Head and skips the first
+// cur.Pos commits that the walk visits; otherwise it resumes the walk
+// from cur.
+func (r *Repository) commitsPage(ctx context.Context, opt vcs.CommitsOptions, match func(*vcs.Commit) bool, cur *commitsCursor) ([]*vcs.Commit, *commitsCursor, error) {
+	resume := cur != nil && len(cur.Queue) > 0
+	follow := opt.Path != "" && !strings.HasPrefix(opt.Path, ":(glob)")
+
+	// seen holds the commit dates of the visited commits (only those
+	// in cur.Seen before this page, if resuming).
+	seen := make(map[vcs.CommitID]int64)
+	next := &commitsCursor{}
+	revs := []string{string(opt.Head)}
+	path := opt.Path
+	var skipPos uint
+	if resume {
+		for id, date := range cur.Seen {
+			seen[id] = date
+		}
+		next.Pos = cur.Pos
+		revs = make([]string, len(cur.Queue))
+		for i, id := range cur.Queue {
+			revs[i] = string(id)
+		}
+		if cur.Path != "" {
+			path = cur.Path
+		}
+	} else if cur != nil {
+		skipPos = cur.Pos
+	}
+	if opt.Base != "" {
+		revs = append(revs, "^"+string(opt.Base))
+	}
+	var walkArgs []string
+	if opt.FirstParent {
+		walkArgs = append(walkArgs, "--first-parent")
+	}
+	if !opt.Since.IsZero() {
+		// Unlike the other filters, --since changes the walk: git log
+		// doesn't visit the parents of older commits.
+		walkArgs = append(walkArgs, "--since="+opt.Since.UTC().Format(time.RFC3339))
+	}
+
+	// With --sparse, git log lists all commits that the walk visits
+	// with the path (whose history it simplifies), not only those
+	// that modify it. --follow doesn't simplify history.
+	args := append([]string{"log", commitLogFormat}, walkArgs...)
+	if path != "" && !follow {
+		args = append(args, "--sparse")
+	}
+	args = append(append(args, revs...), "--")
+	if path != "" && !follow {
+		args = append(args, path)
+	}
+	walk, err := r.startCommitLog(ctx, args, opt.Head, resume)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer walk.Close()
+
+	var paths *commitIterator
+	var pathID vcs.CommitID // the next commit that modifies path
+	var pathFrom string     // the name of path before pathID renamed it
+	if path != "" {
+		args := append([]string{"log", "--format=%H", "-z"}, walkArgs...)
+		if follow {
+			args = append(args, "--follow", "--name-status")
+		}
+		args = append(append(args, revs...), "--", path)
+		paths, err = r.startCommitLog(ctx, args, opt.Head, resume)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer paths.Close()
+		pathID, pathFrom = paths.nextPathCommit(path)
+	}
+
+	var commits []*vcs.Commit
+	var queue []vcs.CommitID
+	inQueue := make(map[vcs.CommitID]bool)
+	if resume {
+		queue = cur.Queue
+		for _, id := range queue {
+			inQueue[id] = true
+		}
+	}
+	// childDate holds the least commit date of the children of each
+	// commit that the resumed walk visited.
+	childDate := make(map[vcs.CommitID]int64)
+	more := false
+	for {
+		c := walk.Next()
+		if c == nil {
+			break
+		}
+		date := c.Committer.Date.Seconds
+		parents := c.Parents
+		if opt.FirstParent && len(parents) > 1 {
+			parents = parents[:1]
+		}
+		if resume {
+			for _, p := range parents {
+				if d, ok := childDate[p]; !ok || date < d {
+					childDate[p] = date
+				}
+			}
+		}
+		if _, ok := seen[c.ID]; ok {
+			// A commit in cur.Seen, visited again.
+			if c.ID == pathID {
+				pathID, pathFrom = paths.nextPathCommit(path)
+			}
+			continue
+		}
+		if d, ok := childDate[c.ID]; ok && date > d {
+			// c is newer than one of its children, so it may have
+			// been visited before without being in cur.Seen (see
+			// commitsCursor).
+			return nil, nil, errCommitsRevisit
+		}
+		if opt.N != 0 && uint(len(commits)) == opt.N {
+			// c is the first commit of the next page, and the
+			// newest commit in the walk queue.
+			more = true
+			next.Date = date
+			break
+		}
+
+		next.Pos++
+		seen[c.ID] = date
+		delete(inQueue, c.ID)
+		for _, p := range parents {
+			if _, ok := seen[p]; !ok && !inQueue[p] {
+				queue = append(queue, p)
+				inQueue[p] = true
+			}
+		}
+
+		ok := true
+		if paths != nil {
+			ok = c.ID == pathID
+			if ok {
+				if pathFrom != "" {
+					path = pathFrom
+				}
+				pathID, pathFrom = paths.nextPathCommit(path)
+			}
+		}
+		if next.Pos <= skipPos || !ok || (match != nil && !match(c)) {
+			continue
+		}
+		if opt.Skip > 0 {
+			opt.Skip--
+			continue
+		}
+		commits = append(commits, c)
+	}
+	if err := walk.Err(); err != nil {
+		return nil, nil, err
+	}
+	if paths != nil {
+		if err := paths.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if !more {
+		return commits, nil, nil
+	}
+
+	for _, id := range queue {
+		if inQueue[id] {
+			next.Queue = append(next.Queue, id)
+		}
+	}
+	next.Seen = make(map[vcs.CommitID]int64)
+	for id, date := range seen {
+		if date <= next.Date {
+			next.Seen[id] = date
+		}
+	}
+	if path != opt.Path {
+		next.Path = path
+	}
+	return commits, next, nil
+}
+
+// commitMatcher returns a func that reports whether a commit matches
+// the filters in opt that `git log` applies to the commits that it
+// visits (as opposed to those that change which commits it visits),
+// or nil if there are no such filters.
+func commitMatcher(opt vcs.CommitsOptions) (func(*vcs.Commit) bool, error) {
+	patterns, err := internal.CompileCommitPatterns(opt)
+	if err != nil {
+		return nil, err
+	}
+	if patterns == nil && opt.Since.IsZero() && opt.Until.IsZero() && !opt.Merges && !opt.NoMerges {
+		return nil, nil
+	}
+
+	return func(c *vcs.Commit) bool {
+		switch {
+		case patterns != nil && !patterns.Match(c):
+			return false
+		case !opt.Since.IsZero() && c.Committer.Date.Time().Before(opt.Since):
+			return false
+		case !opt.Until.IsZero() && c.Committer.Date.Time().After(opt.Until):
+			return false
+		case opt.Merges && len(c.Parents) < 2:
+			return false
+		case opt.NoMerges && len(c.Parents) > 1:
+			return false
+		}
+		return true
+	}, nil
+}
+
+// A commitsCursor is the state of a CommitsPage revision walk after
+// it visited Pos commits. Queue lists the commits that the walk visits
+// next (by descending commit date), in the order that it added them
+// to its queue, which breaks ties. Date is the greatest commit date in
+// Queue. Path is the name of the followed path, if it was renamed.
+//
+// A walk resumed from Queue would visit the commits that are
+// reachable from Queue again, but without clock skew (commits that are
+// newer than their children), these commits are not newer than Date.
+// So Seen lists the commit dates of the visited commits that are not
+// newer than Date, which the resumed walk skips, and the resumed walk
+// is redone from the head if it visits a commit that is newer than
+// one of its children.
+type commitsCursor struct {
+	Pos   uint
+	Queue []vcs.CommitID
+	Date  int64
+	Seen  map[vcs.CommitID]int64 `json:",omitempty"`
+	Path  string                 `json:",omitempty"`
+}
+
+// String encodes the cursor as an opaque string.
+func (c *commitsCursor) String() string {
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// parseCommitsCursor decodes a cursor encoded by
+// (*commitsCursor).String.
+func parseCommitsCursor(cursor string) (*commitsCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, vcs.ErrInvalidCursor
+	}
+	var c commitsCursor
+	if err := json.Unmarshal(b, &c); err != nil || len(c.Queue) == 0 {
+		return nil, vcs.ErrInvalidCursor
+	}
+	isCommitID := func(id vcs.CommitID) bool {
+		return len(id) == 40 && strings.Trim(string(id), "0123456789abcdef") == ""
+	}
+	for _, id := range c.Queue {
+		if !isCommitID(id) {
+			return nil, vcs.ErrInvalidCursor
+		}
+	}
+	for id := range c.Seen {
+		if !isCommitID(id) {
+			return nil, vcs.ErrInvalidCursor
+		}
+	}
+	return &c, nil
+}
+
+// parseCommitLogEntry parses the commitLogPartsPerCommit fields of a
+// commit in the output of `git log` with commitLogFormat.
+func parseCommitLogEntry(parts [][]byte) (*vcs.Commit, error) {
+	// log outputs are newline separated, so all but the 1st commit ID part
+	// has an erroneous leading newline.
+	parts[0] = bytes.TrimPrefix(parts[0], []byte{'\n'})
+
+	authorTime, err := strconv.ParseInt(string(parts[3]), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parsing git commit author time: %s", err)
+	}
+	committerTime, err := strconv.ParseInt(string(parts[6]), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parsing git commit committer time: %s", err)
+	}
+
+	var parents []vcs.CommitID
+	if parentPart := parts[8]; len(parentPart) > 0 {
+		parentIDs := bytes.Split(parentPart, []byte{' '})
+		parents = make([]vcs.CommitID, len(parentIDs))
+		for i, id := range parentIDs {
+			parents[i] = vcs.CommitID(id)
+		}
+	}
+
+	return &vcs.Commit{
+		ID:        vcs.CommitID(parts[0]),
+		Author:    vcs.Signature{string(parts[1]), string(parts[2]), pbtypes.NewTimestamp(time.Unix(authorTime, 0))},
+		Committer: &vcs.Signature{string(parts[4]), string(parts[5]), pbtypes.NewTimestamp(time.Unix(committerTime, 0))},
+		Message:   string(bytes.TrimSuffix(parts[7], []byte{'\n'})),
+		Parents:   parents,
+	}, nil
+}
+
+func parseUint(s string) (uint, error) {
+	n, err := strconv.ParseUint(s, 10, 64)
+	return uint(n), err
+}
+
+func (r *Repository) Diff(base, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.Diff, error) {
+	return r.DiffContext(context.Background(), base, head, opt)
+}
+
+func (r *Repository) DiffContext(ctx context.Context, base, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.Diff, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	if strings.HasPrefix(string(base), "-") || strings.HasPrefix(string(head), "-") {
+		// Protect against base or head that is interpreted as command-line option.
+		return nil, errors.New("diff revspecs must not start with '-'")
+	}
+
+	if opt == nil {
+		opt = &vcs.DiffOptions{}
+	}
+	args := []string{"diff", "--full-index"}
+	if opt.ContextLines != 0 {
+		args = append(args, fmt.Sprintf("-U%d", opt.ContextLines))
+	}
+	if opt.DetectRenames {
+		args = append(args, "-M")
+	}
+	args = append(args, "--src-prefix="+opt.OrigPrefix)
+	args = append(args, "--dst-prefix="+opt.NewPrefix)
+
+	rng := string(base)
+	if opt.ExcludeReachableFromBoth {
+		rng += "..." + string(head)
+	} else {
+		rng += ".." + string(head)
+	}
+
+	args = append(args, rng, "--")
+	cmd := exec.CommandContext(ctx, "git", args...)
+	if opt != nil {
+		cmd.Args = append(cmd.Args, opt.Paths...)
+	}
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		out = bytes.TrimSpace(out)
+		if isBadObjectErr(string(out), string(base)) || isBadObjectErr(string(out), string(head)) || isInvalidRevisionRangeError(string(out), string(base)) || isInvalidRevisionRangeError(string(out), string(head)) {
+			return nil, vcs.ErrCommitNotFound
+		}
+		return nil, ctxErr(ctx, fmt.Errorf("exec `git diff` failed: %s. Output was:\n\n%s", err, out))
+	}
+	files, err := internal.ParseDiff(out, opt.OrigPrefix, opt.NewPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return &vcs.Diff{
+		Raw:   string(out),
+		Files: files,
+	}, nil
+}
+
+// A CrossRepo is a git repository that can be used in cross-repo
+// operations (e.g., as the head repository for a cross-repo diff in
+// another git repository's CrossRepoDiff method, or as the 2nd repo
+// in a CrossRepoMergeBase call).
+type CrossRepo interface {
+	GitRootDir() string // the repo's root directory
+}
+
+func (r *Repository) GitRootDir() string { return r.Dir }
+
+func (r *Repository) CrossRepoDiff(base vcs.CommitID, headRepo vcs.Repository, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.Diff, error) {
+	return r.CrossRepoDiffContext(context.Background(), base, headRepo, head, opt)
+}
+
+func (r *Repository) CrossRepoDiffContext(ctx context.Context, base vcs.CommitID, headRepo vcs.Repository, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.Diff, error) {
+	var headDir string // path to head repo on local filesystem
+	if headRepo, ok := headRepo.(CrossRepo); ok {
+		headDir = headRepo.GitRootDir()
+	} else {
+		return nil, fmt.Errorf("git cross-repo diff not supported against head repo type %T", headRepo)
+	}
+
+	if headDir == r.Dir {
+		return r.DiffContext(ctx, base, head, opt)
+	}
+
+	if err := r.fetchRemote(ctx, headDir); err != nil {
+		return nil, err
+	}
+
+	return r.DiffContext(ctx, base, head, opt)
+}
+
+func (r *Repository) fetchRemote(ctx context.Context, repoDir string) error {
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+
+	name := base64.URLEncoding.EncodeToString([]byte(repoDir))
+
+	// Fetch remote commit data.
+	cmd := exec.CommandContext(ctx, "git", "fetch", "-v", filepath.ToSlash(repoDir), "+refs/heads/*:refs/remotes/"+name+"/*")
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return ctxErr(ctx, fmt.Errorf("exec %v in %s failed: %s. Output was:\n\n%s", cmd.Args, cmd.Dir, err, out))
+	}
+	return nil
+}
+
+func (r *Repository) UpdateEverything(opt vcs.RemoteOpts) (*vcs.UpdateResult, error) {
+	return r.UpdateEverythingContext(context.Background(), opt)
+}
+
+func (r *Repository) UpdateEverythingContext(ctx context.Context, opt vcs.RemoteOpts) (*vcs.UpdateResult, error) {
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+
+	cmd := exec.CommandContext(ctx, "git", "remote", "update", "--prune")
+	cmd.Dir = r.Dir
+
+	if opt.SSH != nil {
+		gitSSHWrapper, gitSSHWrapperDir, keyFile, err := makeGitSSHWrapper(opt.SSH.PrivateKey)
+		defer func() {
+			if keyFile != "" {
+				if err := os.Remove(keyFile); err != nil {
+					log.Fatalf("Error removing SSH key file %s: %s.", keyFile, err)
+				}
+			}
+		}()
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(gitSSHWrapper)
+		if gitSSHWrapperDir != "" {
+			defer os.RemoveAll(gitSSHWrapperDir)
+		}
+		cmd.Env = []string{"GIT_SSH=" + gitSSHWrapper}
+	}
+
+	if opt.HTTPS != nil {
+		env := environ(os.Environ())
+		env.Unset("GIT_TERMINAL_PROMPT")
+
+		gitPassHelper, gitPassHelperDir, err := makeGitPassHelper(opt.HTTPS.Pass)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(gitPassHelper)
+		if gitPassHelperDir != "" {
+			defer os.RemoveAll(gitPassHelperDir)
+		}
+		env = append(env, "GIT_ASKPASS="+gitPassHelper)
+
+		cmd.Env = env
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return nil, ctxErr(ctx, fmt.Errorf("exec `git remote update` failed: %v. Stderr was:\n\n%s", err, stderr.String()))
+	}
+	result, err := parseRemoteUpdate(stderr.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("parsing output of `git remote update` failed: %v", err)
+	}
+	return &result, nil
+}
+
+func (r *Repository) FileHistory(path string, head vcs.CommitID, opt *vcs.FileHistoryOptions) ([]*vcs.FileHistoryEntry, error) {
+	return r.FileHistoryContext(context.Background(), path, head, opt)
+}
+
+func (r *Repository) FileHistoryContext(ctx context.Context, path string, head vcs.CommitID, opt *vcs.FileHistoryOptions) ([]*vcs.FileHistoryEntry, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	if opt == nil {
+		opt = &vcs.FileHistoryOptions{}
+	}
+	if err := checkSpecArgSafety(string(head)); err != nil {
+		return nil, err
+	}
+
+	args := []string{"log", commitLogFormat, "--follow", "--name-status", "-z"}
+	if opt.N != 0 {
+		args = append(args, "-n", strconv.FormatUint(uint64(opt.N), 10))
+	}
+	if opt.Skip != 0 {
+		args = append(args, "--skip="+strconv.FormatUint(uint64(opt.Skip), 10))
+	}
+	args = append(args, string(head), "--", filepath.ToSlash(path))
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.Dir
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		stderr = bytes.TrimSpace(stderr)
+		if isBadObjectErr(string(stderr), string(head)) {
+			return nil, vcs.ErrCommitNotFound
+		}
+		return nil, ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, stderr))
+	}
+	return parseFileHistory(out, filepath.ToSlash(path))
+}
+
+// parseFileHistory parses the output of `git log --name-status -z`
+// with commitLogFormat for the file at path. With -z, each commit's
+// fields are followed by its "\n<status>\x00<path>[\x00<path>]\x00"
+// entries (if any), and commits are separated by an extra \x00.
+func parseFileHistory(out []byte, path string) ([]*vcs.FileHistoryEntry, error) {
+	parts := bytes.Split(out, []byte{'\x00'})
+	var entries []*vcs.FileHistoryEntry
+	for len(parts) >= commitLogPartsPerCommit {
+		commit, err := parseCommitLogEntry(parts[:commitLogPartsPerCommit])
+		if err != nil {
+			return nil, err
+		}
+		parts = parts[commitLogPartsPerCommit:]
+		e := &vcs.FileHistoryEntry{Commit: commit}
+
+		// With --follow, git only shows the status of the followed
+		// file, so there is at most one status entry (and none for
+		// merge commits).
+		for len(parts) > 0 && len(parts[0]) > 0 {
+			status := bytes.TrimPrefix(parts[0], []byte{'\n'})
+			if len(status) == 0 {
+				return nil, fmt.Errorf("empty git log status for commit %s", commit.ID)
+			}
+			nPaths := 1
+			switch status[0] {
+			case 'A':
+				e.Status = vcs.FileAdded
+			case 'D':
+				e.Status = vcs.FileDeleted
+			case 'R':
+				e.Status = vcs.FileRenamed
+				nPaths = 2
+			case 'C':
+				e.Status = vcs.FileCopied
+				nPaths = 2
+			default:
+				e.Status = vcs.FileModified
+			}
+			if len(parts) < 1+nPaths {
+				return nil, fmt.Errorf("unexpected end of git log status for commit %s", commit.ID)
+			}
+			if nPaths == 2 {
+				e.OrigPath, e.Path = string(parts[1]), string(parts[2])
+			} else {
+				e.OrigPath, e.Path = "", string(parts[1])
+			}
+			parts = parts[1+nPaths:]
+		}
+		if e.Status == "" {
+			// A merge commit, which git shows without a status.
+			e.Status = vcs.FileModified
+		}
+		entries = append(entries, e)
+
+		if len(parts) > 0 {
+			parts = parts[1:] // the empty part between commits
+		}
+	}
+
+	// Merge commits have no path in the output. The file's path at a
+	// merge is its path after the next (older) commit, or else its
+	// path before the previous (newer) commit.
+	for i, e := range entries {
+		if e.Path != "" {
+			continue
+		}
+		switch {
+		case i+1 < len(entries) && entries[i+1].Path != "":
+			e.Path = entries[i+1].Path
+		case i > 0 && entries[i-1].OrigPath != "":
+			e.Path = entries[i-1].OrigPath
+		case i > 0:
+			e.Path = entries[i-1].Path
+		default:
+			e.Path = path
+		}
+	}
+	return entries, nil
+}
+
+func (r *Repository) LineHistory(path string, startLine, endLine int, head vcs.CommitID, opt *vcs.LineHistoryOptions) ([]*vcs.LineHistoryEntry, error) {
+	return r.LineHistoryContext(context.Background(), path, startLine, endLine, head, opt)
+}
+
+func (r *Repository) LineHistoryContext(ctx context.Context, path string, startLine, endLine int, head vcs.CommitID, opt *vcs.LineHistoryOptions) ([]*vcs.LineHistoryEntry, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	if opt == nil {
+		opt = &vcs.LineHistoryOptions{}
+	}
+	if startLine < 1 || endLine < startLine {
+		return nil, fmt.Errorf("invalid line range %d-%d", startLine, endLine)
+	}
+	if err := checkSpecArgSafety(string(head)); err != nil {
+		return nil, err
+	}
+
+	// -L can't be combined with a "--" pathspec, so the file is only
+	// given in the -L argument.
+	args := []string{"log", commitLogFormat, fmt.Sprintf("-L%d,%d:%s", startLine, endLine, filepath.ToSlash(path))}
+	if opt.N != 0 {
+		args = append(args, "-n", strconv.FormatUint(uint64(opt.N), 10))
+	}
+	args = append(args, string(head))
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.Dir
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		stderr = bytes.TrimSpace(stderr)
+		if isBadObjectErr(string(stderr), string(head)) {
+			return nil, vcs.ErrCommitNotFound
+		}
+		return nil, ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, stderr))
+	}
+	return parseLineHistory(out)
+}
+
+// parseLineHistory parses the output of `git log -L` with
+// commitLogFormat.
+func parseLineHistory(out []byte) ([]*vcs.LineHistoryEntry, error) {
+	commits, diffs, err := parseCommitLogDiffs(out)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*vcs.LineHistoryEntry
+	for i, commit := range commits {
+		files, err := internal.ParseDiff(diffs[i], "a/", "b/")
+		if err != nil {
+			return nil, fmt.Errorf("parsing git log -L diff for commit %s: %s", commit.ID, err)
+		}
+
+		e := &vcs.LineHistoryEntry{Commit: commit}
+		for _, f := range files {
+			if e.Path == "" {
+				e.Path = f.NewName
+			}
+			for _, h := range f.Hunks {
+				if e.Hunks == nil {
+					e.StartLine = int(h.NewStartLine)
+				}
+				e.EndLine = int(h.NewStartLine + h.NewLines - 1)
+				e.Hunks = append(e.Hunks, h)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// parseCommitLogDiffs parses the output of `git log` with
+// commitLogFormat and a diff option (such as -p or -L), and returns
+// the commits and their diffs. Each commit's fields are followed by
+// its diff, and a blank line separates the diff from the next commit.
+func parseCommitLogDiffs(out []byte) ([]*vcs.Commit, [][]byte, error) {
+	parts := bytes.Split(out, []byte{'\x00'})
+	var commits []*vcs.Commit
+	var diffs [][]byte
+	for len(parts) >= commitLogPartsPerCommit {
+		commit, err := parseCommitLogEntry(parts[:commitLogPartsPerCommit])
+		if err != nil {
+			return nil, nil, err
+		}
+		parts = parts[commitLogPartsPerCommit:]
+
+		// The diff is in the same part as the next commit's first
+		// field (which contains no newlines).
+		var diff []byte
+		if len(parts) > 1 {
+			i := bytes.LastIndexByte(parts[0], '\n')
+			diff, parts[0] = parts[0][:i+1], parts[0][i+1:]
+		} else if len(parts) == 1 {
+			diff = parts[0]
+		}
+		commits = append(commits, commit)
+		diffs = append(diffs, bytes.TrimSuffix(diff, []byte("\n")))
+	}
+	return commits, diffs, nil
+}
+
+func (r *Repository) SearchHistory(opt vcs.HistorySearchOptions) ([]*vcs.HistorySearchResult, error) {
+	return r.SearchHistoryContext(context.Background(), opt)
+}
+
+// SearchHistoryContext uses git's pickaxe options (-S and -G) to find
+// the commits that may match, and matches the diffs in Go. Fixed
+// queries select exactly the matching commits with -S or -G. Other
+// queries only prefilter the commits with -G, with a string that every
+// matching line contains (if any), and the diffs are read one commit
+// at a time until N commits match.
+func (r *Repository) SearchHistoryContext(ctx context.Context, opt vcs.HistorySearchOptions) ([]*vcs.HistorySearchResult, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	if err := checkSpecArgSafety(string(opt.Head)); err != nil {
+		return nil, err
+	}
+	if err := checkSpecArgSafety(string(opt.Base)); err != nil {
+		return nil, err
+	}
+	hm, err := internal.CompileHistorySearch(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"log", commitLogFormat, "-p", "--no-color", "--no-ext-diff", "--no-textconv", "--no-merges"}
+	if opt.QueryType == vcs.FixedQuery && opt.Query != "" {
+		// For fixed queries, git selects exactly the commits (and
+		// files) that match, so it can also limit the number of
+		// commits.
+		if opt.Mode == vcs.HistorySearchLines {
+			args = append(args, "-G"+quoteERE(opt.Query))
+		} else {
+			args = append(args, "-S"+opt.Query)
+		}
+		if opt.N != 0 {
+			args = append(args, "-n", strconv.FormatUint(uint64(opt.N), 10))
+		}
+	} else if lit, foldCase := hm.RequiredLiteral(); lit != "" {
+		// Every matching line (in either mode) contains lit, so -G
+		// selects a superset of the matching commits (and files).
+		args = append(args, "-G"+quoteERE(lit))
+		if foldCase {
+			args = append(args, "--regexp-ignore-case")
+		}
+	}
+	rng := string(opt.Head)
+	if opt.Base != "" {
+		rng = string(opt.Base) + ".." + string(opt.Head)
+	}
+	args = append(args, rng, "--")
+	if opt.Path != "" {
+		args = append(args, opt.Path)
+	}
+
+	it, err := r.startCommitLog(ctx, args, opt.Head, false)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var results []*vcs.HistorySearchResult
+	for {
+		commit, diff := it.nextDiff()
+		if commit == nil {
+			break
+		}
+		files, err := internal.ParseDiff(diff, "a/", "b/")
+		if err != nil {
+			return nil, fmt.Errorf("parsing git log -p diff for commit %s: %s", commit.ID, err)
+		}
+		if files = hm.MatchDiff(files); len(files) == 0 {
+			continue
+		}
+		results = append(results, &vcs.HistorySearchResult{Commit: commit, Files: files})
+		if opt.N != 0 && uint(len(results)) == opt.N {
+			break
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// quoteERE returns a POSIX extended regexp (the syntax of git log -G)
+// that matches the literal text s.
+func quoteERE(s string) string {
+	var buf bytes.Buffer
+	for _, c := range []byte(s) {
+		if strings.IndexByte(`\.+*?()|[]{}^$`, c) != -1 {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+func (r *Repository) BlameFile(path string, opt *vcs.BlameOptions) ([]*vcs.Hunk, error) {
+	return r.BlameFileContext(context.Background(), path, opt)
+}
+
+func (r *Repository) BlameFileContext(ctx context.Context, path string, opt *vcs.BlameOptions) ([]*vcs.Hunk, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	if opt == nil {
+		opt = &vcs.BlameOptions{}
+	}
+	if err := checkSpecArgSafety(string(opt.NewestCommit)); err != nil {
+		return nil, err
+	}
+	if err := checkSpecArgSafety(string(opt.OldestCommit)); err != nil {
+		return nil, err
+	}
+
+	// With --root, git only marks commits as boundaries if they are
+	// at the OldestCommit end of the range (and not if they are root
+	// commits).
+	args := []string{"blame", "-w", "--porcelain", "--root"}
+	if opt.StartLine != 0 || opt.EndLine != 0 {
+		args = append(args, fmt.Sprintf("-L%d,%d", opt.StartLine, opt.EndLine))
+	}
+	if opt.DetectMoves {
+		args = append(args, "-M")
+	}
+	for i := 0; i < opt.DetectCopies; i++ {
+		args = append(args, "-C")
+	}
+	ignoreRevs := opt.IgnoreRevs
+	if opt.IgnoreRevsFile != "" {
+		// Read the file from the repository (and not from the working
+		// tree, which bare repositories don't have).
+		cmd := exec.CommandContext(ctx, "git", "show", string(opt.NewestCommit)+":"+filepath.ToSlash(opt.IgnoreRevsFile))
+		cmd.Dir = r.Dir
+		out, stderr, err := dividedOutput(cmd)
+		if err != nil {
+			return nil, ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, stderr))
+		}
+		ignoreRevs = append(ignoreRevs[:len(ignoreRevs):len(ignoreRevs)], internal.ParseIgnoreRevs(out)...)
+	}
+	for _, rev := range ignoreRevs {
+		if err := checkSpecArgSafety(string(rev)); err != nil {
+			return nil, err
+		}
+		args = append(args, "--ignore-rev="+string(rev))
+	}
+	rng := string(opt.NewestCommit)
+	if opt.OldestCommit != "" {
+		rng = string(opt.OldestCommit) + ".." + rng
+	}
+	args = append(args, rng, "--", filepath.ToSlash(path))
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, ctxErr(ctx, fmt.Errorf("exec `git blame` failed: %s. Output was:\n\n%s", err, out))
+	}
+	if len(out) < 1 {
+		// go 1.8.5 changed the behavior of `git blame` on empty files.
+		// previously, it returned a boundary commit. now, it returns nothing.
+		// TODO(sqs) TODO(beyang): make `git blame` return the boundary commit
+		// on an empty file somehow, or come up with some other workaround.
+		st, err := os.Stat(filepath.Join(r.Dir, path))
+		if err == nil && st.Size() == 0 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Expected git output of length at least 1")
+	}
+	return parseBlamePorcelain(out)
+}
+
+// parseBlamePorcelain parses the output of `git blame --porcelain`.
+// Each group of lines from the same commit begins with a header line
+// ("<commit> <orig line> <final line> <num lines>") followed by
+// information about the commit (the first time the commit appears, or
+// if lines come from more than one of its files), and then the
+// contents of the group's lines. Each content line begins with a tab,
+// and all but the first are preceded by a "<commit> <orig line>
+// <final line>" header line.
+func parseBlamePorcelain(out []byte) ([]*vcs.Hunk, error) {
+	commits := make(map[string]vcs.Commit)
+	boundaries := make(map[string]bool)
+	filenames := make(map[string]string) // the last filename line of each commit
+	hunks := make([]*vcs.Hunk, 0)
+	remainingLines := strings.Split(string(out[:len(out)-1]), "\n")
+	byteOffset := 0
+	for len(remainingLines) > 0 {
+		// Consume hunk header
+		hunkHeader := strings.Split(remainingLines[0], " ")
+		if len(hunkHeader) != 4 {
+			return nil, fmt.Errorf("Expected 4 parts to hunkHeader, but got: '%s'", hunkHeader)
+		}
+		commitID := hunkHeader[0]
+		lineNoOrig, _ := strconv.Atoi(hunkHeader[1])
+		lineNoCur, _ := strconv.Atoi(hunkHeader[2])
+		nLines, _ := strconv.Atoi(hunkHeader[3])
+		hunk := &vcs.Hunk{
+			CommitID:      vcs.CommitID(commitID),
+			StartLine:     int(lineNoCur),
+			EndLine:       int(lineNoCur + nLines),
+			StartByte:     byteOffset,
+			OrigStartLine: lineNoOrig,
+		}
+		remainingLines = remainingLines[1:]
+
+		// Consume commit information
+		commit := commits[commitID]
+		for len(remainingLines) > 0 && !strings.HasPrefix(remainingLines[0], "\t") {
+			line := remainingLines[0]
+			remainingLines = remainingLines[1:]
+			key, value := line, ""
+			if i := strings.Index(line, " "); i != -1 {
+				key, value = line[:i], line[i+1:]
+			}
+			switch key {
+			case "author":
+				commit.Author.Name = value
+			case "author-mail":
+				if len(value) >= 2 && value[0] == '<' && value[len(value)-1] == '>' {
+					value = value[1 : len(value)-1]
+				}
+				commit.Author.Email = value
+			case "author-time":
+				authorTime, err := strconv.ParseInt(value, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("Failed to parse author-time %q", line)
+				}
+				commit.Author.Date = pbtypes.NewTimestamp(time.Unix(authorTime, 0).In(time.UTC))
+			case "summary":
+				commit.Message = value
+			case "boundary":
+				boundaries[commitID] = true
+			case "filename":
+				if strings.HasPrefix(value, `"`) {
+					if uq, err := strconv.Unquote(value); err == nil {
+						value = uq
+					}
+				}
+				filenames[commitID] = value
+			}
+		}
+		commit.ID = vcs.CommitID(commitID)
+		commits[commitID] = commit
+
+		hunk.Author = commit.Author
+		hunk.Boundary = boundaries[commitID]
+		hunk.OrigPath = filenames[commitID]
+
+		// Consume lines in hunk
+		for i := 0; i < nLines && len(remainingLines) > 0; i++ {
+			if i > 0 {
+				remainingLines = remainingLines[1:] // header line
+			}
+			if len(remainingLines) == 0 || !strings.HasPrefix(remainingLines[0], "\t") {
+				return nil, fmt.Errorf("Expected line %d of hunk %q to begin with a tab", i+1, hunkHeader)
+			}
+			// The leading tab accounts for the line's trailing newline.
+			byteOffset += len(remainingLines[0])
+			remainingLines = remainingLines[1:]
+		}
+
+		hunk.EndByte = byteOffset
+		hunks = append(hunks, hunk)
+	}
+
+	return hunks, nil
+}
+
+func (r *Repository) MergeBase(a, b vcs.CommitID) (vcs.CommitID, error) {
+	return r.MergeBaseContext(context.Background(), a, b)
+}
+
+func (r *Repository) MergeBaseContext(ctx context.Context, a, b vcs.CommitID) (vcs.CommitID, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	cmd := exec.CommandContext(ctx, "git", "merge-base", "--", string(a), string(b))
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+	}
+	return vcs.CommitID(bytes.TrimSpace(out)), nil
+}
+
+func (r *Repository) CrossRepoMergeBase(a vcs.CommitID, repoB vcs.Repository, b vcs.CommitID) (vcs.CommitID, error) {
+	return r.CrossRepoMergeBaseContext(context.Background(), a, repoB, b)
+}
+
+func (r *Repository) CrossRepoMergeBaseContext(ctx context.Context, a vcs.CommitID, repoB vcs.Repository, b vcs.CommitID) (vcs.CommitID, error) {
+	// git.Repository inherits GitRootDir and CrossRepo from its
+	// embedded gitcmd.Repository.
+
+	var repoBDir string // path to head repo on local filesystem
+	if repoB, ok := repoB.(CrossRepo); ok {
+		repoBDir = repoB.GitRootDir()
+	} else {
+		return "", fmt.Errorf("git cross-repo merge-base not supported against repo type %T", repoB)
+	}
+
+	if repoBDir != r.Dir {
+		if err := r.fetchRemote(ctx, repoBDir); err != nil {
+			return "", err
+		}
+	}
+
+	return r.MergeBaseContext(ctx, a, b)
+}
+
+func (r *Repository) MergeBases(a, b vcs.CommitID) ([]vcs.CommitID, error) {
+	return r.MergeBasesContext(context.Background(), a, b)
+}
+
+func (r *Repository) MergeBasesContext(ctx context.Context, a, b vcs.CommitID) ([]vcs.CommitID, error) {
+	return r.mergeBases(ctx, []string{"--all"}, []vcs.CommitID{a, b})
+}
+
+func (r *Repository) OctopusMergeBases(commits []vcs.CommitID) ([]vcs.CommitID, error) {
+	return r.OctopusMergeBasesContext(context.Background(), commits)
+}
+
+func (r *Repository) OctopusMergeBasesContext(ctx context.Context, commits []vcs.CommitID) ([]vcs.CommitID, error) {
+	if len(commits) == 0 {
+		return nil, errors.New("octopus merge base of no commits")
+	}
+	bases, err := r.mergeBases(ctx, []string{"--all", "--octopus"}, commits)
+	if err != nil {
+		return nil, err
+	}
+	if len(bases) > 1 {
+		// git merge-base --octopus --all combines the merge bases of
+		// each pair of commits without removing the bases that are
+		// ancestors of others.
+		return r.mergeBases(ctx, []string{"--independent"}, bases)
+	}
+	return bases, nil
+}
+
+// mergeBases runs git merge-base with flags on commits and returns
+// the commits that it prints, sorted.
+func (r *Repository) mergeBases(ctx context.Context, flags []string, commits []vcs.CommitID) ([]vcs.CommitID, error) {
+	args := append(append([]string{"merge-base"}, flags...), "--")
+	for _, c := range commits {
+		if err := checkSpecArgSafety(string(c)); err != nil {
+			return nil, err
+		}
+		args = append(args, string(c))
+	}
+
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		out = bytes.TrimSpace(out)
+		if exitStatus(err) == 1 && len(out) == 0 {
+			// The commits have no common ancestor.
+			return nil, nil
+		}
+		if bytes.HasPrefix(out, []byte("fatal: Not a valid object name ")) || bytes.HasPrefix(out, []byte("fatal: Not a valid commit name ")) {
+			return nil, vcs.ErrCommitNotFound
+		}
+		return nil, ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+	}
+
+	var bases []vcs.CommitID
+	for _, line := range bytes.Fields(out) {
+		bases = append(bases, vcs.CommitID(line))
+	}
+	sort.Sort(vcs.CommitIDs(bases))
+	return bases, nil
+}
+
+func (r *Repository) CheckMerge(ours, theirs vcs.CommitID) (*vcs.MergeCheck, error) {
+	return r.CheckMergeContext(context.Background(), ours, theirs)
+}
+
+// CheckMergeContext runs git merge-tree --write-tree, which requires
+// git 2.38 or newer.
+func (r *Repository) CheckMergeContext(ctx context.Context, ours, theirs vcs.CommitID) (*vcs.MergeCheck, error) {
+	if err := checkSpecArgSafety(string(ours)); err != nil {
+		return nil, err
+	}
+	if err := checkSpecArgSafety(string(theirs)); err != nil {
 		return nil, err
 	}
-	if err := checkSpecArgSafety(string(opt.OldestCommit)); err != nil {
-		return nil, err
+
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "merge-tree", "--write-tree", "-z", string(ours), string(theirs))
+	cmd.Dir = r.Dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// Exit code 1 means that there are conflicts, unless git
+		// merge-tree failed before writing the tree.
+		if exitStatus(err) != 1 || stdout.Len() == 0 {
+			if strings.Contains(stderr.String(), " - not something we can merge") {
+				return nil, vcs.ErrCommitNotFound
+			}
+			return nil, ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, stderr.Bytes()))
+		}
+	}
+	return parseMergeTree(stdout.Bytes())
+}
+
+// parseMergeTree parses the output of git merge-tree --write-tree -z:
+// the ID of the tree, and if there are conflicts, the conflicting
+// files' "<mode> <object> <stage>\t<path>" entries and the
+// informational messages (each "<N>", N paths, the type and the
+// message), all \x00-terminated.
+func parseMergeTree(out []byte) (*vcs.MergeCheck, error) {
+	parts := strings.Split(string(out), "\x00")
+	m := &vcs.MergeCheck{Tree: parts[0]}
+	if len(parts) <= 2 {
+		return m, nil
+	}
+
+	conflicts := map[string]*vcs.MergeConflict{}
+	i := 1
+	for ; i < len(parts) && parts[i] != ""; i++ {
+		var fields []string
+		tab := strings.Index(parts[i], "\t")
+		if tab != -1 {
+			fields = strings.Fields(parts[i][:tab])
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected conflicted file entry in git merge-tree output: %q", parts[i])
+		}
+		path := parts[i][tab+1:]
+		c, ok := conflicts[path]
+		if !ok {
+			c = &vcs.MergeConflict{Path: path}
+			conflicts[path] = c
+			m.Conflicts = append(m.Conflicts, c)
+		}
+		switch fields[2] {
+		case "1":
+			c.Base = fields[1]
+		case "2":
+			c.Ours = fields[1]
+		case "3":
+			c.Theirs = fields[1]
+		}
+	}
+
+	// Take the conflicts' types from the messages.
+	for i++; i < len(parts) && parts[i] != ""; {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || i+n+2 >= len(parts) {
+			return nil, fmt.Errorf("unexpected message in git merge-tree output: %q", parts[i:])
+		}
+		paths, typ := parts[i+1:i+1+n], parts[i+1+n]
+		i += n + 3
+		if !strings.HasPrefix(typ, "CONFLICT (") {
+			continue
+		}
+		typ = strings.TrimSuffix(strings.TrimPrefix(typ, "CONFLICT ("), ")")
+		for _, path := range paths {
+			if c, ok := conflicts[path]; ok && c.Type == "" {
+				c.Type = typ
+			}
+		}
+	}
+
+	sort.Sort(mergeConflictsByPath(m.Conflicts))
+	return m, nil
+}
+
+type mergeConflictsByPath []*vcs.MergeConflict
+
+func (p mergeConflictsByPath) Len() int           { return len(p) }
+func (p mergeConflictsByPath) Less(i, j int) bool { return p[i].Path < p[j].Path }
+func (p mergeConflictsByPath) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+func (r *Repository) CreateCommit(opt vcs.CreateCommitOptions) (vcs.CommitID, error) {
+	return r.CreateCommitContext(context.Background(), opt)
+}
+
+// CreateCommitContext writes the changed files with git hash-object,
+// the changed trees with git mktree and the commit with git
+// commit-tree, so it doesn't need a working tree or an index. opt.Ref
+// must be a full ref name (e.g., "refs/heads/master").
+func (r *Repository) CreateCommitContext(ctx context.Context, opt vcs.CreateCommitOptions) (vcs.CommitID, error) {
+	if err := checkSpecArgSafety(string(opt.Parent)); err != nil {
+		return "", err
+	}
+	if opt.Ref != "" {
+		if err := checkRefName(opt.Ref); err != nil {
+			return "", err
+		}
+	}
+	root := &treeChanges{}
+	for i := range opt.Changes {
+		if err := root.add(&opt.Changes[i]); err != nil {
+			return "", err
+		}
+	}
+
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+
+	// Resolve the parent once, so that the compare-and-swap of the ref
+	// compares it to the commit that the new commit is based on (and
+	// not to the current value of a ref named by the parent).
+	var parent, parentTree string
+	if opt.Parent != "" {
+		var err error
+		parent, err = r.revParse(ctx, string(opt.Parent)+"^{commit}")
+		if err != nil {
+			return "", err
+		}
+		parentTree = parent + "^{tree}"
+	}
+	tree, _, err := r.writeTree(ctx, parentTree, "", root)
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{"commit-tree", tree}
+	if parent != "" {
+		args = append(args, "-p", parent)
+	}
+	committer := opt.Committer
+	if committer == nil {
+		committer = &opt.Author
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.Dir
+	cmd.Env = append(os.Environ(), signatureEnv("AUTHOR", opt.Author)...)
+	cmd.Env = append(cmd.Env, signatureEnv("COMMITTER", *committer)...)
+	cmd.Stdin = strings.NewReader(opt.Message)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+	}
+	id := vcs.CommitID(bytes.TrimSpace(out))
+
+	if opt.Ref != "" {
+		if err := r.updateRef(ctx, opt.Ref, vcs.CommitID(parent), id); err != nil {
+			return "", err
+		}
+	}
+	return id, nil
+}
+
+// revParse resolves spec (e.g., "master^{commit}") to an object ID
+// with git rev-parse --verify, or returns vcs.ErrCommitNotFound if the
+// object doesn't exist. The caller must be holding r.editLock.
+func (r *Repository) revParse(ctx context.Context, spec string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--verify", "--quiet", spec)
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if exitStatus(err) == 1 && len(out) == 0 {
+			return "", vcs.ErrCommitNotFound
+		}
+		return "", ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
+
+// signatureEnv returns the environment variables that set the git
+// author or committer (who is "AUTHOR" or "COMMITTER") to sig.
+func signatureEnv(who string, sig vcs.Signature) []string {
+	env := []string{"GIT_" + who + "_NAME=" + sig.Name, "GIT_" + who + "_EMAIL=" + sig.Email}
+	if sig.Date != (pbtypes.Timestamp{}) {
+		env = append(env, fmt.Sprintf("GIT_%s_DATE=%d +0000", who, sig.Date.Seconds))
+	}
+	return env
+}
+
+// A treeChanges holds the file changes in a directory of a tree, by
+// file and subdirectory name.
+type treeChanges struct {
+	files map[string]*vcs.FileChange
+	dirs  map[string]*treeChanges
+}
+
+func (t *treeChanges) add(c *vcs.FileChange) error {
+	names := strings.Split(c.Path, "/")
+	for _, name := range names {
+		if name == "" || name == "." || name == ".." {
+			return fmt.Errorf("invalid path in file change: %q", c.Path)
+		}
+	}
+	for _, name := range names[:len(names)-1] {
+		if _, ok := t.files[name]; ok {
+			return fmt.Errorf("conflicting file changes to %q", c.Path)
+		}
+		sub, ok := t.dirs[name]
+		if !ok {
+			if t.dirs == nil {
+				t.dirs = map[string]*treeChanges{}
+			}
+			sub = &treeChanges{}
+			t.dirs[name] = sub
+		}
+		t = sub
+	}
+	name := names[len(names)-1]
+	if _, ok := t.files[name]; ok {
+		return fmt.Errorf("conflicting file changes to %q", c.Path)
+	}
+	if _, ok := t.dirs[name]; ok {
+		return fmt.Errorf("conflicting file changes to %q", c.Path)
+	}
+	if t.files == nil {
+		t.files = map[string]*vcs.FileChange{}
+	}
+	t.files[name] = c
+	return nil
+}
+
+// A treeEntry is an entry of a tree object, as listed by git ls-tree
+// and read by git mktree.
+type treeEntry struct {
+	mode, typ, id string
+}
+
+// writeTree writes the tree that applies changes to the tree (which
+// is empty if tree is empty) of the directory dir, and returns its ID
+// and whether it is empty. The caller must be holding r.editLock.
+func (r *Repository) writeTree(ctx context.Context, tree, dir string, changes *treeChanges) (string, bool, error) {
+	entries := map[string]treeEntry{}
+	if tree != "" {
+		cmd := exec.CommandContext(ctx, "git", "ls-tree", "-z", tree)
+		cmd.Dir = r.Dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			if bytes.Contains(out, []byte("Not a valid object name")) {
+				return "", false, vcs.ErrCommitNotFound
+			}
+			return "", false, ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+		}
+		for _, line := range strings.Split(string(out), "\x00") {
+			if line == "" {
+				continue
+			}
+			tab := strings.Index(line, "\t")
+			var fields []string
+			if tab != -1 {
+				fields = strings.Fields(line[:tab])
+			}
+			if len(fields) != 3 {
+				return "", false, fmt.Errorf("unexpected entry in git ls-tree output: %q", line)
+			}
+			entries[line[tab+1:]] = treeEntry{mode: fields[0], typ: fields[1], id: fields[2]}
+		}
+	}
+
+	for name, c := range changes.files {
+		e, exists := entries[name]
+		if exists && e.typ != "blob" {
+			return "", false, fmt.Errorf("file change to %q: not a file", c.Path)
+		}
+		if c.Delete {
+			if !exists {
+				return "", false, &os.PathError{Op: "CreateCommit", Path: c.Path, Err: os.ErrNotExist}
+			}
+			delete(entries, name)
+			continue
+		}
+
+		if c.Contents != nil {
+			id, err := r.hashObject(ctx, c.Contents)
+			if err != nil {
+				return "", false, err
+			}
+			e.id = id
+		} else if !exists {
+			return "", false, &os.PathError{Op: "CreateCommit", Path: c.Path, Err: os.ErrNotExist}
+		}
+		switch {
+		case c.Mode&os.ModeSymlink != 0:
+			e.mode = "120000"
+		case c.Mode&0111 != 0:
+			e.mode = "100755"
+		case c.Mode != 0 || !exists:
+			e.mode = "100644"
+		}
+		e.typ = "blob"
+		entries[name] = e
+	}
+
+	for name, sub := range changes.dirs {
+		e, exists := entries[name]
+		if exists && e.typ != "tree" {
+			return "", false, fmt.Errorf("file change in %q: not a directory", strings.TrimPrefix(dir+"/"+name, "/"))
+		}
+		id, empty, err := r.writeTree(ctx, e.id, dir+"/"+name, sub)
+		if err != nil {
+			return "", false, err
+		}
+		if empty {
+			delete(entries, name) // git doesn't store empty directories
+		} else {
+			entries[name] = treeEntry{mode: "040000", typ: "tree", id: id}
+		}
 	}
 
-	args := []string{"blame", "-w", "--porcelain"}
-	if opt.StartLine != 0 || opt.EndLine != 0 {
-		args = append(args, fmt.Sprintf("-L%d,%d", opt.StartLine, opt.EndLine))
+	var buf bytes.Buffer
+	for name, e := range entries {
+		fmt.Fprintf(&buf, "%s %s %s\t%s\x00", e.mode, e.typ, e.id, name)
 	}
-	args = append(args, string(opt.NewestCommit), "--", filepath.ToSlash(path))
-	cmd := exec.Command("git", args...)
+	cmd := exec.CommandContext(ctx, "git", "mktree", "-z")
 	cmd.Dir = r.Dir
+	cmd.Stdin = &buf
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return nil, fmt.Errorf("exec `git blame` failed: %s. Output was:\n\n%s", err, out)
+		return "", false, ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
 	}
-	if len(out) < 1 {
-		// go 1.8.5 changed the behavior of `git blame` on empty files.
-		// previously, it returned a boundary commit. now, it returns nothing.
-		// TODO(sqs) TODO(beyang): make `git blame` return the boundary commit
-		// on an empty file somehow, or come up with some other workaround.
-		st, err := os.Stat(filepath.Join(r.Dir, path))
-		if err == nil && st.Size() == 0 {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("Expected git output of length at least 1")
+	return string(bytes.TrimSpace(out)), len(entries) == 0, nil
+}
+
+// hashObject writes data as a blob and returns its ID.
+func (r *Repository) hashObject(ctx context.Context, data []byte) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "hash-object", "-w", "--stdin")
+	cmd.Dir = r.Dir
+	cmd.Stdin = bytes.NewReader(data)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
 	}
+	return string(bytes.TrimSpace(out)), nil
+}
 
-	commits := make(map[string]vcs.Commit)
-	hunks := make([]*vcs.Hunk, 0)
-	remainingLines := strings.Split(string(out[:len(out)-1]), "\n")
-	byteOffset := 0
-	for len(remainingLines) > 0 {
-		// Consume hunk
-		hunkHeader := strings.Split(remainingLines[0], " ")
-		if len(hunkHeader) != 4 {
-			fmt.Printf("Remaining lines: %+v, %d, '%s'\n", remainingLines, len(remainingLines), remainingLines[0])
-			return nil, fmt.Errorf("Expected at least 4 parts to hunkHeader, but got: '%s'", hunkHeader)
-		}
-		commitID := hunkHeader[0]
-		lineNoCur, _ := strconv.Atoi(hunkHeader[2])
-		nLines, _ := strconv.Atoi(hunkHeader[3])
-		hunk := &vcs.Hunk{
-			CommitID:  vcs.CommitID(commitID),
-			StartLine: int(lineNoCur),
-			EndLine:   int(lineNoCur + nLines),
-			StartByte: byteOffset,
-		}
+func (r *Repository) CreateBranch(name string, id vcs.CommitID) error {
+	return r.CreateBranchContext(context.Background(), name, id)
+}
 
-		if _, in := commits[commitID]; in {
-			// Already seen commit
-			byteOffset += len(remainingLines[1])
-			remainingLines = remainingLines[2:]
-		} else {
-			// New commit
-			author := strings.Join(strings.Split(remainingLines[1], " ")[1:], " ")
-			email := strings.Join(strings.Split(remainingLines[2], " ")[1:], " ")
-			if len(email) >= 2 && email[0] == '<' && email[len(email)-1] == '>' {
-				email = email[1 : len(email)-1]
-			}
-			authorTime, err := strconv.ParseInt(strings.Join(strings.Split(remainingLines[3], " ")[1:], " "), 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("Failed to parse author-time %q", remainingLines[3])
-			}
-			summary := strings.Join(strings.Split(remainingLines[9], " ")[1:], " ")
-			commit := vcs.Commit{
-				ID:      vcs.CommitID(commitID),
-				Message: summary,
-				Author: vcs.Signature{
-					Name:  author,
-					Email: email,
-					Date:  pbtypes.NewTimestamp(time.Unix(authorTime, 0).In(time.UTC)),
-				},
-			}
+func (r *Repository) CreateBranchContext(ctx context.Context, name string, id vcs.CommitID) error {
+	return r.UpdateRefContext(ctx, "refs/heads/"+name, "", id)
+}
 
-			if len(remainingLines) >= 13 && strings.HasPrefix(remainingLines[10], "previous ") {
-				byteOffset += len(remainingLines[12])
-				remainingLines = remainingLines[13:]
-			} else if len(remainingLines) >= 13 && remainingLines[10] == "boundary" {
-				byteOffset += len(remainingLines[12])
-				remainingLines = remainingLines[13:]
-			} else if len(remainingLines) >= 12 {
-				byteOffset += len(remainingLines[11])
-				remainingLines = remainingLines[12:]
-			} else if len(remainingLines) == 11 {
-				// Empty file
-				remainingLines = remainingLines[11:]
-			} else {
-				return nil, fmt.Errorf("Unexpected number of remaining lines (%d):\n%s", len(remainingLines), "  "+strings.Join(remainingLines, "\n  "))
-			}
+func (r *Repository) DeleteBranch(name string) error {
+	return r.DeleteBranchContext(context.Background(), name)
+}
 
-			commits[commitID] = commit
-		}
+func (r *Repository) DeleteBranchContext(ctx context.Context, name string) error {
+	return r.deleteRef(ctx, "refs/heads/"+name, vcs.ErrBranchNotFound)
+}
 
-		if commit, present := commits[commitID]; present {
-			// Should always be present, but check just to avoid
-			// panicking in case of a (somewhat likely) bug in our
-			// git-blame parser above.
-			hunk.CommitID = commit.ID
-			hunk.Author = commit.Author
-		}
+func (r *Repository) CreateTag(name string, id vcs.CommitID, opt *vcs.CreateTagOptions) error {
+	return r.CreateTagContext(context.Background(), name, id, opt)
+}
+
+// CreateTagContext writes an annotated tag's tag object with git mktag.
+func (r *Repository) CreateTagContext(ctx context.Context, name string, id vcs.CommitID, opt *vcs.CreateTagOptions) error {
+	if opt == nil {
+		return r.UpdateRefContext(ctx, "refs/tags/"+name, "", id)
+	}
+	if err := checkRefName("refs/tags/" + name); err != nil {
+		return err
+	}
+	if err := checkSpecArgSafety(string(id)); err != nil {
+		return err
+	}
+
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+
+	// git mktag requires the ID and type of the tagged object.
+	object, err := r.revParse(ctx, string(id)+"^{object}")
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "-t", object)
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+	}
+	typ := string(bytes.TrimSpace(out))
 
-		// Consume remaining lines in hunk
-		for i := 1; i < nLines; i++ {
-			byteOffset += len(remainingLines[1])
-			remainingLines = remainingLines[2:]
+	var tagger string
+	if opt.Tagger != nil {
+		date := opt.Tagger.Date.Seconds
+		if opt.Tagger.Date == (pbtypes.Timestamp{}) {
+			date = time.Now().Unix()
 		}
+		tagger = fmt.Sprintf("%s <%s> %d +0000", opt.Tagger.Name, opt.Tagger.Email, date)
+	} else {
+		cmd := exec.CommandContext(ctx, "git", "var", "GIT_COMMITTER_IDENT")
+		cmd.Dir = r.Dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+		}
+		tagger = string(bytes.TrimSpace(out))
+	}
 
-		hunk.EndByte = byteOffset
-		hunks = append(hunks, hunk)
+	cmd = exec.CommandContext(ctx, "git", "mktag")
+	cmd.Dir = r.Dir
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("object %s\ntype %s\ntag %s\ntagger %s\n\n%s", object, typ, name, tagger, opt.Message))
+	out, err = cmd.CombinedOutput()
+	if err != nil {
+		return ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
 	}
+	return r.updateRef(ctx, "refs/tags/"+name, "", vcs.CommitID(bytes.TrimSpace(out)))
+}
 
-	return hunks, nil
+func (r *Repository) DeleteTag(name string) error {
+	return r.DeleteTagContext(context.Background(), name)
 }
 
-func (r *Repository) MergeBase(a, b vcs.CommitID) (vcs.CommitID, error) {
-	r.editLock.RLock()
-	defer r.editLock.RUnlock()
+func (r *Repository) DeleteTagContext(ctx context.Context, name string) error {
+	return r.deleteRef(ctx, "refs/tags/"+name, vcs.ErrTagNotFound)
+}
+
+func (r *Repository) UpdateRef(name string, old, new vcs.CommitID) error {
+	return r.UpdateRefContext(context.Background(), name, old, new)
+}
+
+func (r *Repository) UpdateRefContext(ctx context.Context, name string, old, new vcs.CommitID) error {
+	if err := checkRefName(name); err != nil {
+		return err
+	}
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+	return r.updateRef(ctx, name, old, new)
+}
+
+// deleteRef deletes the ref name, or returns errNotFound if it doesn't
+// exist.
+func (r *Repository) deleteRef(ctx context.Context, name string, errNotFound error) error {
+	if err := checkRefName(name); err != nil {
+		return err
+	}
 
-	cmd := exec.Command("git", "merge-base", "--", string(a), string(b))
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+
+	cmd := exec.CommandContext(ctx, "git", "show-ref", "--verify", "--hash", name)
 	cmd.Dir = r.Dir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return "", fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out)
+		if bytes.Contains(out, []byte(" - not a valid ref")) {
+			return errNotFound
+		}
+		return ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
 	}
-	return vcs.CommitID(bytes.TrimSpace(out)), nil
+	return r.updateRef(ctx, name, vcs.CommitID(bytes.TrimSpace(out)), "")
 }
 
-func (r *Repository) CrossRepoMergeBase(a vcs.CommitID, repoB vcs.Repository, b vcs.CommitID) (vcs.CommitID, error) {
-	// git.Repository inherits GitRootDir and CrossRepo from its
-	// embedded gitcmd.Repository.
-
-	var repoBDir string // path to head repo on local filesystem
-	if repoB, ok := repoB.(CrossRepo); ok {
-		repoBDir = repoB.GitRootDir()
-	} else {
-		return "", fmt.Errorf("git cross-repo merge-base not supported against repo type %T", repoB)
+// updateRef updates the ref name from old to new (see
+// vcs.RefUpdater.UpdateRef) in a git update-ref --stdin transaction.
+// The caller must be holding r.editLock.
+func (r *Repository) updateRef(ctx context.Context, name string, old, new vcs.CommitID) error {
+	for _, id := range []vcs.CommitID{old, new} {
+		if strings.ContainsAny(string(id), " \t\r\n") {
+			return fmt.Errorf("invalid object ID: %q", id)
+		}
+	}
+	var line string
+	switch {
+	case old == "" && new == "":
+		line = fmt.Sprintf("verify %s %s\n", name, strings.Repeat("0", 40)) // the ref must not exist
+	case old == "":
+		line = fmt.Sprintf("create %s %s\n", name, new)
+	case new == "":
+		line = fmt.Sprintf("delete %s %s\n", name, old)
+	default:
+		line = fmt.Sprintf("update %s %s %s\n", name, new, old)
 	}
 
-	if repoBDir != r.Dir {
-		if err := r.fetchRemote(repoBDir); err != nil {
-			return "", err
+	cmd := exec.CommandContext(ctx, "git", "update-ref", "--stdin")
+	cmd.Dir = r.Dir
+	cmd.Stdin = strings.NewReader("start\n" + line + "commit\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		switch {
+		case bytes.Contains(out, []byte("cannot lock ref")):
+			return vcs.ErrRefChanged
+		case bytes.Contains(out, []byte("nonexistent object")), bytes.Contains(out, []byte(": invalid <new")):
+			// Newer versions of git say <new-oid> instead of
+			// <newvalue>.
+			return vcs.ErrCommitNotFound
 		}
+		return ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
 	}
+	return nil
+}
 
-	return r.MergeBase(a, b)
+// checkRefName checks that name is a full ref name (e.g.,
+// "refs/heads/master") that can be passed to git update-ref --stdin.
+func checkRefName(name string) error {
+	if !strings.HasPrefix(name, "refs/") || strings.ContainsAny(name, " \t\r\n") {
+		return fmt.Errorf("invalid ref name (must be a full ref name, like refs/heads/master): %q", name)
+	}
+	return nil
 }
 
 func (r *Repository) Search(at vcs.CommitID, opt vcs.SearchOptions) ([]*vcs.SearchResult, error) {
-	if err := checkSpecArgSafety(string(at)); err != nil {
+	return r.SearchContext(context.Background(), at, opt)
+}
+
+func (r *Repository) SearchContext(ctx context.Context, at vcs.CommitID, opt vcs.SearchOptions) ([]*vcs.SearchResult, error) {
+	page := internal.NewSearchPage(opt)
+	if err := r.search(ctx, at, opt, page); err != nil {
 		return nil, err
 	}
+	return page.Results, nil
+}
 
-	var queryType string
-	switch opt.QueryType {
-	case vcs.FixedQuery:
-		queryType = "--fixed-strings"
-	default:
-		return nil, fmt.Errorf("unrecognized QueryType: %q", opt.QueryType)
+func (r *Repository) StreamSearch(at vcs.CommitID, opt vcs.SearchOptions, fn func(*vcs.SearchResult) bool) (*vcs.SearchStats, error) {
+	return r.StreamSearchContext(context.Background(), at, opt, fn)
+}
+
+func (r *Repository) StreamSearchContext(ctx context.Context, at vcs.CommitID, opt vcs.SearchOptions, fn func(*vcs.SearchResult) bool) (*vcs.SearchStats, error) {
+	page := internal.NewSearchStream(opt, fn)
+	if err := r.search(ctx, at, opt, page); err != nil {
+		return nil, err
+	}
+	return &page.Stats, nil
+}
+
+// search adds the results of searching the commit at to page, until
+// the page is full (in which case git grep is killed).
+func (r *Repository) search(ctx context.Context, at vcs.CommitID, opt vcs.SearchOptions, page *internal.SearchPage) error {
+	if err := checkSpecArgSafety(string(at)); err != nil {
+		return err
+	}
+
+	m, err := internal.CompileSearch(opt)
+	if err != nil {
+		return err
 	}
 
-	cmd := exec.Command("git", "grep", "--null", "--line-number", "-I", "--no-color", "--context", strconv.Itoa(int(opt.ContextLines)), queryType, "-e", opt.Query, string(at))
+	// git grep lists the files that may match, and their lines are
+	// matched in Go so that queries have the same semantics as on
+	// other backends.
+	args := []string{"grep", "--null", "--files-with-matches", "-I", "--no-color"}
+	args = append(args, grepCandidateArgs(m)...)
+	args = append(args, string(at), "--")
+	args = append(args, grepPathspecs(opt)...)
+	cmd := exec.CommandContext(ctx, "git", args...)
 	cmd.Dir = r.Dir
 	cmd.Stderr = os.Stderr
 	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := cmd.Start(); err != nil {
+		return ctxErr(ctx, err)
+	}
+	defer cmd.Process.Kill()
+
+	cat, err := r.startCatFile(ctx, "--batch")
+	if err != nil {
+		return err
+	}
+	defer cat.Close()
+
+	rd := bufio.NewReader(out)
+	for {
+		// Each file looks like: "HEAD:filename\x00".
+		name, err := rd.ReadString('\x00')
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return ctxErr(ctx, err)
+		}
+		spec := name[:len(name)-1]
+		file := spec[len(at)+1:]
+		if !m.MatchPath(file) {
+			continue
+		}
+		if page.Full() {
+			page.Truncate()
+			break
+		}
+		data, size, err := cat.contents(spec, opt.MaxFileSize)
+		if err != nil {
+			return ctxErr(ctx, err)
+		}
+		if !m.MatchSize(size) {
+			continue
+		}
+		page.Stats.FilesScanned++
+		page.Add(m.SearchFile(file, data, int(opt.ContextLines)))
+	}
+
+	if err := cmd.Process.Kill(); err != nil {
+		if runtime.GOOS != "windows" {
+			return err
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		if c := exitStatus(err); c != -1 && c != 1 {
+			// -1 exit code = killed (by cmd.Process.Kill() call
+			// above), 1 exit code means grep had no match (but we
+			// don't translate that to a Go error)
+			return ctxErr(ctx, fmt.Errorf("exec %v failed: %s", cmd.Args, err))
+		}
+	}
+	// If the search was cut short, the results are incomplete.
+	return ctx.Err()
+}
+
+func (r *Repository) SearchRevisions(revs []vcs.CommitID, opt vcs.SearchOptions) ([]*vcs.SearchResult, error) {
+	return r.SearchRevisionsContext(context.Background(), revs, opt)
+}
+
+// SearchRevisionsContext lists the files of all revisions that may
+// match with a single git grep, and searches each of their blobs once
+// in Go. Once the results fill the page, it only reads the blob IDs of
+// the remaining files (to list the revisions of the results).
+func (r *Repository) SearchRevisionsContext(ctx context.Context, revs []vcs.CommitID, opt vcs.SearchOptions) ([]*vcs.SearchResult, error) {
+	for _, rev := range revs {
+		if err := checkSpecArgSafety(string(rev)); err != nil {
+			return nil, err
+		}
+	}
+	if len(revs) == 0 {
+		return nil, nil
+	}
+
+	m, err := internal.CompileSearch(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"grep", "--null", "--files-with-matches", "-I", "--no-color"}
+	args = append(args, grepCandidateArgs(m)...)
+	for _, rev := range revs {
+		args = append(args, string(rev))
+	}
+	args = append(args, "--")
+	args = append(args, grepPathspecs(opt)...)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.Dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
 	if err != nil {
 		return nil, err
 	}
 	defer out.Close()
 	if err := cmd.Start(); err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+	defer cmd.Process.Kill()
+
+	check, err := r.startCatFile(ctx, "--batch-check")
+	if err != nil {
 		return nil, err
 	}
+	defer check.Close()
+	cat, err := r.startCatFile(ctx, "--batch")
+	if err != nil {
+		return nil, err
+	}
+	defer cat.Close()
 
-	errc := make(chan error)
-	var res []*vcs.SearchResult
-	go func() {
-		rd := bufio.NewReader(out)
-		var r *vcs.SearchResult
-		addResult := func(rr *vcs.SearchResult) bool {
-			if rr != nil {
-				if opt.Offset == 0 {
-					res = append(res, rr)
-				} else {
-					opt.Offset--
-				}
-				r = nil
-			}
-			// Return true if no more need to be added.
-			return len(res) == int(opt.N)
+	s := internal.NewMultiSearch(m, opt)
+	rd := bufio.NewReader(out)
+	i := 0 // index in revs of the revision of the current file
+	for {
+		// Each file looks like "rev:filename\x00", and the files of
+		// each revision are listed in the order of revs.
+		name, err := rd.ReadString('\x00')
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, ctxErr(ctx, err)
 		}
-		for {
-			line, err := rd.ReadBytes('\n')
-			if err == io.EOF {
-				// git-grep output ends with a newline, so if we hit EOF, there's nothing left to
-				// read
-				break
-			} else if err != nil {
-				errc <- err
-				return
+		spec := name[:len(name)-1]
+		j := i
+		for j < len(revs) && !strings.HasPrefix(spec, string(revs[j])+":") {
+			j++
+		}
+		if j == len(revs) {
+			continue // not a tree (e.g., a blob ID)
+		}
+		i = j
+		file := spec[len(revs[i])+1:]
+		if !m.MatchPath(file) {
+			continue
+		}
+		oid, _, err := check.header(spec)
+		if err != nil {
+			return nil, ctxErr(ctx, err)
+		}
+		if !s.Searched(oid) {
+			if s.Full() {
+				continue
 			}
-			// line is guaranteed to be '\n' terminated according to the contract of ReadBytes
-			line = line[0 : len(line)-1]
-
-			if bytes.Equal(line, []byte("--")) {
-				// Match separator.
-				if addResult(r) {
-					break
-				}
-			} else {
-				// Match line looks like: "HEAD:filename\x00lineno\x00matchline\n".
-				fileEnd := bytes.Index(line, []byte{'\x00'})
-				file := string(line[len(at)+1 : fileEnd])
-				lineNoStart, lineNoEnd := fileEnd+1, fileEnd+1+bytes.Index(line[fileEnd+1:], []byte{'\x00'})
-				lineNo, err := strconv.Atoi(string(line[lineNoStart:lineNoEnd]))
-				if err != nil {
-					panic("bad line number on line: " + string(line) + ": " + err.Error())
-				}
-				if r == nil || r.File != file {
-					if r != nil {
-						if addResult(r) {
-							break
-						}
-					}
-					r = &vcs.SearchResult{File: file, StartLine: uint32(lineNo)}
-				}
-				r.EndLine = uint32(lineNo)
-				if r.Match != nil {
-					r.Match = append(r.Match, '\n')
-				}
-				r.Match = append(r.Match, line[lineNoEnd+1:]...)
+			data, _, err := cat.contents(oid, opt.MaxFileSize)
+			if err != nil {
+				return nil, ctxErr(ctx, err)
 			}
+			s.AddContents(oid, file, data)
 		}
-		addResult(r)
+		s.AddFile(revs[i], file, oid)
+	}
 
-		if err := cmd.Process.Kill(); err != nil {
-			if runtime.GOOS != "windows" {
-				errc <- err
-				return
-			}
+	if err := cmd.Wait(); err != nil {
+		if msg := stderr.Bytes(); bytes.HasPrefix(msg, []byte("fatal: unable to resolve revision: ")) || bytes.HasPrefix(msg, []byte("fatal: unable to parse object: ")) {
+			return nil, vcs.ErrCommitNotFound
 		}
-		if err := cmd.Wait(); err != nil {
-			if c := exitStatus(err); c != -1 && c != 1 {
-				// -1 exit code = killed (by cmd.Process.Kill() call
-				// above), 1 exit code means grep had no match (but we
-				// don't translate that to a Go error)
-				errc <- fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out)
-				return
+		// Exit code 1 means that grep had no match.
+		if exitStatus(err) != 1 {
+			return nil, ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, stderr.Bytes()))
+		}
+	}
+	return s.Results(), nil
+}
+
+// grepCandidateArgs returns the git grep arguments that match the
+// lines that may match the query of m: those that contain the string
+// that every match contains. git's regexps may differ from Go's, so the
+// lines are matched in Go. If there is no such string, all files are
+// listed.
+func grepCandidateArgs(m *internal.SearchMatcher) []string {
+	lit, foldCase := m.RequiredLiteral()
+	if lit == "" {
+		return []string{"-e", ""}
+	}
+	args := []string{"--fixed-strings"}
+	if foldCase {
+		args = append(args, "--ignore-case")
+	}
+	return append(args, "-e", lit)
+}
+
+// grepPathspecs returns the git pathspecs that select the files to
+// search. Pathspecs can't require both an include pattern and an
+// extension, so the extensions are only used if there are no include
+// patterns (and the files are also matched in Go).
+func grepPathspecs(opt vcs.SearchOptions) []string {
+	var pathspecs []string
+	for _, pattern := range opt.IncludePatterns {
+		pathspecs = append(pathspecs, ":(top,glob)"+pattern)
+	}
+	if len(opt.IncludePatterns) == 0 {
+		for _, ext := range opt.Extensions {
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
 			}
+			pathspecs = append(pathspecs, ":(top,glob)**/*"+ext)
 		}
-		errc <- nil
-	}()
+	}
+	for _, pattern := range opt.ExcludePatterns {
+		pathspecs = append(pathspecs, ":(top,glob,exclude)"+pattern)
+	}
+	return pathspecs
+}
+
+// A catFileBatch reads objects with a long-running `git cat-file
+// --batch` (or --batch-check) process.
+type catFileBatch struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	out *bufio.Reader
+}
+
+// startCatFile starts a git cat-file process with the mode flag (either
+// --batch or --batch-check).
+func (r *Repository) startCatFile(ctx context.Context, mode string) (*catFileBatch, error) {
+	cmd := exec.CommandContext(ctx, "git", "cat-file", mode)
+	cmd.Dir = r.Dir
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+	return &catFileBatch{cmd: cmd, in: in, out: bufio.NewReader(out)}, nil
+}
+
+// contents returns the contents and size of the object named by spec
+// (e.g., "rev:path"). If maxSize is positive and the object is larger,
+// its contents are skipped and nil is returned instead. git cat-file
+// flushes its output after each object, so objects can be read one at
+// a time.
+func (c *catFileBatch) contents(spec string, maxSize int64) ([]byte, int64, error) {
+	_, size, err := c.header(spec)
+	if err != nil {
+		return nil, 0, err
+	}
+	// The contents are followed by a newline.
+	if maxSize > 0 && size > maxSize {
+		_, err := io.CopyN(ioutil.Discard, c.out, size+1)
+		return nil, size, err
+	}
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(c.out, data); err != nil {
+		return nil, 0, err
+	}
+	return data[:size], size, nil
+}
+
+// header requests the object named by spec and returns its ID and size
+// from the header that git cat-file writes before its contents (or
+// alone, with --batch-check).
+func (c *catFileBatch) header(spec string) (string, int64, error) {
+	if _, err := io.WriteString(c.in, spec+"\n"); err != nil {
+		return "", 0, err
+	}
+	// The header looks like "<oid> <type> <size>\n" or "<spec> missing\n".
+	header, err := c.out.ReadString('\n')
+	if err != nil {
+		return "", 0, err
+	}
+	if strings.HasSuffix(header, " missing\n") {
+		return "", 0, &os.PathError{Op: "cat-file", Path: spec, Err: os.ErrNotExist}
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return "", 0, fmt.Errorf("invalid `git cat-file` output: %q", header)
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid `git cat-file` size: %q", header)
+	}
+	return fields[0], size, nil
+}
 
-	err = <-errc
-	cmd.Process.Kill()
-	return res, err
+func (c *catFileBatch) Close() error {
+	c.in.Close()
+	return c.cmd.Wait()
 }
 
 func (r *Repository) Committers(opt vcs.CommittersOptions) ([]*vcs.Committer, error) {
+	return r.CommittersContext(context.Background(), opt)
+}
+
+func (r *Repository) CommittersContext(ctx context.Context, opt vcs.CommittersOptions) ([]*vcs.Committer, error) {
 	r.editLock.RLock()
 	defer r.editLock.RUnlock()
 
@@ -972,11 +2980,11 @@ func (r *Repository) Committers(opt vcs.CommittersOptions) ([]*vcs.Committer, er
 		opt.Rev = "HEAD"
 	}
 
-	cmd := exec.Command("git", "shortlog", "-sne", opt.Rev)
+	cmd := exec.CommandContext(ctx, "git", "shortlog", "-sne", opt.Rev)
 	cmd.Dir = r.Dir
 	out, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("exec `git shortlog -sne` failed: %v", err)
+		return nil, ctxErr(ctx, fmt.Errorf("exec `git shortlog -sne` failed: %v", err))
 	}
 	out = bytes.TrimSpace(out)
 
@@ -1004,6 +3012,10 @@ func (r *Repository) Committers(opt vcs.CommittersOptions) ([]*vcs.Committer, er
 }
 
 func (r *Repository) ListFiles(at vcs.CommitID) ([]string, error) {
+	return r.ListFilesContext(context.Background(), at)
+}
+
+func (r *Repository) ListFilesContext(ctx context.Context, at vcs.CommitID) ([]string, error) {
 	if err := checkSpecArgSafety(string(at)); err != nil {
 		return nil, err
 	}
@@ -1014,11 +3026,11 @@ func (r *Repository) ListFiles(at vcs.CommitID) ([]string, error) {
 	if at == "" {
 		at = "HEAD"
 	}
-	cmd := exec.Command("git", "ls-tree", "--full-tree", "-r", "-z", "--name-only", string(at))
+	cmd := exec.CommandContext(ctx, "git", "ls-tree", "--full-tree", "-r", "-z", "--name-only", string(at))
 	cmd.Dir = r.Dir
 	out, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("exec `git ls-tree --full-tree -r -z --name-only %v` failed: %v", at, err)
+		return nil, ctxErr(ctx, fmt.Errorf("exec `git ls-tree --full-tree -r -z --name-only %v` failed: %v", at, err))
 	}
 	if len(out) == 0 {
 		return []string{}, nil
@@ -1028,11 +3040,16 @@ func (r *Repository) ListFiles(at vcs.CommitID) ([]string, error) {
 }
 
 func (r *Repository) FileSystem(at vcs.CommitID) (vfs.FileSystem, error) {
+	return r.FileSystemContext(context.Background(), at)
+}
+
+func (r *Repository) FileSystemContext(ctx context.Context, at vcs.CommitID) (vfs.FileSystem, error) {
 	if err := checkSpecArgSafety(string(at)); err != nil {
 		return nil, err
 	}
 
 	return &gitFSCmd{
+		ctx:          ctx,
 		dir:          r.Dir,
 		at:           at,
 		repo:         r,
@@ -1041,6 +3058,7 @@ func (r *Repository) FileSystem(at vcs.CommitID) (vfs.FileSystem, error) {
 }
 
 type gitFSCmd struct {
+	ctx          context.Context // bounds all of the commands run by the file system
 	dir          string
 	at           vcs.CommitID
 	repo         *Repository
@@ -1059,7 +3077,7 @@ func (fs *gitFSCmd) Open(name string) (vfs.ReadSeekCloser, error) {
 }
 
 func (fs *gitFSCmd) readFileBytes(name string) ([]byte, error) {
-	cmd := exec.Command("git", "show", string(fs.at)+":"+name)
+	cmd := exec.CommandContext(fs.ctx, "git", "show", string(fs.at)+":"+name)
 	cmd.Dir = fs.dir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -1078,7 +3096,7 @@ func (fs *gitFSCmd) readFileBytes(name string) ([]byte, error) {
 			}
 
 		}
-		return nil, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out)
+		return nil, ctxErr(fs.ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
 	}
 	return out, nil
 }
@@ -1118,11 +3136,11 @@ func (fs *gitFSCmd) getModTimeFromGitLog(path string) (time.Time, error) {
 	if !SetModTime {
 		return time.Time{}, nil
 	}
-	cmd := exec.Command("git", "log", "-1", "--format=%ad", string(fs.at), "--", filepath.ToSlash(path))
+	cmd := exec.CommandContext(fs.ctx, "git", "log", "-1", "--format=%ad", string(fs.at), "--", filepath.ToSlash(path))
 	cmd.Dir = fs.dir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return time.Time{}, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out)
+		return time.Time{}, ctxErr(fs.ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
 	}
 	timeStr := strings.Trim(string(out), "\n")
 	if timeStr == "" {
@@ -1173,14 +3191,14 @@ func (fs *gitFSCmd) lsTree(path string) ([]os.FileInfo, error) {
 		return nil, err
 	}
 
-	cmd := exec.Command("git", "ls-tree", "-z", "--full-name", "--long", string(fs.at), "--", filepath.ToSlash(path))
+	cmd := exec.CommandContext(fs.ctx, "git", "ls-tree", "-z", "--full-name", "--long", string(fs.at), "--", filepath.ToSlash(path))
 	cmd.Dir = fs.dir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		if bytes.Contains(out, []byte("exists on disk, but not in")) {
 			return nil, &os.PathError{Op: "ls-tree", Path: filepath.ToSlash(path), Err: os.ErrNotExist}
 		}
-		return nil, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out)
+		return nil, ctxErr(fs.ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
 	}
 
 	if len(out) == 0 {
@@ -1248,7 +3266,7 @@ func (fs *gitFSCmd) lsTree(path string) ([]os.FileInfo, error) {
 			}
 		case "commit":
 			mode = mode | vcs.ModeSubmodule
-			cmd := exec.Command("git", "config", "--get", "submodule."+name+".url")
+			cmd := exec.CommandContext(fs.ctx, "git", "config", "--get", "submodule."+name+".url")
 			cmd.Dir = fs.dir
 			url := "" // url is not available if submodules are not initialized
 			if out, err := cmd.Output(); err == nil {
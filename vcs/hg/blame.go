@@ -0,0 +1,324 @@
+package hg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	hg_revlog "github.com/beyang/hgo/revlog"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/internal"
+	"sourcegraph.com/sqs/pbtypes"
+)
+
+func (r *Repository) BlameFile(path string, opt *vcs.BlameOptions) ([]*vcs.Hunk, error) {
+	return r.BlameFileContext(context.Background(), path, opt)
+}
+
+// BlameFileContext annotates the file by walking its filelog (and the
+// filelogs of the files it was copied from) and diffing the revisions
+// in Go, so unlike hgcmd it doesn't need python-hglib. It falls back
+// to hgcmd for IgnoreRevs and IgnoreRevsFile.
+func (r *Repository) BlameFileContext(ctx context.Context, path string, opt *vcs.BlameOptions) ([]*vcs.Hunk, error) {
+	if opt == nil {
+		opt = &vcs.BlameOptions{}
+	}
+	if len(opt.IgnoreRevs) > 0 || opt.IgnoreRevsFile != "" {
+		return r.Repository.BlameFileContext(ctx, path, opt)
+	}
+	if opt.DetectMoves || opt.DetectCopies != 0 {
+		return nil, fmt.Errorf("vcs.BlameOptions.DetectMoves and DetectCopies options not implemented")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	newest, err := r.parseRevisionSpec(string(opt.NewestCommit)).Lookup(r.cl)
+	if err != nil {
+		if err == hg_revlog.ErrRevNotFound {
+			err = vcs.ErrCommitNotFound
+		}
+		return nil, err
+	}
+	fs := &hgFSNative{
+		ctx:  ctx,
+		dir:  r.Dir,
+		at:   hg_revlog.FileRevSpec(newest.FileRev()),
+		repo: r.u,
+		st:   r.st,
+		cl:   r.cl,
+		fb:   hg_revlog.NewFileBuilder(),
+	}
+	fileRec, _, err := fs.getEntry(internal.Rel(path))
+	if err != nil {
+		return nil, standardizeHgError(err)
+	}
+
+	a := &annotator{ctx: ctx, r: r, fb: hg_revlog.NewFileBuilder()}
+	lines, origins, err := a.annotate(&fileRev{path: internal.Rel(path), rec: fileRec})
+	if err != nil {
+		return nil, err
+	}
+
+	var oldest *vcs.Commit
+	var boundaryRevs map[int]struct{}
+	if opt.OldestCommit != "" {
+		oldestRec, err := r.getRec(opt.OldestCommit)
+		if err != nil {
+			return nil, err
+		}
+		if oldest, err = r.makeCommit(oldestRec); err != nil {
+			return nil, err
+		}
+		boundaryRevs = ancestorRevs(oldestRec)
+	}
+
+	hunks := make([]*vcs.Hunk, 0)
+	commits := map[int]*vcs.Commit{}
+	byteOffset := 0
+	for i, o := range origins {
+		var (
+			c        *vcs.Commit
+			boundary bool
+		)
+		if _, ok := boundaryRevs[o.linkrev]; ok {
+			c, boundary = oldest, true
+		} else if c = commits[o.linkrev]; c == nil {
+			rec, err := hg_revlog.FileRevSpec(o.linkrev).Lookup(r.cl)
+			if err != nil {
+				return nil, err
+			}
+			if c, err = r.makeCommit(rec); err != nil {
+				return nil, err
+			}
+			commits[o.linkrev] = c
+		}
+
+		startByte := byteOffset
+		byteOffset += len(lines[i]) + 1
+
+		// Extend the previous hunk if the line continues it. Lines
+		// attributed to the boundary are merged regardless of where
+		// they came from, like hgcmd's blameBoundary does.
+		if n := len(hunks); n > 0 {
+			h := hunks[n-1]
+			prev := origins[i-1]
+			if h.CommitID == c.ID && h.Boundary == boundary && (boundary || (prev.path == o.path && prev.line+1 == o.line)) {
+				h.EndLine = i + 2
+				h.EndByte = byteOffset
+				continue
+			}
+		}
+		hunks = append(hunks, &vcs.Hunk{
+			StartLine: i + 1,
+			EndLine:   i + 2,
+			StartByte: startByte,
+			EndByte:   byteOffset,
+			CommitID:  c.ID,
+			Author: vcs.Signature{
+				Name:  c.Author.Name,
+				Email: c.Author.Email,
+				Date:  pbtypes.NewTimestamp(c.Author.Date.Time().In(time.UTC)),
+			},
+			Boundary:      boundary,
+			OrigPath:      o.path,
+			OrigStartLine: o.line,
+		})
+	}
+	return hunks, nil
+}
+
+// A fileRev is a revision of a file in its filelog.
+type fileRev struct {
+	path string
+	rec  *hg_revlog.Rec
+}
+
+func (f *fileRev) key() string { return f.path + "\x00" + strconv.Itoa(f.rec.FileRev()) }
+
+// A lineOrigin is the changeset (by revision number) that introduced a
+// line, and the path and 1-indexed line number that the line had in
+// that changeset.
+type lineOrigin struct {
+	linkrev int
+	path    string
+	line    int
+}
+
+// An annotator computes the origins of the lines of a file revision,
+// like hg annotate.
+type annotator struct {
+	ctx context.Context
+	r   *Repository
+	fb  *hg_revlog.FileBuilder
+}
+
+// annotateNode is a file revision in the graph walked by annotate.
+type annotateNode struct {
+	rev      *fileRev
+	parents  []*annotateNode
+	children int // number of children that still need lines and origins
+
+	lines   []string
+	origins []lineOrigin
+}
+
+// annotate returns the lines of the file revision rev and their
+// origins.
+func (a *annotator) annotate(rev *fileRev) ([]string, []lineOrigin, error) {
+	// Find the file revisions that rev descends from, and order them
+	// so that parents come before their children.
+	nodes := map[string]*annotateNode{}
+	root := &annotateNode{rev: rev}
+	nodes[rev.key()] = root
+	var order []*annotateNode
+	type frame struct {
+		n        *annotateNode
+		expanded bool
+	}
+	stack := []frame{{n: root}}
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if f.expanded {
+			order = append(order, f.n)
+			continue
+		}
+		if err := a.ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		parents, err := a.parents(f.n.rev)
+		if err != nil {
+			return nil, nil, err
+		}
+		stack = append(stack, frame{n: f.n, expanded: true})
+		for _, p := range parents {
+			pn, seen := nodes[p.key()]
+			if !seen {
+				pn = &annotateNode{rev: p}
+				nodes[p.key()] = pn
+				stack = append(stack, frame{n: pn})
+			}
+			pn.children++
+			f.n.parents = append(f.n.parents, pn)
+		}
+	}
+
+	for _, n := range order {
+		if err := a.ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		_, content, err := a.read(n.rev.rec)
+		if err != nil {
+			return nil, nil, err
+		}
+		n.lines = splitLines(content)
+		n.origins = make([]lineOrigin, len(n.lines))
+		linkrev := int(n.rev.rec.Linkrev)
+		for i := range n.origins {
+			n.origins[i] = lineOrigin{linkrev: linkrev, path: n.rev.path, line: i + 1}
+		}
+
+		// Lines that are unchanged from a parent keep their origin
+		// there. The first parent takes precedence for merges.
+		attributed := make([]bool, len(n.lines))
+		for _, p := range n.parents {
+			for i, j := range internal.MatchLines(p.lines, n.lines) {
+				if j >= 0 && !attributed[i] {
+					n.origins[i] = p.origins[j]
+					attributed[i] = true
+				}
+			}
+			// Free the parent's lines once all of its children have
+			// been annotated.
+			if p.children--; p.children == 0 {
+				p.lines, p.origins = nil, nil
+			}
+		}
+	}
+	return root.lines, root.origins, nil
+}
+
+// parents returns the parent file revisions of rev. A file revision
+// without a parent in its own filelog that was copied or renamed from
+// another file has the revision it was copied from as its parent.
+func (a *annotator) parents(rev *fileRev) ([]*fileRev, error) {
+	var parents []*fileRev
+	if !rev.rec.IsStartOfBranch() {
+		if p := rev.rec.Parent(); p != nil && !p.IsNull() {
+			parents = append(parents, &fileRev{path: rev.path, rec: p})
+		}
+		if rev.rec.Parent2Present() {
+			parents = append(parents, &fileRev{path: rev.path, rec: rev.rec.Parent2()})
+		}
+	}
+	if len(parents) > 0 {
+		return parents, nil
+	}
+
+	meta, _, err := a.read(rev.rec)
+	if err != nil {
+		return nil, err
+	}
+	if meta["copy"] == "" || meta["copyrev"] == "" {
+		return nil, nil
+	}
+	fileLog, err := a.r.st.OpenRevlog(meta["copy"])
+	if err != nil {
+		return nil, err
+	}
+	rec, err := hg_revlog.NodeIdRevSpec(meta["copyrev"]).Lookup(fileLog)
+	if err != nil {
+		return nil, err
+	}
+	return []*fileRev{{path: meta["copy"], rec: rec}}, nil
+}
+
+// read returns the copy metadata and the contents of a file revision.
+func (a *annotator) read(rec *hg_revlog.Rec) (map[string]string, []byte, error) {
+	data, err := a.fb.Build(rec)
+	if err != nil {
+		return nil, nil, err
+	}
+	meta, content := splitFileMeta(data)
+	return meta, content, nil
+}
+
+// splitFileMeta splits the metadata that hg stores at the beginning of
+// a filelog revision's data (between "\x01\n" markers, e.g.,
+// "copy: path" and "copyrev: id" for copied files) from the file's
+// contents.
+func splitFileMeta(data []byte) (map[string]string, []byte) {
+	const marker = "\x01\n"
+	if !bytes.HasPrefix(data, []byte(marker)) {
+		return nil, data
+	}
+	end := bytes.Index(data[len(marker):], []byte(marker))
+	if end == -1 {
+		return nil, data
+	}
+	meta := map[string]string{}
+	for _, line := range strings.Split(string(data[len(marker):len(marker)+end]), "\n") {
+		if i := strings.Index(line, ": "); i != -1 {
+			meta[line[:i]] = line[i+2:]
+		}
+	}
+	return meta, data[len(marker)+end+len(marker):]
+}
+
+// splitLines splits data into lines, without their trailing newlines.
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.Split(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
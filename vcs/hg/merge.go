@@ -0,0 +1,190 @@
+package hg
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	hg_revlog "github.com/beyang/hgo/revlog"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func (r *Repository) MergeBase(a, b vcs.CommitID) (vcs.CommitID, error) {
+	return r.MergeBaseContext(context.Background(), a, b)
+}
+
+// MergeBaseContext returns the greatest common ancestor of a and b by
+// walking the parents in the changelog. Like hg's ancestor revset
+// function, it chooses the merge base with the smallest node ID if
+// there are several (e.g., after criss-cross merges).
+func (r *Repository) MergeBaseContext(ctx context.Context, a, b vcs.CommitID) (vcs.CommitID, error) {
+	bases, err := r.MergeBasesContext(ctx, a, b)
+	if err != nil {
+		return "", err
+	}
+	if len(bases) == 0 {
+		return "", fmt.Errorf("commits %s and %s have no common ancestor", a, b)
+	}
+	return bases[0], nil
+}
+
+func (r *Repository) MergeBases(a, b vcs.CommitID) ([]vcs.CommitID, error) {
+	return r.MergeBasesContext(context.Background(), a, b)
+}
+
+func (r *Repository) MergeBasesContext(ctx context.Context, a, b vcs.CommitID) ([]vcs.CommitID, error) {
+	return r.OctopusMergeBasesContext(ctx, []vcs.CommitID{a, b})
+}
+
+func (r *Repository) OctopusMergeBases(commits []vcs.CommitID) ([]vcs.CommitID, error) {
+	return r.OctopusMergeBasesContext(context.Background(), commits)
+}
+
+// OctopusMergeBasesContext returns the heads of the common ancestors of
+// commits by walking the parents in the changelog.
+func (r *Repository) OctopusMergeBasesContext(ctx context.Context, commits []vcs.CommitID) ([]vcs.CommitID, error) {
+	if len(commits) == 0 {
+		return nil, errors.New("octopus merge base of no commits")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	revs := make([]int, len(commits))
+	for i, c := range commits {
+		rec, err := r.getRec(c)
+		if err != nil {
+			return nil, err
+		}
+		revs[i] = rec.FileRev()
+	}
+
+	// Each walk tells apart at most maxWalkInputs inputs, so fold the
+	// commits into the heads found so far in batches. The common
+	// ancestors of the heads and of the next commits are the common
+	// ancestors of all of those commits.
+	heads := revs[:1]
+	for rest := revs[1:]; len(rest) > 0 && len(heads) > 0; {
+		batch := rest
+		if len(batch) > maxWalkInputs-1 {
+			batch = batch[:maxWalkInputs-1]
+		}
+		rest = rest[len(batch):]
+		inputs := [][]int{heads}
+		for _, rev := range batch {
+			inputs = append(inputs, []int{rev})
+		}
+		var err error
+		if heads, err = r.commonAncestorHeads(ctx, inputs); err != nil {
+			return nil, err
+		}
+	}
+
+	bases := make([]vcs.CommitID, len(heads))
+	for i, rev := range heads {
+		rec, err := hg_revlog.FileRevSpec(rev).Lookup(r.cl)
+		if err != nil {
+			return nil, err
+		}
+		bases[i] = vcs.CommitID(hex.EncodeToString(rec.Id()))
+	}
+	sort.Sort(vcs.CommitIDs(bases))
+	return bases, nil
+}
+
+// maxWalkInputs is the number of inputs that commonAncestorHeads can
+// tell apart: the bits of a walkMask, except for poisoned.
+const maxWalkInputs = 63
+
+// A walkMask is the set of inputs of commonAncestorHeads that reach a
+// revision (bit i is set if inputs[i] reaches it).
+type walkMask uint64
+
+// poisoned marks the ancestors of the common ancestor heads found so
+// far, which can't be heads themselves.
+const poisoned walkMask = 1 << maxWalkInputs
+
+// commonAncestorHeads returns the heads of the common ancestors of
+// inputs, which are sets of revisions (a revision is an ancestor of a
+// set if it is an ancestor of any of its revisions). The heads are the
+// common ancestors that aren't ancestors of another common ancestor.
+//
+// Parents always have lower revision numbers than their children, so
+// it visits the revisions by descending number, marking which inputs
+// reach each revision: a revision is visited after all of its
+// descendants in the walk, so its mark is complete. The first
+// revisions that all of the inputs reach are the heads, and their
+// ancestors are poisoned. The walk stops as soon as all of the
+// revisions left to visit are poisoned, like hg's
+// ancestor.commonancestorsheads, instead of reading the whole history.
+func (r *Repository) commonAncestorHeads(ctx context.Context, inputs [][]int) ([]int, error) {
+	all := walkMask(1)<<uint(len(inputs)) - 1
+	marks := map[int]walkMask{}
+	var h revHeap
+	for i, revs := range inputs {
+		for _, rev := range revs {
+			if marks[rev] == 0 {
+				h.push(rev)
+			}
+			marks[rev] |= 1 << uint(i)
+		}
+	}
+
+	var heads []int
+	interesting := len(h) // revisions to visit that aren't poisoned
+	for interesting > 0 {
+		// Walking the changelog of a large repository can take a
+		// while, so check ctx on each iteration.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		rev := h.pop()
+		mark := marks[rev]
+		if mark&poisoned == 0 {
+			interesting--
+			if mark == all {
+				heads = append(heads, rev)
+				mark |= poisoned
+			}
+		}
+
+		rec, err := hg_revlog.FileRevSpec(rev).Lookup(r.cl)
+		if err != nil {
+			return nil, err
+		}
+		if rec.IsStartOfBranch() {
+			continue
+		}
+		parents := []*hg_revlog.Rec{rec.Parent()}
+		if rec.Parent2Present() {
+			parents = append(parents, rec.Parent2())
+		}
+		for _, p := range parents {
+			if p == nil || p.IsNull() {
+				continue
+			}
+			prev := p.FileRev()
+			pmark, seen := marks[prev]
+			switch {
+			case !seen:
+				h.push(prev)
+				if mark&poisoned == 0 {
+					interesting++
+				}
+			case pmark&poisoned == 0 && mark&poisoned != 0:
+				interesting--
+			}
+			if mark&poisoned != 0 {
+				// The ancestors of a head can't be heads, so
+				// which inputs reach them doesn't matter.
+				marks[prev] = poisoned
+			} else {
+				marks[prev] = pmark | mark
+			}
+		}
+	}
+	return heads, nil
+}
@@ -2,6 +2,8 @@ package hg
 
 import (
 	"bytes"
+	"container/heap"
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -78,6 +80,14 @@ func (r *Repository) Close() error {
 }
 
 func (r *Repository) ResolveRevision(spec string) (vcs.CommitID, error) {
+	return r.ResolveRevisionContext(context.Background(), spec)
+}
+
+func (r *Repository) ResolveRevisionContext(ctx context.Context, spec string) (vcs.CommitID, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	if id, err := r.ResolveBranch(spec); err == nil {
 		return id, nil
 	}
@@ -96,6 +106,14 @@ func (r *Repository) ResolveRevision(spec string) (vcs.CommitID, error) {
 }
 
 func (r *Repository) ResolveTag(name string) (vcs.CommitID, error) {
+	return r.ResolveTagContext(context.Background(), name)
+}
+
+func (r *Repository) ResolveTagContext(ctx context.Context, name string) (vcs.CommitID, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	if id, ok := r.allTags.IdByName[name]; ok {
 		return vcs.CommitID(id), nil
 	}
@@ -103,6 +121,14 @@ func (r *Repository) ResolveTag(name string) (vcs.CommitID, error) {
 }
 
 func (r *Repository) ResolveBranch(name string) (vcs.CommitID, error) {
+	return r.ResolveBranchContext(context.Background(), name)
+}
+
+func (r *Repository) ResolveBranchContext(ctx context.Context, name string) (vcs.CommitID, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	if id, ok := r.branchHeads.IdByName[name]; ok {
 		return vcs.CommitID(id), nil
 	}
@@ -110,6 +136,14 @@ func (r *Repository) ResolveBranch(name string) (vcs.CommitID, error) {
 }
 
 func (r *Repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
+	return r.BranchesContext(context.Background(), opt)
+}
+
+func (r *Repository) BranchesContext(ctx context.Context, opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	if opt.ContainsCommit != "" {
 		return nil, fmt.Errorf("vcs.BranchesOptions.ContainsCommit option not implemented")
 	}
@@ -125,6 +159,14 @@ func (r *Repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
 }
 
 func (r *Repository) Tags() ([]*vcs.Tag, error) {
+	return r.TagsContext(context.Background())
+}
+
+func (r *Repository) TagsContext(ctx context.Context) ([]*vcs.Tag, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	ts := make([]*vcs.Tag, len(r.allTags.IdByName))
 	i := 0
 	for name, id := range r.allTags.IdByName {
@@ -144,6 +186,14 @@ func (r *Repository) getRec(id vcs.CommitID) (*hg_revlog.Rec, error) {
 }
 
 func (r *Repository) GetCommit(id vcs.CommitID) (*vcs.Commit, error) {
+	return r.GetCommitContext(context.Background(), id)
+}
+
+func (r *Repository) GetCommitContext(ctx context.Context, id vcs.CommitID) (*vcs.Commit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	rec, err := r.getRec(id)
 	if err != nil {
 		return nil, err
@@ -152,48 +202,288 @@ func (r *Repository) GetCommit(id vcs.CommitID) (*vcs.Commit, error) {
 }
 
 func (r *Repository) Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
-	rec, err := r.getRec(opt.Head)
+	return r.CommitsContext(context.Background(), opt)
+}
+
+func (r *Repository) CommitsContext(ctx context.Context, opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
+	if opt.Path != "" || !nativeCommitsOptions(opt) {
+		return r.Repository.CommitsContext(ctx, opt)
+	}
+
+	// Like hgcmd, list only the ancestors of Head (and not all
+	// lower-numbered revisions).
+	all := opt
+	all.N, all.Skip = 0, 0
+	it, err := r.WalkCommitsContext(ctx, all)
 	if err != nil {
 		return nil, 0, err
 	}
+	defer it.Close()
+	return internal.ListCommits(it, opt)
+}
 
-	var commits []*vcs.Commit
-	total := uint(0)
-	for ; ; rec = rec.Prev() {
-		if total >= opt.Skip && (opt.N == 0 || uint(len(commits)) < opt.N) {
-			c, err := r.makeCommit(rec)
-			if err != nil {
-				return nil, 0, err
+func (r *Repository) WalkCommits(opt vcs.CommitsOptions) (vcs.CommitIterator, error) {
+	return r.WalkCommitsContext(context.Background(), opt)
+}
+
+func (r *Repository) WalkCommitsContext(ctx context.Context, opt vcs.CommitsOptions) (vcs.CommitIterator, error) {
+	if !nativeCommitsOptions(opt) {
+		return r.Repository.WalkCommitsContext(ctx, opt)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rec, err := r.getRec(opt.Head)
+	if err != nil {
+		return nil, err
+	}
+	it := &commitIterator{ctx: ctx, r: r, opt: opt}
+	it.heads.push(rec.FileRev())
+	if opt.Base != "" {
+		base, err := r.getRec(opt.Base)
+		if err != nil {
+			return nil, err
+		}
+		it.bases.push(base.FileRev())
+	}
+	return it, nil
+}
+
+func (r *Repository) CommitsPage(opt vcs.CommitsOptions) ([]*vcs.Commit, string, error) {
+	return r.CommitsPageContext(context.Background(), opt)
+}
+
+// CommitsPageContext walks the changelog like WalkCommits. The cursor
+// is an internal.HgCommitsCursor.
+func (r *Repository) CommitsPageContext(ctx context.Context, opt vcs.CommitsOptions) ([]*vcs.Commit, string, error) {
+	if !nativeCommitsOptions(opt) {
+		// The hgcmd cursors have the same format.
+		return r.Repository.CommitsPageContext(ctx, opt)
+	}
+
+	n := opt.N
+	opt.N = 0 // counted below, so that the iterator stops at the next record
+	itr, err := r.WalkCommitsContext(ctx, opt)
+	if err != nil {
+		return nil, "", err
+	}
+	it := itr.(*commitIterator)
+	if opt.Cursor != "" {
+		cur, err := internal.ParseHgCommitsCursor(opt.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		it.heads, it.bases = nil, nil
+		for _, rev := range append(cur.Heads, cur.Bases...) {
+			if _, err := hg_revlog.FileRevSpec(rev).Lookup(r.cl); err != nil {
+				return nil, "", vcs.ErrInvalidCursor
 			}
-			commits = append(commits, c)
 		}
-		total++
+		for _, rev := range cur.Heads {
+			it.heads.push(rev)
+		}
+		for _, rev := range cur.Bases {
+			it.bases.push(rev)
+		}
+		it.opt.Skip = 0
+	}
 
-		if rec.IsStartOfBranch() {
+	var commits []*vcs.Commit
+	for n == 0 || uint(len(commits)) < n {
+		c := it.Next()
+		if c == nil {
 			break
 		}
-		// If we want total, keep going until the end.
-		if !opt.NoTotal {
+		commits = append(commits, c)
+	}
+	if err := it.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var nextCursor string
+	if len(it.heads) > 0 {
+		nextCursor = (&internal.HgCommitsCursor{Heads: it.heads, Bases: it.bases}).String()
+	}
+	return commits, nextCursor, nil
+}
+
+// nativeCommitsOptions reports whether opt uses only the
+// CommitsOptions that the native changelog walk supports. Otherwise,
+// listing commits falls back to hgcmd.
+func nativeCommitsOptions(opt vcs.CommitsOptions) bool {
+	return opt.Author == "" && opt.Committer == "" && opt.Message == "" &&
+		opt.Since.IsZero() && opt.Until.IsZero() &&
+		!opt.FirstParent && !opt.Merges && !opt.NoMerges && opt.Order == ""
+}
+
+// commitIterator walks the ancestors of a record by descending
+// revision number, like hgcmd's ancestors() revsets. A revision's
+// parents have lower revision numbers than the revision itself, so
+// the revisions that are left to visit are the ancestors of heads.
+type commitIterator struct {
+	ctx context.Context
+	r   *Repository
+	opt vcs.CommitsOptions
+
+	heads revHeap // revisions to visit next
+	bases revHeap // revisions whose ancestors are excluded (reachable from opt.Base)
+	n     uint    // number of matching commits seen so far
+	err   error
+}
+
+func (it *commitIterator) Next() *vcs.Commit {
+	for len(it.heads) > 0 {
+		if it.opt.N != 0 && it.n >= it.opt.Skip+it.opt.N {
+			it.heads = nil
+			break
+		}
+		// Walking the changelog of a large repository can take a
+		// while, so check ctx on each iteration.
+		if err := it.ctx.Err(); err != nil {
+			it.heads, it.err = nil, err
+			break
+		}
+
+		rev := it.heads.pop()
+		rec, err := it.pushParents(&it.heads, rev)
+		if err != nil {
+			it.heads, it.err = nil, err
+			break
+		}
+		// Pass the bases that are not lower than rev, so that rev is
+		// excluded if and only if it is the greatest remaining base.
+		for len(it.bases) > 0 && it.bases[0] > rev {
+			if _, err := it.pushParents(&it.bases, it.bases.pop()); err != nil {
+				it.heads, it.err = nil, err
+				return nil
+			}
+		}
+		if len(it.bases) > 0 && it.bases[0] == rev {
 			continue
 		}
-		// Otherwise return once N has been satisfied.
-		if opt.N != 0 && uint(len(commits)) >= opt.N {
+
+		ce, err := buildChangelogEntry(rec)
+		if err != nil {
+			it.heads, it.err = nil, err
 			break
 		}
+		if it.opt.Path != "" && !touchesPath(ce, it.opt.Path) {
+			continue
+		}
+		it.n++
+		if it.n <= it.opt.Skip {
+			continue
+		}
+		return makeCommitFromEntry(rec, ce)
 	}
-	if opt.NoTotal {
-		total = 0
+	return nil
+}
+
+// pushParents pushes the parents of revision rev onto h and returns
+// rev's record.
+func (it *commitIterator) pushParents(h *revHeap, rev int) (*hg_revlog.Rec, error) {
+	rec, err := hg_revlog.FileRevSpec(rev).Lookup(it.r.cl)
+	if err != nil {
+		return nil, err
 	}
-	return commits, total, nil
+	if !rec.IsStartOfBranch() {
+		parents := []*hg_revlog.Rec{rec.Parent()}
+		if rec.Parent2Present() {
+			parents = append(parents, rec.Parent2())
+		}
+		for _, p := range parents {
+			if p != nil && !p.IsNull() {
+				h.push(p.FileRev())
+			}
+		}
+	}
+	return rec, nil
 }
 
-func (r *Repository) makeCommit(rec *hg_revlog.Rec) (*vcs.Commit, error) {
+func (it *commitIterator) Err() error { return it.err }
+
+func (it *commitIterator) Close() error {
+	it.heads = nil
+	return nil
+}
+
+// revHeap is a max-heap of revision numbers.
+type revHeap []int
+
+func (h revHeap) Len() int            { return len(h) }
+func (h revHeap) Less(i, j int) bool  { return h[i] > h[j] }
+func (h revHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *revHeap) Push(x interface{}) { *h = append(*h, x.(int)) }
+func (h *revHeap) Pop() interface{} {
+	rev := (*h)[len(*h)-1]
+	*h = (*h)[:len(*h)-1]
+	return rev
+}
+
+func (h *revHeap) push(rev int) { heap.Push(h, rev) }
+
+// pop removes and returns the greatest revision, along with its
+// duplicates (which are added when a revision has multiple children).
+func (h *revHeap) pop() int {
+	rev := heap.Pop(h).(int)
+	for len(*h) > 0 && (*h)[0] == rev {
+		heap.Pop(h)
+	}
+	return rev
+}
+
+// ancestorRevs returns the set of revision numbers of rec and all of
+// its ancestors.
+func ancestorRevs(rec *hg_revlog.Rec) map[int]struct{} {
+	revs := map[int]struct{}{}
+	stack := []*hg_revlog.Rec{rec}
+	for len(stack) > 0 {
+		rec := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if rec == nil || rec.IsNull() {
+			continue
+		}
+		if _, seen := revs[rec.FileRev()]; seen {
+			continue
+		}
+		revs[rec.FileRev()] = struct{}{}
+		if !rec.IsStartOfBranch() {
+			stack = append(stack, rec.Parent())
+			if rec.Parent2Present() {
+				stack = append(stack, rec.Parent2())
+			}
+		}
+	}
+	return revs
+}
+
+// touchesPath reports whether the changeset modified path or, if path
+// is a directory, any file in it.
+func touchesPath(ce *hg_changelog.Entry, path string) bool {
+	path = strings.TrimSuffix(path, "/")
+	for _, f := range ce.Files {
+		if f == path || strings.HasPrefix(f, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func buildChangelogEntry(rec *hg_revlog.Rec) (*hg_changelog.Entry, error) {
 	fb := hg_revlog.NewFileBuilder()
-	ce, err := hg_changelog.BuildEntry(rec, fb)
+	return hg_changelog.BuildEntry(rec, fb)
+}
+
+func (r *Repository) makeCommit(rec *hg_revlog.Rec) (*vcs.Commit, error) {
+	ce, err := buildChangelogEntry(rec)
 	if err != nil {
 		return nil, err
 	}
+	return makeCommitFromEntry(rec, ce), nil
+}
 
+func makeCommitFromEntry(rec *hg_revlog.Rec, ce *hg_changelog.Entry) *vcs.Commit {
 	addr, err := mail.ParseAddress(ce.Committer)
 	if err != nil {
 		// This occurs when the commit author specifier is
@@ -220,16 +510,25 @@ func (r *Repository) makeCommit(rec *hg_revlog.Rec) (*vcs.Commit, error) {
 		Author:  vcs.Signature{addr.Name, addr.Address, pbtypes.NewTimestamp(ce.Date)},
 		Message: ce.Comment,
 		Parents: parents,
-	}, nil
+	}
 }
 
 func (r *Repository) FileSystem(at vcs.CommitID) (vfs.FileSystem, error) {
+	return r.FileSystemContext(context.Background(), at)
+}
+
+func (r *Repository) FileSystemContext(ctx context.Context, at vcs.CommitID) (vfs.FileSystem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	rec, err := r.getRec(at)
 	if err != nil {
 		return nil, err
 	}
 
 	return &hgFSNative{
+		ctx:  ctx,
 		dir:  r.Dir,
 		at:   hg_revlog.FileRevSpec(rec.FileRev()),
 		repo: r.u,
@@ -260,6 +559,7 @@ func (r *Repository) parseRevisionSpec(s string) hg_revlog.RevisionSpec {
 }
 
 type hgFSNative struct {
+	ctx  context.Context // operations fail once ctx is done
 	dir  string
 	at   hg_revlog.FileRevSpec
 	repo *hgo.Repository
@@ -317,11 +617,20 @@ func (fs *hgFSNative) getEntry(path string) (*hg_revlog.Rec, *hg_store.ManifestE
 		return nil, nil, err
 	}
 
-	// Lookup record in revlog
-	entId, err := ent.Id()
+	rec, err := fs.fileRec(fileLog, ent)
 	if err != nil {
 		return nil, nil, err
 	}
+	return rec, ent, nil
+}
+
+// fileRec looks up the record of a manifest entry in its file's
+// revlog.
+func (fs *hgFSNative) fileRec(fileLog *hg_revlog.Index, ent *hg_store.ManifestEnt) (*hg_revlog.Rec, error) {
+	entId, err := ent.Id()
+	if err != nil {
+		return nil, err
+	}
 	linkRevSpec := hg_revlog.LinkRevSpec{
 		Rev: int(fs.at),
 		FindPresent: func(maybeAncestors []*hg_revlog.Rec) (index int, err error) {
@@ -355,11 +664,11 @@ func (fs *hgFSNative) getEntry(path string) (*hg_revlog.Rec, *hg_store.ManifestE
 		linkRevSpec.FindPresent = nil
 		rec, err = linkRevSpec.Lookup(fileLog)
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 	}
 	if rec.FileRev() == -1 {
-		return nil, nil, hg_revlog.ErrRevisionNotFound
+		return nil, hg_revlog.ErrRevisionNotFound
 	}
 
 	if int(rec.Linkrev) == int(fs.at) {
@@ -367,19 +676,22 @@ func (fs *hgFSNative) getEntry(path string) (*hg_revlog.Rec, *hg_store.ManifestE
 		// used as a sign that the file exists. (TODO(sqs): original comments
 		// say maybe this means the file is NOT existent yet? the word "not" is
 		// not there but that seems to be a mistake.)
-		return rec, ent, nil
+		return rec, nil
 	}
 
 	if !rec.IsLeaf() {
 		// There are other records that have the current record as a parent.
 		// This means, the file was existent, no need to check the manifest.
-		return rec, ent, nil
+		return rec, nil
 	}
 
-	return rec, ent, nil
+	return rec, nil
 }
 
 func (fs *hgFSNative) Open(name string) (vfs.ReadSeekCloser, error) {
+	if err := fs.ctx.Err(); err != nil {
+		return nil, err
+	}
 	name = internal.Rel(name)
 	rec, _, err := fs.getEntry(name)
 	if err != nil {
@@ -413,6 +725,9 @@ func (fs *hgFSNative) getModTime() (time.Time, error) {
 }
 
 func (fs *hgFSNative) Lstat(path string) (os.FileInfo, error) {
+	if err := fs.ctx.Err(); err != nil {
+		return nil, err
+	}
 	fi, _, err := fs.lstat(path)
 	return fi, err
 }
@@ -444,6 +759,9 @@ func (fs *hgFSNative) lstat(path string) (*util.FileInfo, []byte, error) {
 }
 
 func (fs *hgFSNative) Stat(path string) (os.FileInfo, error) {
+	if err := fs.ctx.Err(); err != nil {
+		return nil, err
+	}
 	path = internal.Rel(path)
 	fi, data, err := fs.lstat(path)
 	if err != nil {
@@ -524,6 +842,9 @@ func (fs *hgFSNative) fileInfo(ent *hg_store.ManifestEnt) *util.FileInfo {
 }
 
 func (fs *hgFSNative) ReadDir(path string) ([]os.FileInfo, error) {
+	if err := fs.ctx.Err(); err != nil {
+		return nil, err
+	}
 	path = internal.Rel(path)
 	m, err := fs.getManifest(fs.at)
 	if err != nil {
@@ -0,0 +1,155 @@
+package hg
+
+import (
+	"context"
+
+	hg_store "github.com/beyang/hgo/store"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/internal"
+)
+
+func (r *Repository) Search(at vcs.CommitID, opt vcs.SearchOptions) ([]*vcs.SearchResult, error) {
+	return r.SearchContext(context.Background(), at, opt)
+}
+
+func (r *Repository) SearchContext(ctx context.Context, at vcs.CommitID, opt vcs.SearchOptions) ([]*vcs.SearchResult, error) {
+	page := internal.NewSearchPage(opt)
+	if err := r.search(ctx, at, opt, page); err != nil {
+		return nil, err
+	}
+	return page.Results, nil
+}
+
+func (r *Repository) StreamSearch(at vcs.CommitID, opt vcs.SearchOptions, fn func(*vcs.SearchResult) bool) (*vcs.SearchStats, error) {
+	return r.StreamSearchContext(context.Background(), at, opt, fn)
+}
+
+func (r *Repository) StreamSearchContext(ctx context.Context, at vcs.CommitID, opt vcs.SearchOptions, fn func(*vcs.SearchResult) bool) (*vcs.SearchStats, error) {
+	page := internal.NewSearchStream(opt, fn)
+	if err := r.search(ctx, at, opt, page); err != nil {
+		return nil, err
+	}
+	return &page.Stats, nil
+}
+
+// search searches the files of the manifest at the commit, reading
+// them from their revlogs, with the same semantics as hgcmd and the
+// git backends, until page is full.
+func (r *Repository) search(ctx context.Context, at vcs.CommitID, opt vcs.SearchOptions, page *internal.SearchPage) error {
+	m, err := internal.CompileSearch(opt)
+	if err != nil {
+		return err
+	}
+
+	fs, manifest, err := r.searchManifest(ctx, at)
+	if err != nil {
+		return err
+	}
+
+	for i := range manifest {
+		// Reading every file of a large repository can take a while,
+		// so check ctx for each file.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		ent := &manifest[i]
+		if !m.MatchPath(ent.FileName) {
+			continue
+		}
+		if page.Full() {
+			page.Truncate()
+			break
+		}
+		data, err := r.readManifestFile(fs, ent)
+		if err != nil {
+			return err
+		}
+		if !m.MatchSize(int64(len(data))) || internal.IsBinary(data) {
+			continue
+		}
+		page.Stats.FilesScanned++
+		page.Add(m.SearchFile(ent.FileName, data, int(opt.ContextLines)))
+	}
+	return nil
+}
+
+func (r *Repository) SearchRevisions(revs []vcs.CommitID, opt vcs.SearchOptions) ([]*vcs.SearchResult, error) {
+	return r.SearchRevisionsContext(context.Background(), revs, opt)
+}
+
+// SearchRevisionsContext searches the files of the manifest at each
+// revision, reading each file revision (identified by its node ID)
+// only once.
+func (r *Repository) SearchRevisionsContext(ctx context.Context, revs []vcs.CommitID, opt vcs.SearchOptions) ([]*vcs.SearchResult, error) {
+	m, err := internal.CompileSearch(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	s := internal.NewMultiSearch(m, opt)
+	for _, rev := range revs {
+		fs, manifest, err := r.searchManifest(ctx, rev)
+		if err != nil {
+			return nil, err
+		}
+		for i := range manifest {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			ent := &manifest[i]
+			if !m.MatchPath(ent.FileName) {
+				continue
+			}
+			nodeID, err := ent.Id()
+			if err != nil {
+				return nil, err
+			}
+			id := string(nodeID)
+			if !s.Searched(id) {
+				data, err := r.readManifestFile(fs, ent)
+				if err != nil {
+					return nil, err
+				}
+				s.AddContents(id, ent.FileName, data)
+			}
+			s.AddFile(rev, ent.FileName, id)
+		}
+	}
+	return s.Results(), nil
+}
+
+// searchManifest returns the native file system and the manifest at
+// the commit.
+func (r *Repository) searchManifest(ctx context.Context, at vcs.CommitID) (*hgFSNative, hg_store.Manifest, error) {
+	fsys, err := r.FileSystemContext(ctx, at)
+	if err != nil {
+		return nil, nil, err
+	}
+	fs := fsys.(*hgFSNative)
+	manifest, err := fs.getManifest(fs.at)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fs, manifest, nil
+}
+
+// readManifestFile reads the contents of the file of a manifest entry
+// from its revlog, without its copy metadata.
+func (r *Repository) readManifestFile(fs *hgFSNative, ent *hg_store.ManifestEnt) ([]byte, error) {
+	fileLog, err := r.st.OpenRevlog(ent.FileName)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := fs.fileRec(fileLog, ent)
+	if err != nil {
+		return nil, err
+	}
+	data, err := fs.readFile(rec)
+	if err != nil {
+		return nil, err
+	}
+	_, data = splitFileMeta(data)
+	return data, nil
+}
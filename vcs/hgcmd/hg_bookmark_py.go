@@ -0,0 +1,59 @@
+package hgcmd
+
+// hgBookmarkPy atomically updates a bookmark. It takes the repository
+// directory, the bookmark name, and its old and new commit IDs (see
+// vcs.RefUpdater.UpdateRef), and writes a JSON result with an Error
+// ("notfound" if the new commit doesn't exist, or "refchanged" if the
+// bookmark doesn't point to the old commit), if any.
+var hgBookmarkPy = `
+import json, sys
+from mercurial import error, hg, node, scmutil, ui as uimod
+
+def b(s):
+    if isinstance(s, bytes):
+        return s
+    return s.encode('utf-8')
+
+def done(result):
+    sys.stdout.write(json.dumps(result))
+    sys.exit(0)
+
+repo = hg.repository(uimod.ui.load(), b(sys.argv[1]))
+name, old, new = b(sys.argv[2]), sys.argv[3], sys.argv[4]
+
+def revsingle(spec):
+    # repo[spec] only accepts revision numbers, full nodes and a few
+    # reserved names, so resolve other specs (short hashes, bookmarks,
+    # branches, revsets) like hg's -r options do.
+    try:
+        return scmutil.revsingle(repo, b(spec))
+    except (error.RepoLookupError, error.LookupError, error.ParseError):
+        done({'Error': 'notfound'})
+
+wlock = repo.wlock()
+lock = repo.lock()
+try:
+    newnode = None
+    if new:
+        newnode = revsingle(new).node()
+
+    marks = repo._bookmarks
+    cur = ''
+    if name in marks:
+        cur = node.hex(marks[name]).decode('ascii')
+    if cur != old:
+        done({'Error': 'refchanged'})
+
+    if old or new:
+        tr = repo.transaction(b'bookmark')
+        try:
+            marks.applychanges(repo, tr, [(name, newnode)])
+            tr.close()
+        finally:
+            tr.release()
+finally:
+    lock.release()
+    wlock.release()
+
+done({})
+`
@@ -0,0 +1,84 @@
+package hgcmd
+
+// hgCommitPy creates a commit with Mercurial's in-memory commit
+// context (memctx), without a working directory. It takes the
+// repository directory and the name of a JSON file of commit options
+// (see CreateCommitContext), and writes a JSON result: the new commit's
+// Node, or an Error ("notfound" if the parent doesn't exist,
+// "notexist" if a changed file (Path) doesn't exist, or "refchanged"
+// if the bookmark to update doesn't point to the parent).
+var hgCommitPy = `
+import base64, json, sys
+from mercurial import context, error, hg, node, scmutil, ui as uimod
+
+def b(s):
+    if isinstance(s, bytes):
+        return s
+    return s.encode('utf-8')
+
+def done(result):
+    sys.stdout.write(json.dumps(result))
+    sys.exit(0)
+
+repo = hg.repository(uimod.ui.load(), b(sys.argv[1]))
+with open(sys.argv[2]) as f:
+    opt = json.load(f)
+
+def revsingle(spec):
+    # repo[spec] only accepts revision numbers, full nodes and a few
+    # reserved names, so resolve other specs (short hashes, bookmarks,
+    # branches, revsets) like hg's -r options do.
+    try:
+        return scmutil.revsingle(repo, b(spec))
+    except (error.RepoLookupError, error.LookupError, error.ParseError):
+        done({'Error': 'notfound'})
+
+wlock = repo.wlock()
+lock = repo.lock()
+try:
+    pctx = revsingle(opt['Parent'] or 'null')
+
+    changes = {}
+    for c in opt['Changes']:
+        path = b(c['Path'])
+        if (c['Delete'] or c['Contents'] is None) and path not in pctx:
+            done({'Error': 'notexist', 'Path': c['Path']})
+        changes[path] = c
+
+    ref = b(opt['Ref'] or '')
+    if ref:
+        want = pctx.node() if opt['Parent'] else None
+        if repo._bookmarks.get(ref) != want:
+            done({'Error': 'refchanged'})
+
+    def filectxfn(repo, mctx, path):
+        c = changes[path]
+        if c['Delete']:
+            return None
+        if c['Contents'] is None:
+            data = pctx[path].data()
+        else:
+            data = base64.b64decode(c['Contents'])
+        flags = c['Flags']
+        if flags is None:
+            flags = pctx[path].flags() if path in pctx else b''
+        flags = b(flags)
+        return context.memfilectx(repo, mctx, path, data, islink=b'l' in flags, isexec=b'x' in flags)
+
+    mctx = context.memctx(repo, (pctx.node(), node.nullid), b(opt['Message']), sorted(changes), filectxfn,
+                          user=b(opt['User']), date=(opt['Date'], 0), extra={b'branch': pctx.branch()})
+    n = mctx.commit()
+
+    if ref:
+        tr = repo.transaction(b'bookmark')
+        try:
+            repo._bookmarks.applychanges(repo, tr, [(ref, n)])
+            tr.close()
+        finally:
+            tr.release()
+finally:
+    lock.release()
+    wlock.release()
+
+done({'Node': node.hex(n).decode('ascii')})
+`
@@ -42,6 +42,17 @@ DSTDIFF = DSTOFFSET - STDOFFSET
 repodir = os.path.abspath(sys.argv[1])
 v = sys.argv[2]
 files = sys.argv[3:]
+
+# Options (before the files): --skip=REV skips the given revision
+# (attributing its changes to the previous revisions that changed the
+# lines).
+skipRevs = []
+while files and files[0].startswith('--skip='):
+    skipRevs.append(files[0][len('--skip='):])
+    files = files[1:]
+skipArgs = []
+for rev in skipRevs:
+    skipArgs += ['--skip', rev]
 explicitFiles = []
 if len(files) > 0:
     # sys.stderr.write("Using %d files specified on command line: %r\n" % (len(files), files))
@@ -91,14 +102,17 @@ for file in files:
     lineno = 1
     hunk = None
     byteOffsetInFile = 0
-    for (info, contents) in client.annotate(files=['--debug', '--', filepath], rev=v, changeset=True):
-        changeset = info.strip()
+    for (info, contents) in client.annotate(files=['--debug'] + skipArgs + ['--', filepath], rev=v, changeset=True, file=True, line=True):
+        # info is "changeset origpath:origline".
+        changeset, origpathline = info.strip().split(' ', 1)
+        origpath, origline = origpathline.rsplit(':', 1)
+        origline = int(origline)
         startNewHunk = False
         advanceCurrentHunk = False
         if hunk is None:
             startNewHunk = True
             byteOffsetInFile = 0
-        elif changeset != hunk['CommitID']:
+        elif changeset != hunk['CommitID'] or origpath != hunk['OrigPath'] or origline != hunk['OrigStartLine'] + (hunk['EndLine'] - hunk['StartLine']):
             addHunk(file, hunk)
             startNewHunk = True
         else:
@@ -111,6 +125,8 @@ for file in files:
                 'EndLine': lineno + 1,
                 'StartByte': byteOffsetInFile,
                 'EndByte': byteOffsetInFile+len(contents)+1,
+                'OrigPath': origpath,
+                'OrigStartLine': origline,
             }
         
         byteOffsetInFile += len(contents) + 1 # +1 for newline
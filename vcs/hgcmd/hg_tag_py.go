@@ -0,0 +1,79 @@
+package hgcmd
+
+// hgTagPy adds or removes a global tag by committing the change to
+// .hgtags with an in-memory commit context (memctx), on top of the head
+// of the tagged commit's branch. Unlike hg tag, it doesn't use the
+// working directory, so it works in repositories without a checkout.
+// It takes the repository directory, the tag name, the commit ID to tag
+// (or "" to remove the tag), and the commit's user ("" for the
+// configured user), date (0 for now) and message ("" for hg tag's
+// default). It writes a JSON result with an Error ("notfound" if the
+// commit doesn't exist, "refchanged" if the tag to add already exists,
+// or "notexist" if the tag to remove isn't a global tag), if any.
+var hgTagPy = `
+import json, sys, time
+from mercurial import context, error, hg, node, scmutil, ui as uimod
+
+def b(s):
+    if isinstance(s, bytes):
+        return s
+    return s.encode('utf-8')
+
+def done(result):
+    sys.stdout.write(json.dumps(result))
+    sys.exit(0)
+
+repo = hg.repository(uimod.ui.load(), b(sys.argv[1]))
+name, rev, user, date, message = b(sys.argv[2]), sys.argv[3], b(sys.argv[4]), int(sys.argv[5]), b(sys.argv[6])
+
+def revsingle(spec):
+    # repo[spec] only accepts revision numbers, full nodes and a few
+    # reserved names, so resolve other specs (short hashes, bookmarks,
+    # branches, revsets) like hg's -r options do.
+    try:
+        return scmutil.revsingle(repo, b(spec))
+    except (error.RepoLookupError, error.LookupError, error.ParseError):
+        done({'Error': 'notfound'})
+
+wlock = repo.wlock()
+lock = repo.lock()
+try:
+    tags = repo.tags()
+    if rev:
+        if name in tags:
+            done({'Error': 'refchanged'})
+        ctx = revsingle(rev)
+        tagnode = ctx.node()
+        if not message:
+            message = b'Added tag %s for changeset %s' % (name, node.short(tagnode))
+    else:
+        if repo.tagtype(name) != b'global':
+            done({'Error': 'notexist'})
+        ctx = repo[tags[name]]
+        tagnode = node.nullid
+        if not message:
+            message = b'Removed tag %s' % name
+
+    pctx = repo[repo.branchtip(ctx.branch())]
+    data = b''
+    if b'.hgtags' in pctx:
+        data = pctx[b'.hgtags'].data()
+    if data and not data.endswith(b'\n'):
+        data += b'\n'
+    if name in tags:
+        data += b'%s %s\n' % (node.hex(tags[name]), name)
+    data += b'%s %s\n' % (node.hex(tagnode), name)
+
+    def filectxfn(repo, mctx, path):
+        return context.memfilectx(repo, mctx, path, data)
+
+    mctx = context.memctx(repo, (pctx.node(), node.nullid), message, [b'.hgtags'], filectxfn,
+                          user=user or repo.ui.username(), date=(date or int(time.time()), 0),
+                          extra={b'branch': pctx.branch()})
+    mctx.commit()
+finally:
+    lock.release()
+    wlock.release()
+
+done({})
+`
@@ -3,9 +3,11 @@ package hgcmd
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -14,6 +16,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/sourcegraph/go-diff/diff"
@@ -76,8 +79,22 @@ func (r *Repository) RepoDir() string {
 	return r.Dir
 }
 
+// ctxErr returns ctx.Err() if ctx is done, and err otherwise. Commands
+// created with exec.CommandContext are killed when ctx is done, so
+// their errors are only a symptom of the context error.
+func ctxErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
 func (r *Repository) ResolveRevision(spec string) (vcs.CommitID, error) {
-	cmd := exec.Command("hg", "identify", "--debug", "-i", "--rev="+spec)
+	return r.ResolveRevisionContext(context.Background(), spec)
+}
+
+func (r *Repository) ResolveRevisionContext(ctx context.Context, spec string) (vcs.CommitID, error) {
+	cmd := exec.CommandContext(ctx, "hg", "identify", "--debug", "-i", "--rev="+spec)
 	cmd.Dir = r.Dir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -85,33 +102,45 @@ func (r *Repository) ResolveRevision(spec string) (vcs.CommitID, error) {
 		if isUnknownRevisionError(string(out), spec) {
 			return "", vcs.ErrRevisionNotFound
 		}
-		return "", fmt.Errorf("exec `hg identify` failed: %s. Output was:\n\n%s", err, out)
+		return "", ctxErr(ctx, fmt.Errorf("exec `hg identify` failed: %s. Output was:\n\n%s", err, out))
 	}
 	return vcs.CommitID(bytes.TrimSpace(out)), nil
 }
 
 func (r *Repository) ResolveTag(name string) (vcs.CommitID, error) {
-	commitID, err := r.ResolveRevision(name)
+	return r.ResolveTagContext(context.Background(), name)
+}
+
+func (r *Repository) ResolveTagContext(ctx context.Context, name string) (vcs.CommitID, error) {
+	commitID, err := r.ResolveRevisionContext(ctx, name)
 	if err == vcs.ErrRevisionNotFound {
 		return "", vcs.ErrTagNotFound
 	}
-	return commitID, nil
+	return commitID, err
 }
 
 func (r *Repository) ResolveBranch(name string) (vcs.CommitID, error) {
-	commitID, err := r.ResolveRevision(name)
+	return r.ResolveBranchContext(context.Background(), name)
+}
+
+func (r *Repository) ResolveBranchContext(ctx context.Context, name string) (vcs.CommitID, error) {
+	commitID, err := r.ResolveRevisionContext(ctx, name)
 	if err == vcs.ErrRevisionNotFound {
 		return "", vcs.ErrBranchNotFound
 	}
-	return commitID, nil
+	return commitID, err
 }
 
 func (r *Repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
+	return r.BranchesContext(context.Background(), opt)
+}
+
+func (r *Repository) BranchesContext(ctx context.Context, opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
 	if opt.ContainsCommit != "" {
 		return nil, fmt.Errorf("vcs.BranchesOptions.ContainsCommit option not implemented")
 	}
 
-	refs, err := r.execAndParseCols("branches")
+	refs, err := r.execAndParseCols(ctx, "branches")
 	if err != nil {
 		return nil, err
 	}
@@ -127,7 +156,11 @@ func (r *Repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
 }
 
 func (r *Repository) Tags() ([]*vcs.Tag, error) {
-	refs, err := r.execAndParseCols("tags")
+	return r.TagsContext(context.Background())
+}
+
+func (r *Repository) TagsContext(ctx context.Context) ([]*vcs.Tag, error) {
+	refs, err := r.execAndParseCols(ctx, "tags")
 	if err != nil {
 		return nil, err
 	}
@@ -148,12 +181,12 @@ func (p byteSlices) Len() int           { return len(p) }
 func (p byteSlices) Less(i, j int) bool { return bytes.Compare(p[i], p[j]) < 0 }
 func (p byteSlices) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
-func (r *Repository) execAndParseCols(subcmd string) ([][2]string, error) {
-	cmd := exec.Command("hg", "-v", "--debug", subcmd)
+func (r *Repository) execAndParseCols(ctx context.Context, subcmd string) ([][2]string, error) {
+	cmd := exec.CommandContext(ctx, "hg", "-v", "--debug", subcmd)
 	cmd.Dir = r.Dir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return nil, fmt.Errorf("exec `hg -v --debug %s` failed: %s. Output was:\n\n%s", subcmd, err, out)
+		return nil, ctxErr(ctx, fmt.Errorf("exec `hg -v --debug %s` failed: %s. Output was:\n\n%s", subcmd, err, out))
 	}
 
 	out = bytes.TrimSuffix(out, []byte("\n")) // remove trailing newline
@@ -181,7 +214,11 @@ func (r *Repository) execAndParseCols(subcmd string) ([][2]string, error) {
 }
 
 func (r *Repository) GetCommit(id vcs.CommitID) (*vcs.Commit, error) {
-	commits, _, err := r.commitLog(vcs.CommitsOptions{Head: id, N: 1, NoTotal: true})
+	return r.GetCommitContext(context.Background(), id)
+}
+
+func (r *Repository) GetCommitContext(ctx context.Context, id vcs.CommitID) (*vcs.Commit, error) {
+	commits, _, err := r.commitLog(ctx, vcs.CommitsOptions{Head: id, N: 1, NoTotal: true})
 	if err != nil {
 		return nil, err
 	}
@@ -194,7 +231,11 @@ func (r *Repository) GetCommit(id vcs.CommitID) (*vcs.Commit, error) {
 }
 
 func (r *Repository) Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
-	return r.commitLog(opt)
+	return r.CommitsContext(context.Background(), opt)
+}
+
+func (r *Repository) CommitsContext(ctx context.Context, opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
+	return r.commitLog(ctx, opt)
 }
 
 var hgNullParentNodeID = []byte("0000000000000000000000000000000000000000")
@@ -203,117 +244,640 @@ func isUnknownRevisionError(output, revSpec string) bool {
 	return output == "abort: unknown revision '"+string(revSpec)+"'!"
 }
 
-func (r *Repository) commitLog(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
-	revSpec := string(opt.Head)
-	if opt.Skip != 0 {
-		revSpec += "~" + strconv.FormatUint(uint64(opt.N), 10)
+// commitLogTemplate is the `hg log` template of the commits parsed by
+// parseCommitLogEntry.
+const commitLogTemplate = `--template={node}\x00{author|person}\x00{author|email}\x00{date|rfc3339date}\x00{desc}\x00{p1node}\x00{p2node}\x00`
+
+// commitLogPartsPerCommit is the number of \x00-separated fields per
+// commit in commitLogTemplate.
+const commitLogPartsPerCommit = 7
+
+func (r *Repository) commitLog(ctx context.Context, opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
+	if opt.Author != "" || opt.Committer != "" || opt.Message != "" {
+		// The patterns are matched in Go, so count the matching
+		// commits while listing them instead of with another hg log.
+		all := opt
+		all.N, all.Skip = 0, 0
+		it, err := r.WalkCommitsContext(ctx, all)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer it.Close()
+		return internal.ListCommits(it, opt)
+	}
+
+	revset, err := commitsOrderedRevset(opt)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	args := []string{"log", `--template={node}\x00{author|person}\x00{author|email}\x00{date|rfc3339date}\x00{desc}\x00{p1node}\x00{p2node}\x00`}
+	args := []string{"log", commitLogTemplate, "--rev=" + revset}
 	if opt.N != 0 {
-		args = append(args, "--limit", strconv.FormatUint(uint64(opt.N), 10))
+		// hg log has no option to skip commits, so skip them below.
+		args = append(args, "--limit", strconv.FormatUint(uint64(opt.N+opt.Skip), 10))
 	}
-	args = append(args, "--rev="+revSpec+":0")
+	args = append(args, commitsPathArgs(opt)...)
 
-	cmd := exec.Command("hg", args...)
+	cmd := exec.CommandContext(ctx, "hg", args...)
 	cmd.Dir = r.Dir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		out = bytes.TrimSpace(out)
-		if isUnknownRevisionError(string(out), revSpec) {
+		if isUnknownRevisionError(string(out), string(opt.Head)) {
 			return nil, 0, vcs.ErrCommitNotFound
 		}
-		return nil, 0, fmt.Errorf("exec `hg log` failed: %s. Output was:\n\n%s", err, out)
+		return nil, 0, ctxErr(ctx, fmt.Errorf("exec `hg log` failed: %s. Output was:\n\n%s", err, out))
 	}
 
-	const partsPerCommit = 7 // number of \x00-separated fields per commit
 	allParts := bytes.Split(out, []byte{'\x00'})
-	numCommits := len(allParts) / partsPerCommit
-	commits := make([]*vcs.Commit, numCommits)
-	for i := 0; i < numCommits; i++ {
-		parts := allParts[partsPerCommit*i : partsPerCommit*(i+1)]
-		id := vcs.CommitID(parts[0])
-
-		authorTime, err := time.Parse(time.RFC3339, string(parts[3]))
-		if err != nil {
-			log.Println(err)
-			//return nil, 0, err
-		}
-
-		parents, err := r.getParents(id)
+	numCommits := len(allParts) / commitLogPartsPerCommit
+	var commits []*vcs.Commit
+	for i := int(opt.Skip); i < numCommits; i++ {
+		c, err := parseCommitLogEntry(allParts[commitLogPartsPerCommit*i : commitLogPartsPerCommit*(i+1)])
 		if err != nil {
-			return nil, 0, fmt.Errorf("r.GetParents failed: %s. Output was:\n\n%s", err, out)
-		}
-
-		commits[i] = &vcs.Commit{
-			ID:      id,
-			Author:  vcs.Signature{string(parts[1]), string(parts[2]), pbtypes.NewTimestamp(authorTime)},
-			Message: string(parts[4]),
-			Parents: parents,
+			return nil, 0, err
 		}
+		commits = append(commits, c)
 	}
 
 	// Count commits.
 	var total uint
 	if !opt.NoTotal {
-		cmd = exec.Command("hg", "id", "--num", "--rev="+revSpec)
+		cmd = exec.CommandContext(ctx, "hg", "log", "--template=x", "--rev="+commitsRevset(opt))
+		cmd.Args = append(cmd.Args, commitsPathArgs(opt)...)
 		cmd.Dir = r.Dir
 		out, err = cmd.CombinedOutput()
 		if err != nil {
-			return nil, 0, fmt.Errorf("exec `hg id --num` failed: %s. Output was:\n\n%s", err, out)
+			return nil, 0, ctxErr(ctx, fmt.Errorf("exec `hg log` failed: %s. Output was:\n\n%s", err, out))
 		}
-		out = bytes.TrimSpace(out)
-		total, err = parseUint(string(out))
+		total = uint(len(bytes.TrimSpace(out)))
+	}
+
+	return commits, total, nil
+}
+
+func (r *Repository) WalkCommits(opt vcs.CommitsOptions) (vcs.CommitIterator, error) {
+	return r.WalkCommitsContext(context.Background(), opt)
+}
+
+func (r *Repository) WalkCommitsContext(ctx context.Context, opt vcs.CommitsOptions) (vcs.CommitIterator, error) {
+	revset, err := commitsOrderedRevset(opt)
+	if err != nil {
+		return nil, err
+	}
+	patterns, err := internal.CompileCommitPatterns(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"log", commitLogTemplate, "--rev=" + revset}
+	if opt.N != 0 && patterns == nil {
+		// hg log has no option to skip commits, so the iterator
+		// skips them itself.
+		args = append(args, "--limit", strconv.FormatUint(uint64(opt.N+opt.Skip), 10))
+	}
+	args = append(args, commitsPathArgs(opt)...)
+
+	it, err := r.startLog(ctx, opt.Head, args)
+	if err != nil {
+		return nil, err
+	}
+	if patterns != nil {
+		// The revset only prefilters the commits (see
+		// commitsFilterRevset), so N and Skip apply to the commits
+		// that match in Go.
+		return internal.FilterCommits(it, patterns.Match, opt.Skip, opt.N), nil
+	}
+	it.skip = opt.Skip
+	return it, nil
+}
+
+// startLog starts `hg log` with args and returns an iterator over its
+// output. Head is the revision that is reported as
+// vcs.ErrCommitNotFound if hg doesn't know it.
+func (r *Repository) startLog(ctx context.Context, head vcs.CommitID, args []string) (*commitIterator, error) {
+	cmd := exec.CommandContext(ctx, "hg", args...)
+	cmd.Dir = r.Dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+
+	it := &commitIterator{ctx: ctx, cmd: cmd, stderr: &stderr, rd: bufio.NewReader(out), head: head}
+	if _, err := it.rd.Peek(1); err == io.EOF {
+		// hg log exited without output, either because no commits
+		// matched or because it failed (e.g., Head doesn't exist).
+		// Report the latter here instead of from Err.
+		it.finish(nil)
+		if it.err != nil {
+			return nil, it.err
+		}
+	}
+	return it, nil
+}
+
+func (r *Repository) CommitsPage(opt vcs.CommitsOptions) ([]*vcs.Commit, string, error) {
+	return r.CommitsPageContext(context.Background(), opt)
+}
+
+// CommitsPageContext lists the commits by descending revision number
+// (the default order). The cursor is an internal.HgCommitsCursor, so
+// each page only visits the ancestors of the heads and bases in the
+// cursor, which `hg log` walks lazily.
+//
+// The commits matching opt are read from one `hg log` process, and
+// the ancestors of Base (which are excluded) from another, so that hg
+// doesn't compute the whole only(Head, Base) set. Only if the page is
+// full, a third process walks the ancestors of the heads down to the
+// page's last commit, to find the next page's heads.
+func (r *Repository) CommitsPageContext(ctx context.Context, opt vcs.CommitsOptions) ([]*vcs.Commit, string, error) {
+	if opt.Order != "" {
+		return nil, "", fmt.Errorf("CommitsPage: unsupported Order: %q", opt.Order)
+	}
+	patterns, err := internal.CompileCommitPatterns(opt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	heads := revsetString(string(opt.Head))
+	var bases string
+	if opt.Base != "" {
+		bases = revsetString(string(opt.Base))
+	}
+	cur := &internal.HgCommitsCursor{}
+	if opt.Cursor != "" {
+		if cur, err = internal.ParseHgCommitsCursor(opt.Cursor); err != nil {
+			return nil, "", err
+		}
+		heads, bases = revsetRevs(cur.Heads), revsetRevs(cur.Bases)
+		opt.Skip = 0
+	}
+	ancestors := "ancestors"
+	if opt.FirstParent {
+		ancestors = "_firstancestors"
+	}
+
+	startLog := func(template, revset string, pathArgs []string) (*commitIterator, error) {
+		args := append([]string{"log", template, "--rev=reverse(" + revset + ")"}, pathArgs...)
+		it, err := r.startLog(ctx, opt.Head, args)
 		if err != nil {
-			return nil, 0, err
+			if opt.Cursor != "" && isUnknownRevError(err) {
+				err = vcs.ErrInvalidCursor
+			}
+			return nil, err
 		}
-		total++ // sequence number is 1 less than total number of commits
+		return it, nil
+	}
 
-		// Add back however many we skipped.
-		total += opt.Skip
+	matches, err := startLog(`--template={rev}\x00`+strings.TrimPrefix(commitLogTemplate, "--template="),
+		ancestors+"("+heads+")"+commitsFilterRevset(opt), commitsPathArgs(opt))
+	if err != nil {
+		return nil, "", err
+	}
+	defer matches.Close()
+	var baseWalk *revWalk
+	if bases != "" {
+		it, err := startLog(revLogTemplate, "ancestors("+bases+")", nil)
+		if err != nil {
+			return nil, "", err
+		}
+		defer it.Close()
+		baseWalk = newRevWalk(it, cur.Bases, false)
 	}
 
-	return commits, total, nil
+	var commits []*vcs.Commit
+	lastRev := -1
+	for skip := opt.Skip; opt.N == 0 || uint(len(commits)) < opt.N; {
+		rev, c := matches.nextRevCommit()
+		if c == nil {
+			break
+		}
+		if baseWalk != nil {
+			if excluded := baseWalk.passTo(rev); excluded {
+				continue
+			}
+		}
+		lastRev = rev
+		if patterns != nil && !patterns.Match(c) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		commits = append(commits, c)
+	}
+	if err := matches.Err(); err != nil {
+		return nil, "", err
+	}
+	if baseWalk != nil && baseWalk.err() != nil {
+		return nil, "", baseWalk.err()
+	}
+	if opt.N == 0 || uint(len(commits)) < opt.N {
+		return commits, "", nil
+	}
+
+	it, err := startLog(revLogTemplate, ancestors+"("+heads+")", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer it.Close()
+	headWalk := newRevWalk(it, cur.Heads, opt.FirstParent)
+	headWalk.passTo(lastRev)
+	if headWalk.err() != nil {
+		return nil, "", headWalk.err()
+	}
+	next := &internal.HgCommitsCursor{Heads: headWalk.frontierRevs()}
+	if len(next.Heads) == 0 {
+		return commits, "", nil
+	}
+	if baseWalk != nil {
+		next.Bases = baseWalk.frontierRevs()
+	}
+	return commits, next.String(), nil
 }
 
-func parseUint(s string) (uint, error) {
-	n, err := strconv.ParseUint(s, 10, 64)
-	return uint(n), err
+// revLogTemplate is the `hg log` template of the revisions read by
+// (*commitIterator).nextRev.
+const revLogTemplate = `--template={rev}\x00{p1rev}\x00{p2rev}\x00`
+
+// revsetRevs returns the revset of the revision numbers revs.
+func revsetRevs(revs []int) string {
+	s := make([]string, len(revs))
+	for i, rev := range revs {
+		s[i] = strconv.Itoa(rev)
+	}
+	return strings.Join(s, "+")
 }
 
-func (r *Repository) getParents(revSpec vcs.CommitID) ([]vcs.CommitID, error) {
-	var parents []vcs.CommitID
+// isUnknownRevError reports whether err is the error of an hg command
+// that failed because a revision doesn't exist.
+func isUnknownRevError(err error) bool {
+	return strings.Contains(err.Error(), "abort: unknown revision '")
+}
 
-	cmd := exec.Command("hg", "parents", "-r", string(revSpec), "--template",
-		`{node}\x00{author|person}\x00{author|email}\x00{date|rfc3339date}\x00{desc}\x00{p1node}\x00{p2node}\x00`)
-	cmd.Dir = r.Dir
-	out, err := cmd.CombinedOutput()
+// A revWalk follows the output of a descending `hg log` of the
+// ancestors of some revisions, with revLogTemplate. Its frontier is
+// the set of parents of the revisions that it passed that it hasn't
+// passed yet, whose ancestors are the revisions that are left.
+type revWalk struct {
+	it          *commitIterator
+	firstParent bool
+
+	next     int   // the next revision, or -1 if none
+	parents  []int // the parents of next
+	frontier map[int]struct{}
+}
+
+// newRevWalk returns a walk of the output of it, starting with the
+// frontier revs.
+func newRevWalk(it *commitIterator, revs []int, firstParent bool) *revWalk {
+	w := &revWalk{it: it, firstParent: firstParent, frontier: map[int]struct{}{}}
+	for _, rev := range revs {
+		w.frontier[rev] = struct{}{}
+	}
+	w.next, w.parents = it.nextRev()
+	return w
+}
+
+// passTo passes the revisions greater than or equal to rev, and
+// reports whether rev was one of them.
+func (w *revWalk) passTo(rev int) bool {
+	found := false
+	for w.next >= rev {
+		found = found || w.next == rev
+		delete(w.frontier, w.next)
+		parents := w.parents
+		if w.firstParent && len(parents) > 1 {
+			parents = parents[:1]
+		}
+		for _, p := range parents {
+			w.frontier[p] = struct{}{}
+		}
+		w.next, w.parents = w.it.nextRev()
+	}
+	return found
+}
+
+func (w *revWalk) frontierRevs() []int {
+	revs := make([]int, 0, len(w.frontier))
+	for rev := range w.frontier {
+		revs = append(revs, rev)
+	}
+	return revs
+}
+
+func (w *revWalk) err() error { return w.it.Err() }
+
+// commitsRevset returns the revset of the commits matching opt
+// (except Path, N and Skip, which aren't expressible in revsets, and
+// the patterns, which it only prefilters). It selects only the
+// ancestors of Head and not all lower-numbered revisions (as the
+// Head:0 range that Commits used to list did), so that Base can be
+// honored and hg lists the same commits as git.
+func commitsRevset(opt vcs.CommitsOptions) string {
+	ancestors := "ancestors"
+	if opt.FirstParent {
+		ancestors = "_firstancestors"
+	}
+	revset := ancestors + "(" + revsetString(string(opt.Head)) + ")"
+	if opt.Base != "" {
+		revset += " - ancestors(" + revsetString(string(opt.Base)) + ")"
+	}
+	return revset + commitsFilterRevset(opt)
+}
+
+// commitsFilterRevset returns the revset operations that select the
+// commits matching opt's filters (Since, Until, Merges and NoMerges)
+// from the revset that precedes them.
+//
+// The Author, Committer and Message patterns are matched in Go (see
+// internal.CommitPatterns), so the revset only prefilters the commits
+// whose description contains a string that every match contains
+// (desc() is case-insensitive). user() can't prefilter because the
+// patterns match the author formatted from {author|person} and
+// {author|email}, which needn't be a substring of the user.
+func commitsFilterRevset(opt vcs.CommitsOptions) string {
+	var revset string
+	if lit, _ := internal.RequiredLiteral(opt.Message); lit != "" {
+		revset += " & desc(" + revsetString(lit) + ")"
+	}
+	const dateFormat = "2006-01-02 15:04:05 -0700"
+	if !opt.Since.IsZero() {
+		revset += " & date(" + revsetString(">"+opt.Since.Format(dateFormat)) + ")"
+	}
+	if !opt.Until.IsZero() {
+		revset += " & date(" + revsetString("<"+opt.Until.Format(dateFormat)) + ")"
+	}
+	if opt.Merges {
+		revset += " & merge()"
+	}
+	if opt.NoMerges {
+		revset += " - merge()"
+	}
+	return revset
+}
+
+// commitsOrderedRevset returns commitsRevset(opt) sorted by opt.Order.
+func commitsOrderedRevset(opt vcs.CommitsOptions) (string, error) {
+	switch opt.Order {
+	case "":
+		return "reverse(" + commitsRevset(opt) + ")", nil
+	case vcs.CommitsOrderDate:
+		return "sort(" + commitsRevset(opt) + ", -date)", nil
+	case vcs.CommitsOrderTopo:
+		// "topo" yields heads first and can't be reversed.
+		return "sort(" + commitsRevset(opt) + ", topo)", nil
+	default:
+		return "", fmt.Errorf("unrecognized Order: %q", opt.Order)
+	}
+}
+
+// commitsPathArgs returns the `hg log` arguments that select only the
+// commits modifying opt.Path.
+func commitsPathArgs(opt vcs.CommitsOptions) []string {
+	if opt.Path == "" {
+		return nil
+	}
+	return []string{"--", "path:" + opt.Path}
+}
+
+// revsetString quotes s as a string literal in an hg revset.
+func revsetString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// commitIterator reads commits from the output of a running `hg log`
+// process, one at a time.
+type commitIterator struct {
+	ctx    context.Context
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+	rd     *bufio.Reader
+	head   vcs.CommitID
+	skip   uint   // number of commits left to skip
+	nextID []byte // the next commit's ID, if nextDiff read it
+
+	done bool // whether cmd has exited
+	err  error
+}
+
+func (it *commitIterator) Next() *vcs.Commit {
+	for !it.done {
+		c := it.next()
+		if c == nil || it.skip == 0 {
+			return c
+		}
+		it.skip--
+	}
+	return nil
+}
+
+func (it *commitIterator) next() *vcs.Commit {
+	parts := it.readParts(commitLogPartsPerCommit)
+	if parts == nil {
+		return nil
+	}
+	c, err := parseCommitLogEntry(parts)
+	if err != nil {
+		it.finish(err)
+		return nil
+	}
+	return c
+}
+
+// nextRevCommit reads the next commit and its revision number from
+// output with a {rev} field before the commitLogTemplate fields. It
+// returns a nil commit if there are no more commits.
+func (it *commitIterator) nextRevCommit() (int, *vcs.Commit) {
+	if it.done {
+		return -1, nil
+	}
+	parts := it.readParts(1)
+	if parts == nil {
+		return -1, nil
+	}
+	rev, err := strconv.Atoi(string(parts[0]))
 	if err != nil {
-		return nil, fmt.Errorf("exec `hg parents` failed: %s. Output was:\n\n%s", err, out)
+		it.finish(fmt.Errorf("parsing hg revision number: %s", err))
+		return -1, nil
 	}
+	return rev, it.next()
+}
 
-	const partsPerCommit = 7 // number of \x00-separated fields per commit
-	allParts := bytes.Split(out, []byte{'\x00'})
-	numCommits := len(allParts) / partsPerCommit
-	for i := 0; i < numCommits; i++ {
-		parts := allParts[partsPerCommit*i : partsPerCommit*(i+1)]
+// nextRev reads the next revision number and the revision numbers of
+// its parents from output with revLogTemplate. It returns -1 if there
+// are no more revisions.
+func (it *commitIterator) nextRev() (rev int, parents []int) {
+	if it.done {
+		return -1, nil
+	}
+	parts := it.readParts(3)
+	if parts == nil {
+		return -1, nil
+	}
+	revs := make([]int, len(parts))
+	for i, part := range parts {
+		var err error
+		if revs[i], err = strconv.Atoi(string(part)); err != nil {
+			it.finish(fmt.Errorf("parsing hg revision number: %s", err))
+			return -1, nil
+		}
+	}
+	for _, p := range revs[1:] {
+		if p >= 0 { // the null revision is -1
+			parents = append(parents, p)
+		}
+	}
+	return revs[0], parents
+}
+
+// nextDiff reads the next commit and its diff from the output of `hg
+// log --patch` with commitLogTemplate. It returns a nil commit if there
+// are no more commits or an error occurred.
+func (it *commitIterator) nextDiff() (*vcs.Commit, []byte) {
+	if it.done {
+		return nil, nil
+	}
+	var parts [][]byte
+	if it.nextID != nil {
+		rest := it.readParts(commitLogPartsPerCommit - 1)
+		if rest == nil {
+			if it.err == nil {
+				it.err = io.ErrUnexpectedEOF
+			}
+			return nil, nil
+		}
+		parts = append([][]byte{it.nextID}, rest...)
+		it.nextID = nil
+	} else if parts = it.readParts(commitLogPartsPerCommit); parts == nil {
+		return nil, nil
+	}
+	c, err := parseCommitLogEntry(parts)
+	if err != nil {
+		it.finish(err)
+		return nil, nil
+	}
+
+	// The diff is in the same field as the next commit's first field
+	// (which contains no newlines), and hg may separate them with a
+	// blank line.
+	diff, err := it.rd.ReadBytes('\x00')
+	switch {
+	case err == io.EOF:
+		it.finish(nil)
+	case err != nil:
+		it.finish(err)
+		return nil, nil
+	default:
+		diff = diff[:len(diff)-1]
+		i := bytes.LastIndexByte(diff, '\n')
+		diff, it.nextID = diff[:i+1], diff[i+1:]
+	}
+	if it.err != nil {
+		return nil, nil
+	}
+	if diff = bytes.TrimRight(diff, "\n"); len(diff) > 0 {
+		diff = append(diff, '\n')
+	}
+	return c, diff
+}
 
-		if p1 := parts[0]; len(p1) > 0 && !bytes.Equal(p1, hgNullParentNodeID) {
-			parents = append(parents, vcs.CommitID(p1))
+// readParts reads the next n \x00-terminated fields of output. It
+// returns nil if there are no more fields or an error occurred.
+func (it *commitIterator) readParts(n int) [][]byte {
+	parts := make([][]byte, n)
+	for i := range parts {
+		part, err := it.rd.ReadBytes('\x00')
+		if err == io.EOF && i == 0 && len(part) == 0 {
+			it.finish(nil)
+			return nil
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			it.finish(err)
+			return nil
 		}
-		if p2 := parts[5]; len(p2) > 0 && !bytes.Equal(p2, hgNullParentNodeID) {
-			parents = append(parents, vcs.CommitID(p2))
+		parts[i] = part[:len(part)-1]
+	}
+	return parts
+}
+
+// finish waits for the hg log process to exit and records the first
+// error that occurred, if any. If err is non-nil, the process is
+// killed first because it may be blocked writing output that will
+// never be read.
+func (it *commitIterator) finish(err error) {
+	it.done = true
+	if err != nil {
+		it.cmd.Process.Kill()
+	}
+	if waitErr := it.cmd.Wait(); err == nil && waitErr != nil {
+		out := bytes.TrimSpace(it.stderr.Bytes())
+		if isUnknownRevisionError(string(out), string(it.head)) {
+			err = vcs.ErrCommitNotFound
+		} else {
+			err = fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", it.cmd.Args, waitErr, out)
 		}
-		if p3 := parts[6]; len(p3) > 0 && !bytes.Equal(p3, hgNullParentNodeID) {
-			parents = append(parents, vcs.CommitID(p3))
+	}
+	if err != nil {
+		it.err = ctxErr(it.ctx, err)
+	}
+}
+
+func (it *commitIterator) Err() error { return it.err }
+
+func (it *commitIterator) Close() error {
+	if it.done {
+		return nil
+	}
+	it.done = true
+	it.cmd.Process.Kill()
+	it.cmd.Wait() // the exit status of a killed process is meaningless
+	return nil
+}
+
+// parseCommitLogEntry parses the commitLogPartsPerCommit fields of a
+// commit in the output of `hg log` with commitLogTemplate.
+func parseCommitLogEntry(parts [][]byte) (*vcs.Commit, error) {
+	authorTime, err := time.Parse(time.RFC3339, string(parts[3]))
+	if err != nil {
+		return nil, fmt.Errorf("parsing hg commit author time: %s", err)
+	}
+
+	var parents []vcs.CommitID
+	for _, p := range parts[5:7] {
+		if len(p) > 0 && !bytes.Equal(p, hgNullParentNodeID) {
+			parents = append(parents, vcs.CommitID(p))
 		}
 	}
 
-	return parents, nil
+	return &vcs.Commit{
+		ID:      vcs.CommitID(parts[0]),
+		Author:  vcs.Signature{string(parts[1]), string(parts[2]), pbtypes.NewTimestamp(authorTime)},
+		Message: string(parts[4]),
+		Parents: parents,
+	}, nil
+}
+
+func parseUint(s string) (uint, error) {
+	n, err := strconv.ParseUint(s, 10, 64)
+	return uint(n), err
 }
 
 func (r *Repository) Diff(base, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.Diff, error) {
-	cmd := exec.Command("hg", "-v", "diff", "-p", "--git", "--rev="+string(base), "--rev="+string(head), "--")
+	return r.DiffContext(context.Background(), base, head, opt)
+}
+
+func (r *Repository) DiffContext(ctx context.Context, base, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.Diff, error) {
+	cmd := exec.CommandContext(ctx, "hg", "-v", "diff", "-p", "--git", "--rev="+string(base), "--rev="+string(head), "--")
 	if opt != nil {
 		cmd.Args = append(cmd.Args, opt.Paths...)
 	}
@@ -324,13 +888,20 @@ func (r *Repository) Diff(base, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.D
 		if isUnknownRevisionError(string(out), string(base)) || isUnknownRevisionError(string(out), string(head)) {
 			return nil, vcs.ErrCommitNotFound
 		}
-		return nil, fmt.Errorf("exec `hg diff` failed: %s. Output was:\n\n%s", err, out)
+		return nil, ctxErr(ctx, fmt.Errorf("exec `hg diff` failed: %s. Output was:\n\n%s", err, out))
 	}
 
 	if opt == nil {
 		opt = &vcs.DiffOptions{}
 	}
 
+	// Parse the diff before the prefixes are rewritten below, while
+	// the file names still have hg's "a/" and "b/" prefixes.
+	files, err := internal.ParseDiff(out, "a/", "b/")
+	if err != nil {
+		return nil, err
+	}
+
 	// Hackily apply OrigPrefix and NewPrefix.
 	fdiffs, err := diff.ParseMultiFileDiff(out)
 	if err != nil {
@@ -349,66 +920,345 @@ func (r *Repository) Diff(base, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.D
 	}
 
 	return &vcs.Diff{
-		Raw: string(out),
+		Raw:   string(out),
+		Files: files,
 	}, nil
 }
 
 func (r *Repository) UpdateEverything(opt vcs.RemoteOpts) (*vcs.UpdateResult, error) {
+	return r.UpdateEverythingContext(context.Background(), opt)
+}
+
+func (r *Repository) UpdateEverythingContext(ctx context.Context, opt vcs.RemoteOpts) (*vcs.UpdateResult, error) {
 	if opt.SSH != nil {
 		return nil, fmt.Errorf("hgcmd: ssh remote not supported")
 	}
-	cmd := exec.Command("hg", "pull")
+	cmd := exec.CommandContext(ctx, "hg", "pull")
 	cmd.Dir = r.Dir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return nil, fmt.Errorf("exec `hg pull` failed: %s. Output was:\n\n%s", err, out)
+		return nil, ctxErr(ctx, fmt.Errorf("exec `hg pull` failed: %s. Output was:\n\n%s", err, out))
 	}
 	// TODO: Calculate value of vcs.UpdateResult.
 	return nil, nil
 }
 
-func (r *Repository) BlameFile(path string, opt *vcs.BlameOptions) ([]*vcs.Hunk, error) {
+// fileHistoryTemplate is commitLogTemplate followed by the files that
+// each commit added, removed and copied (as "name\x01source" pairs),
+// each list terminated by \x00.
+const fileHistoryTemplate = commitLogTemplate + `{file_adds % '{file}\x01'}\x00{file_dels % '{file}\x01'}\x00{file_copies % '{name}\x01{source}\x01'}\x00`
+
+// fileHistoryPartsPerCommit is the number of \x00-separated fields
+// per commit in fileHistoryTemplate.
+const fileHistoryPartsPerCommit = commitLogPartsPerCommit + 3
+
+func (r *Repository) FileHistory(path string, head vcs.CommitID, opt *vcs.FileHistoryOptions) ([]*vcs.FileHistoryEntry, error) {
+	return r.FileHistoryContext(context.Background(), path, head, opt)
+}
+
+func (r *Repository) FileHistoryContext(ctx context.Context, path string, head vcs.CommitID, opt *vcs.FileHistoryOptions) ([]*vcs.FileHistoryEntry, error) {
 	if opt == nil {
-		opt = &vcs.BlameOptions{}
+		opt = &vcs.FileHistoryOptions{}
 	}
 
-	// TODO(sqs): implement OldestCommit
-	cmd := exec.Command("python", "-", r.Dir, string(opt.NewestCommit), path)
+	// Like `hg log -f path`, but starting at head instead of the
+	// working directory's parent.
+	revset := "reverse(follow(" + revsetString("path:"+path) + ", " + revsetString(string(head)) + "))"
+	args := []string{"log", fileHistoryTemplate, "--rev=" + revset}
+	if opt.N != 0 {
+		// The path at each commit depends on the renames in all
+		// newer commits, so skip them below.
+		args = append(args, "--limit", strconv.FormatUint(uint64(opt.N+opt.Skip), 10))
+	}
+	cmd := exec.CommandContext(ctx, "hg", args...)
 	cmd.Dir = r.Dir
-	cmd.Stdin = strings.NewReader(hgRepoAnnotatePy)
-	stdout, err := cmd.StdoutPipe()
+	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return nil, err
+		out = bytes.TrimSpace(out)
+		if isUnknownRevisionError(string(out), string(head)) {
+			return nil, vcs.ErrCommitNotFound
+		}
+		return nil, ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
 	}
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, err
+
+	allParts := bytes.Split(out, []byte{'\x00'})
+	numCommits := len(allParts) / fileHistoryPartsPerCommit
+	var entries []*vcs.FileHistoryEntry
+	for i := 0; i < numCommits; i++ {
+		parts := allParts[fileHistoryPartsPerCommit*i : fileHistoryPartsPerCommit*(i+1)]
+		c, err := parseCommitLogEntry(parts[:commitLogPartsPerCommit])
+		if err != nil {
+			return nil, err
+		}
+		e := &vcs.FileHistoryEntry{Commit: c, Path: path, Status: vcs.FileModified}
+		adds, dels := hgFileList(parts[commitLogPartsPerCommit]), hgFileList(parts[commitLogPartsPerCommit+1])
+		copies := hgFileList(parts[commitLogPartsPerCommit+2])
+		for j := 0; j+1 < len(copies); j += 2 {
+			if copies[j] == path {
+				e.OrigPath = copies[j+1]
+			}
+		}
+		switch {
+		case e.OrigPath != "":
+			e.Status = vcs.FileCopied
+			if containsString(dels, e.OrigPath) {
+				e.Status = vcs.FileRenamed
+			}
+			path = e.OrigPath // the file's path in older commits
+		case containsString(adds, path):
+			e.Status = vcs.FileAdded
+		case containsString(dels, path):
+			e.Status = vcs.FileDeleted
+		}
+		if uint(i) >= opt.Skip {
+			entries = append(entries, e)
+		}
 	}
+	return entries, nil
+}
 
-	in := bufio.NewReader(stdout)
-	if err := cmd.Start(); err != nil {
+func (r *Repository) LineHistory(path string, startLine, endLine int, head vcs.CommitID, opt *vcs.LineHistoryOptions) ([]*vcs.LineHistoryEntry, error) {
+	return r.LineHistoryContext(context.Background(), path, startLine, endLine, head, opt)
+}
+
+// LineHistoryContext walks back through the file's history (following
+// renames) and maps the line range through each commit's diff of the
+// file, like `git log -L`.
+func (r *Repository) LineHistoryContext(ctx context.Context, path string, startLine, endLine int, head vcs.CommitID, opt *vcs.LineHistoryOptions) ([]*vcs.LineHistoryEntry, error) {
+	if opt == nil {
+		opt = &vcs.LineHistoryOptions{}
+	}
+	if startLine < 1 || endLine < startLine {
+		return nil, fmt.Errorf("invalid line range %d-%d", startLine, endLine)
+	}
+
+	fileHistory, err := r.FileHistoryContext(ctx, path, head, nil)
+	if err != nil {
 		return nil, err
 	}
 
-	var data struct {
-		Commits map[string]struct {
-			Author     struct{ Name, Email string }
+	var entries []*vcs.LineHistoryEntry
+	for _, fe := range fileHistory {
+		// Diff both paths of a rename so that hg detects it (and
+		// doesn't show the file as added).
+		args := []string{"diff", "--git", "--unified=0", "--change=" + string(fe.Commit.ID), "--", "path:" + fe.Path}
+		if fe.OrigPath != "" {
+			args = append(args, "path:"+fe.OrigPath)
+		}
+		cmd := exec.CommandContext(ctx, "hg", args...)
+		cmd.Dir = r.Dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return nil, ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+		}
+		files, err := internal.ParseDiff(out, "a/", "b/")
+		if err != nil {
+			return nil, err
+		}
+		var hunks []*vcs.DiffHunk
+		for _, f := range files {
+			if f.NewName == fe.Path {
+				hunks = f.Hunks
+			}
+		}
+
+		changed, origStartLine, origEndLine := mapLineRange(hunks, startLine, endLine)
+		if len(changed) > 0 {
+			entries = append(entries, &vcs.LineHistoryEntry{
+				Commit:    fe.Commit,
+				Path:      fe.Path,
+				StartLine: startLine,
+				EndLine:   endLine,
+				Hunks:     changed,
+			})
+			if opt.N != 0 && uint(len(entries)) == opt.N {
+				break
+			}
+		}
+		if origEndLine < origStartLine || fe.Status == vcs.FileAdded {
+			break // the commit added all of the lines in the range
+		}
+		startLine, endLine = origStartLine, origEndLine
+	}
+	return entries, nil
+}
+
+// mapLineRange returns the hunks (of a diff without context lines)
+// that change the lines startLine through endLine of the new file,
+// and the range of lines in the original file that correspond to
+// them. If the hunks added all of the lines in the range, origEndLine
+// is less than origStartLine.
+func mapLineRange(hunks []*vcs.DiffHunk, startLine, endLine int) (changed []*vcs.DiffHunk, origStartLine, origEndLine int) {
+	origStartLine, origEndLine = startLine, endLine
+	for _, h := range hunks {
+		// The hunk replaces orig lines origFirst..origLast with new
+		// lines newFirst..newLast. Without context lines, an empty
+		// side's start line is the line before the change, so its
+		// range is (start+1)..start.
+		newFirst, newLast := int(h.NewStartLine), int(h.NewStartLine+h.NewLines-1)
+		if h.NewLines == 0 {
+			newFirst, newLast = newFirst+1, newFirst
+		}
+		origFirst, origLast := int(h.OrigStartLine), int(h.OrigStartLine+h.OrigLines-1)
+		if h.OrigLines == 0 {
+			origFirst, origLast = origFirst+1, origFirst
+		}
+
+		if h.NewLines > 0 && newFirst <= endLine && newLast >= startLine ||
+			h.NewLines == 0 && startLine < newFirst && newFirst <= endLine {
+			changed = append(changed, h)
+		}
+
+		switch {
+		case newLast < startLine:
+			origStartLine = startLine + origLast - newLast
+		case newFirst <= startLine:
+			origStartLine = origFirst
+		}
+		switch {
+		case newLast < endLine:
+			origEndLine = endLine + origLast - newLast
+		case newFirst <= endLine:
+			origEndLine = origLast
+		}
+	}
+	return changed, origStartLine, origEndLine
+}
+
+// hgFileList splits a list of files terminated by \x01 (as output by
+// fileHistoryTemplate).
+func hgFileList(b []byte) []string {
+	if len(b) == 0 {
+		return nil
+	}
+	return strings.Split(string(bytes.TrimSuffix(b, []byte{'\x01'})), "\x01")
+}
+
+func containsString(list []string, s string) bool {
+	for _, t := range list {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Repository) SearchHistory(opt vcs.HistorySearchOptions) ([]*vcs.HistorySearchResult, error) {
+	return r.SearchHistoryContext(context.Background(), opt)
+}
+
+// SearchHistoryContext matches the diff of each commit in the range
+// in Go, reading the commits and their diffs from a single `hg log
+// --patch` until N commits match. (hg grep --diff only reports the
+// matching lines, not the hunks, and isn't available in older versions
+// of hg.)
+func (r *Repository) SearchHistoryContext(ctx context.Context, opt vcs.HistorySearchOptions) ([]*vcs.HistorySearchResult, error) {
+	hm, err := internal.CompileHistorySearch(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	copt := vcs.CommitsOptions{Head: opt.Head, Base: opt.Base, Path: opt.Path, NoMerges: true}
+	args := []string{"log", commitLogTemplate, "--patch", "--git", "--rev=reverse(" + commitsRevset(copt) + ")"}
+	args = append(args, commitsPathArgs(copt)...)
+	it, err := r.startLog(ctx, opt.Head, args)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var results []*vcs.HistorySearchResult
+	for {
+		c, diff := it.nextDiff()
+		if c == nil {
+			break
+		}
+		files, err := internal.ParseDiff(diff, "a/", "b/")
+		if err != nil {
+			return nil, fmt.Errorf("parsing hg log --patch diff for commit %s: %s", c.ID, err)
+		}
+		if files = hm.MatchDiff(files); len(files) == 0 {
+			continue
+		}
+		results = append(results, &vcs.HistorySearchResult{Commit: c, Files: files})
+		if opt.N != 0 && uint(len(results)) == opt.N {
+			break
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *Repository) BlameFile(path string, opt *vcs.BlameOptions) ([]*vcs.Hunk, error) {
+	return r.BlameFileContext(context.Background(), path, opt)
+}
+
+func (r *Repository) BlameFileContext(ctx context.Context, path string, opt *vcs.BlameOptions) ([]*vcs.Hunk, error) {
+	if opt == nil {
+		opt = &vcs.BlameOptions{}
+	}
+	if opt.DetectMoves || opt.DetectCopies != 0 {
+		return nil, fmt.Errorf("vcs.BlameOptions.DetectMoves and DetectCopies options not implemented")
+	}
+
+	ignoreRevs := opt.IgnoreRevs
+	if opt.IgnoreRevsFile != "" {
+		cmd := exec.CommandContext(ctx, "hg", "cat", "--rev="+string(opt.NewestCommit), "--", "path:"+opt.IgnoreRevsFile)
+		cmd.Dir = r.Dir
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, ctxErr(ctx, fmt.Errorf("exec %v failed: %s", cmd.Args, err))
+		}
+		ignoreRevs = append(ignoreRevs[:len(ignoreRevs):len(ignoreRevs)], internal.ParseIgnoreRevs(out)...)
+	}
+
+	args := []string{"-", r.Dir, string(opt.NewestCommit)}
+	for _, rev := range ignoreRevs {
+		// Passed to hg annotate --skip, the equivalent of git blame
+		// --ignore-rev.
+		args = append(args, "--skip="+string(rev))
+	}
+	args = append(args, path)
+	cmd := exec.CommandContext(ctx, "python", args...)
+	cmd.Dir = r.Dir
+	cmd.Stdin = strings.NewReader(hgRepoAnnotatePy)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	in := bufio.NewReader(stdout)
+	if err := cmd.Start(); err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+
+	var data struct {
+		Commits map[string]struct {
+			Author     struct{ Name, Email string }
 			AuthorDate time.Time
 		}
 		Hunks map[string][]struct {
 			CommitID           string
 			StartLine, EndLine int
 			StartByte, EndByte int
+			OrigPath           string
+			OrigStartLine      int
 		}
 	}
 	jsonErr := json.NewDecoder(in).Decode(&data)
 	errOut, _ := ioutil.ReadAll(stderr)
 	if jsonErr != nil {
 		cmd.Wait()
-		return nil, fmt.Errorf("%s (stderr: %s)", jsonErr, errOut)
+		return nil, ctxErr(ctx, fmt.Errorf("%s (stderr: %s)", jsonErr, errOut))
 	}
 	if err := cmd.Wait(); err != nil {
-		return nil, fmt.Errorf("%s (stderr: %s)", err, errOut)
+		return nil, ctxErr(ctx, fmt.Errorf("%s (stderr: %s)", err, errOut))
 	}
 
 	hunks := make([]*vcs.Hunk, len(data.Hunks[path]))
@@ -425,37 +1275,673 @@ func (r *Repository) BlameFile(path string, opt *vcs.BlameOptions) ([]*vcs.Hunk,
 				Email: c.Author.Email,
 				Date:  pbtypes.NewTimestamp(c.AuthorDate.In(time.UTC)),
 			},
+			OrigPath:      hunk.OrigPath,
+			OrigStartLine: hunk.OrigStartLine,
 		}
 	}
+
+	if opt.OldestCommit != "" {
+		return r.blameBoundary(ctx, hunks, opt)
+	}
 	return hunks, nil
 }
 
+// blameBoundary attributes the hunks whose commits are not in the
+// range opt.OldestCommit..opt.NewestCommit (i.e., are ancestors of
+// OldestCommit) to OldestCommit, like `git blame oldest..newest`.
+func (r *Repository) blameBoundary(ctx context.Context, hunks []*vcs.Hunk, opt *vcs.BlameOptions) ([]*vcs.Hunk, error) {
+	oldest, err := r.GetCommitContext(ctx, opt.OldestCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	revset := "ancestors(" + revsetString(string(opt.NewestCommit)) + ") - ancestors(" + revsetString(string(oldest.ID)) + ")"
+	cmd := exec.CommandContext(ctx, "hg", "log", `--template={node}\n`, "--rev="+revset)
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+	}
+	inRange := make(map[vcs.CommitID]bool)
+	for _, id := range strings.Fields(string(out)) {
+		inRange[vcs.CommitID(id)] = true
+	}
+
+	var boundedHunks []*vcs.Hunk
+	for _, h := range hunks {
+		if !inRange[h.CommitID] {
+			h.CommitID = oldest.ID
+			h.Author = oldest.Author
+			h.Boundary = true
+		}
+		// Merge adjacent hunks that are now both attributed to the
+		// boundary.
+		if n := len(boundedHunks); n > 0 && h.Boundary && boundedHunks[n-1].Boundary && boundedHunks[n-1].EndLine == h.StartLine {
+			boundedHunks[n-1].EndLine = h.EndLine
+			boundedHunks[n-1].EndByte = h.EndByte
+			continue
+		}
+		boundedHunks = append(boundedHunks, h)
+	}
+	return boundedHunks, nil
+}
+
+func (r *Repository) MergeBase(a, b vcs.CommitID) (vcs.CommitID, error) {
+	return r.MergeBaseContext(context.Background(), a, b)
+}
+
+// MergeBaseContext returns the greatest common ancestor of a and b, as
+// chosen by hg's ancestor revset function.
+func (r *Repository) MergeBaseContext(ctx context.Context, a, b vcs.CommitID) (vcs.CommitID, error) {
+	return r.mergeBase(ctx, r.Dir, a, b)
+}
+
+// mergeBase runs `hg log -r 'ancestor(a, b)'` in repo, which is either
+// r.Dir or a bundle repository overlaid on it.
+func (r *Repository) mergeBase(ctx context.Context, repo string, a, b vcs.CommitID) (vcs.CommitID, error) {
+	revset := "ancestor(" + revsetString(string(a)) + ", " + revsetString(string(b)) + ")"
+	cmd := exec.CommandContext(ctx, "hg", "-R", repo, "log", "--template={node}", "--rev="+revset)
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		out = bytes.TrimSpace(out)
+		if isUnknownRevisionError(string(out), string(a)) || isUnknownRevisionError(string(out), string(b)) {
+			return "", vcs.ErrCommitNotFound
+		}
+		return "", ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+	}
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 || bytes.Equal(out, hgNullParentNodeID) {
+		return "", fmt.Errorf("commits %s and %s have no common ancestor", a, b)
+	}
+	return vcs.CommitID(out), nil
+}
+
+// A CrossRepo is an hg repository that can be used in cross-repo
+// operations (e.g., as the 2nd repo in a CrossRepoMergeBase call).
+type CrossRepo interface {
+	RepoDir() string // the repo's root directory
+}
+
+func (r *Repository) CrossRepoMergeBase(a vcs.CommitID, repoB vcs.Repository, b vcs.CommitID) (vcs.CommitID, error) {
+	return r.CrossRepoMergeBaseContext(context.Background(), a, repoB, b)
+}
+
+// CrossRepoMergeBaseContext bundles the changesets of repoB that are
+// ancestors of b and missing from r into a temporary file, and finds
+// the merge base in the bundle repository overlaid on r. Unlike
+// gitcmd, it doesn't modify r.
+func (r *Repository) CrossRepoMergeBaseContext(ctx context.Context, a vcs.CommitID, repoB vcs.Repository, b vcs.CommitID) (vcs.CommitID, error) {
+	// hg.Repository inherits RepoDir and CrossRepo from its embedded
+	// hgcmd.Repository.
+
+	var repoBDir string // path to repo B on local filesystem
+	if repoB, ok := repoB.(CrossRepo); ok {
+		repoBDir = repoB.RepoDir()
+	} else {
+		return "", fmt.Errorf("hg cross-repo merge-base not supported against repo type %T", repoB)
+	}
+
+	if repoBDir == r.Dir {
+		return r.MergeBaseContext(ctx, a, b)
+	}
+
+	tmp, err := ioutil.TempDir("", "go-vcs-hg-merge-base")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	bundle := filepath.Join(tmp, "incoming.hg")
+
+	cmd := exec.CommandContext(ctx, "hg", "incoming", "--quiet", "--bundle", bundle, "--rev="+string(b), "--", repoBDir)
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		out = bytes.TrimSpace(out)
+		if isUnknownRevisionError(string(out), string(b)) {
+			return "", vcs.ErrCommitNotFound
+		}
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			// hg incoming exits with status 1 if there are no
+			// incoming changesets, in which case r already contains
+			// b.
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.ExitStatus() == 1 {
+				return r.MergeBaseContext(ctx, a, b)
+			}
+		}
+		return "", ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+	}
+
+	return r.mergeBase(ctx, "bundle:"+r.Dir+"+"+bundle, a, b)
+}
+
+func (r *Repository) MergeBases(a, b vcs.CommitID) ([]vcs.CommitID, error) {
+	return r.MergeBasesContext(context.Background(), a, b)
+}
+
+func (r *Repository) MergeBasesContext(ctx context.Context, a, b vcs.CommitID) ([]vcs.CommitID, error) {
+	return r.OctopusMergeBasesContext(ctx, []vcs.CommitID{a, b})
+}
+
+func (r *Repository) OctopusMergeBases(commits []vcs.CommitID) ([]vcs.CommitID, error) {
+	return r.OctopusMergeBasesContext(context.Background(), commits)
+}
+
+// OctopusMergeBasesContext lists the heads of the common ancestors of
+// commits with `hg log -r 'heads(::a and ::b and ...)'`.
+func (r *Repository) OctopusMergeBasesContext(ctx context.Context, commits []vcs.CommitID) ([]vcs.CommitID, error) {
+	if len(commits) == 0 {
+		return nil, errors.New("octopus merge base of no commits")
+	}
+	ancestors := make([]string, len(commits))
+	for i, c := range commits {
+		ancestors[i] = "::" + revsetString(string(c))
+	}
+
+	cmd := exec.CommandContext(ctx, "hg", "log", "--template={node}\n", "--rev=heads("+strings.Join(ancestors, " and ")+")")
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		out = bytes.TrimSpace(out)
+		for _, c := range commits {
+			if isUnknownRevisionError(string(out), string(c)) {
+				return nil, vcs.ErrCommitNotFound
+			}
+		}
+		return nil, ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+	}
+
+	var bases []vcs.CommitID
+	for _, line := range bytes.Fields(out) {
+		bases = append(bases, vcs.CommitID(line))
+	}
+	sort.Sort(vcs.CommitIDs(bases))
+	return bases, nil
+}
+
+func (r *Repository) CreateCommit(opt vcs.CreateCommitOptions) (vcs.CommitID, error) {
+	return r.CreateCommitContext(context.Background(), opt)
+}
+
+// CreateCommitContext creates the commit with a Python script that
+// uses Mercurial's in-memory commits (memctx), which requires Mercurial
+// 4.5 or newer. opt.Ref is a bookmark.
+func (r *Repository) CreateCommitContext(ctx context.Context, opt vcs.CreateCommitOptions) (vcs.CommitID, error) {
+	type fileChange struct {
+		Path     string
+		Delete   bool
+		Contents []byte
+		Flags    *string // "l" (symlink), "x" (executable), "" or nil (keep)
+	}
+	data := struct {
+		Parent, Ref   string
+		Changes       []fileChange
+		User, Message string
+		Date          int64
+	}{
+		Parent:  string(opt.Parent),
+		Ref:     opt.Ref,
+		User:    opt.Author.Name + " <" + opt.Author.Email + ">",
+		Message: opt.Message,
+		Date:    opt.Author.Date.Seconds,
+	}
+	if opt.Author.Date == (pbtypes.Timestamp{}) {
+		data.Date = time.Now().Unix()
+	}
+	for _, c := range opt.Changes {
+		if !validFileChangePath(c.Path) {
+			return "", fmt.Errorf("invalid path in file change: %q", c.Path)
+		}
+		fc := fileChange{Path: c.Path, Delete: c.Delete, Contents: c.Contents}
+		if c.Mode != 0 {
+			var flags string
+			switch {
+			case c.Mode&os.ModeSymlink != 0:
+				flags = "l"
+			case c.Mode&0111 != 0:
+				flags = "x"
+			}
+			fc.Flags = &flags
+		}
+		data.Changes = append(data.Changes, fc)
+	}
+
+	f, err := ioutil.TempFile("", "go-vcs-hg-commit")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	err = json.NewEncoder(f).Encode(data)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var result struct{ Node, Error, Path string }
+	if err := r.runPython(ctx, hgCommitPy, &result, f.Name()); err != nil {
+		return "", err
+	}
+	switch result.Error {
+	case "":
+		return vcs.CommitID(result.Node), nil
+	case "notfound":
+		return "", vcs.ErrCommitNotFound
+	case "notexist":
+		return "", &os.PathError{Op: "CreateCommit", Path: result.Path, Err: os.ErrNotExist}
+	case "refchanged":
+		return "", vcs.ErrRefChanged
+	}
+	return "", fmt.Errorf("creating commit failed: %s", result.Error)
+}
+
+// runPython runs script with the repository directory and args as
+// arguments, and decodes its JSON output into result.
+func (r *Repository) runPython(ctx context.Context, script string, result interface{}, args ...string) error {
+	cmd := exec.CommandContext(ctx, "python", append([]string{"-", r.Dir}, args...)...)
+	cmd.Dir = r.Dir
+	cmd.Stdin = strings.NewReader(script)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, stderr.Bytes()))
+	}
+	if err := json.Unmarshal(out, result); err != nil {
+		return fmt.Errorf("%s (output: %q)", err, out)
+	}
+	return nil
+}
+
+// validFileChangePath reports whether p is a clean, relative,
+// slash-separated path.
+func validFileChangePath(p string) bool {
+	for _, name := range strings.Split(p, "/") {
+		if name == "" || name == "." || name == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Repository) CreateBranch(name string, id vcs.CommitID) error {
+	return r.CreateBranchContext(context.Background(), name, id)
+}
+
+// CreateBranchContext creates a bookmark.
+func (r *Repository) CreateBranchContext(ctx context.Context, name string, id vcs.CommitID) error {
+	return r.UpdateRefContext(ctx, name, "", id)
+}
+
+func (r *Repository) DeleteBranch(name string) error {
+	return r.DeleteBranchContext(context.Background(), name)
+}
+
+// DeleteBranchContext deletes a bookmark.
+func (r *Repository) DeleteBranchContext(ctx context.Context, name string) error {
+	cmd := exec.CommandContext(ctx, "hg", "bookmark", "--delete", "--", name)
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if bytes.Contains(out, []byte("abort: bookmark '"+name+"' does not exist")) {
+			return vcs.ErrBranchNotFound
+		}
+		return ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+	}
+	return nil
+}
+
+func (r *Repository) CreateTag(name string, id vcs.CommitID, opt *vcs.CreateTagOptions) error {
+	return r.CreateTagContext(context.Background(), name, id, opt)
+}
+
+// CreateTagContext creates a local tag if opt is nil. Otherwise it
+// creates a global tag by committing the change to .hgtags on top of
+// the head of the tagged commit's branch (see hgTagPy), which requires
+// Mercurial 4.5 or newer.
+func (r *Repository) CreateTagContext(ctx context.Context, name string, id vcs.CommitID, opt *vcs.CreateTagOptions) error {
+	if opt != nil {
+		var user string
+		var date int64
+		if opt.Tagger != nil {
+			user = opt.Tagger.Name + " <" + opt.Tagger.Email + ">"
+			date = opt.Tagger.Date.Seconds
+		}
+		return r.globalTag(ctx, name, id, user, date, opt.Message)
+	}
+
+	cmd := exec.CommandContext(ctx, "hg", "tag", "--local", "--rev="+string(id), "--", name)
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		switch {
+		case bytes.Contains(out, []byte("abort: tag '"+name+"' already exists")):
+			return vcs.ErrRefChanged
+		case isUnknownRevisionError(string(out), string(id)):
+			return vcs.ErrCommitNotFound
+		}
+		return ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+	}
+	return nil
+}
+
+func (r *Repository) DeleteTag(name string) error {
+	return r.DeleteTagContext(context.Background(), name)
+}
+
+// DeleteTagContext removes a local tag, or if name is a global tag,
+// commits its removal from .hgtags like CreateTagContext.
+func (r *Repository) DeleteTagContext(ctx context.Context, name string) error {
+	cmd := exec.CommandContext(ctx, "hg", "tag", "--remove", "--local", "--", name)
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		switch {
+		case bytes.Contains(out, []byte("abort: tag '"+name+"' is not a local tag")):
+			return r.globalTag(ctx, name, "", "", 0, "")
+		case bytes.Contains(out, []byte("abort: tag '"+name+"' does not exist")):
+			return vcs.ErrTagNotFound
+		}
+		return ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+	}
+	return nil
+}
+
+// globalTag adds the global tag name for id, or removes it if id is
+// empty, with hgTagPy.
+func (r *Repository) globalTag(ctx context.Context, name string, id vcs.CommitID, user string, date int64, message string) error {
+	var result struct{ Error string }
+	if err := r.runPython(ctx, hgTagPy, &result, name, string(id), user, strconv.FormatInt(date, 10), message); err != nil {
+		return err
+	}
+	switch result.Error {
+	case "":
+		return nil
+	case "notfound":
+		return vcs.ErrCommitNotFound
+	case "refchanged":
+		return vcs.ErrRefChanged
+	case "notexist":
+		return vcs.ErrTagNotFound
+	}
+	return fmt.Errorf("tagging %q failed: %s", name, result.Error)
+}
+
+func (r *Repository) UpdateRef(name string, old, new vcs.CommitID) error {
+	return r.UpdateRefContext(context.Background(), name, old, new)
+}
+
+// UpdateRefContext updates a bookmark with a Python script, because
+// hg bookmark can't check the bookmark's old value atomically. It
+// requires Mercurial 4.5 or newer.
+func (r *Repository) UpdateRefContext(ctx context.Context, name string, old, new vcs.CommitID) error {
+	var result struct{ Error string }
+	if err := r.runPython(ctx, hgBookmarkPy, &result, name, string(old), string(new)); err != nil {
+		return err
+	}
+	switch result.Error {
+	case "":
+		return nil
+	case "notfound":
+		return vcs.ErrCommitNotFound
+	case "refchanged":
+		return vcs.ErrRefChanged
+	}
+	return fmt.Errorf("updating bookmark %q failed: %s", name, result.Error)
+}
+
+func (r *Repository) Search(at vcs.CommitID, opt vcs.SearchOptions) ([]*vcs.SearchResult, error) {
+	return r.SearchContext(context.Background(), at, opt)
+}
+
+// searchCatBatchSize is the number of files that search writes
+// with each `hg cat` command.
+const searchCatBatchSize = 100
+
+func (r *Repository) SearchContext(ctx context.Context, at vcs.CommitID, opt vcs.SearchOptions) ([]*vcs.SearchResult, error) {
+	page := internal.NewSearchPage(opt)
+	if err := r.search(ctx, at, opt, page); err != nil {
+		return nil, err
+	}
+	return page.Results, nil
+}
+
+func (r *Repository) StreamSearch(at vcs.CommitID, opt vcs.SearchOptions, fn func(*vcs.SearchResult) bool) (*vcs.SearchStats, error) {
+	return r.StreamSearchContext(context.Background(), at, opt, fn)
+}
+
+func (r *Repository) StreamSearchContext(ctx context.Context, at vcs.CommitID, opt vcs.SearchOptions, fn func(*vcs.SearchResult) bool) (*vcs.SearchStats, error) {
+	page := internal.NewSearchStream(opt, fn)
+	if err := r.search(ctx, at, opt, page); err != nil {
+		return nil, err
+	}
+	return &page.Stats, nil
+}
+
+// search searches the files of the manifest at the commit in Go, with
+// the same semantics as gitcmd (and the git backend), until page is
+// full.
+func (r *Repository) search(ctx context.Context, at vcs.CommitID, opt vcs.SearchOptions, page *internal.SearchPage) error {
+	m, err := internal.CompileSearch(opt)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "hg", "manifest", "--rev="+string(at))
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		out = bytes.TrimSpace(out)
+		if isUnknownRevisionError(string(out), string(at)) {
+			return vcs.ErrCommitNotFound
+		}
+		return ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+	}
+	var files []string
+	for _, file := range strings.Split(string(out), "\n") {
+		if file != "" && m.MatchPath(file) {
+			files = append(files, file)
+		}
+	}
+
+	// hg cat writes the files to a temporary directory in batches, so
+	// that the search can stop once it has enough results.
+	tmp, err := ioutil.TempDir("", "go-vcs-hg-search")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	for len(files) > 0 {
+		if page.Full() {
+			page.Truncate()
+			break
+		}
+		batch := files
+		if len(batch) > searchCatBatchSize {
+			batch = batch[:searchCatBatchSize]
+		}
+		files = files[len(batch):]
+
+		if err := r.catFiles(ctx, at, batch, tmp); err != nil {
+			return err
+		}
+		for _, file := range batch {
+			if page.Full() {
+				page.Truncate()
+				break
+			}
+			data, err := readCatFile(m, tmp, file)
+			if err != nil {
+				return err
+			}
+			if data == nil || internal.IsBinary(data) {
+				continue
+			}
+			page.Stats.FilesScanned++
+			page.Add(m.SearchFile(file, data, int(opt.ContextLines)))
+		}
+	}
+	return nil
+}
+
+func (r *Repository) SearchRevisions(revs []vcs.CommitID, opt vcs.SearchOptions) ([]*vcs.SearchResult, error) {
+	return r.SearchRevisionsContext(context.Background(), revs, opt)
+}
+
+// SearchRevisionsContext lists the file revisions of each revision
+// with `hg manifest --debug`, and searches each file revision
+// (identified by its node ID) once in Go.
+func (r *Repository) SearchRevisionsContext(ctx context.Context, revs []vcs.CommitID, opt vcs.SearchOptions) ([]*vcs.SearchResult, error) {
+	m, err := internal.CompileSearch(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := ioutil.TempDir("", "go-vcs-hg-search")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	s := internal.NewMultiSearch(m, opt)
+	for _, rev := range revs {
+		cmd := exec.CommandContext(ctx, "hg", "manifest", "--debug", "--rev="+string(rev))
+		cmd.Dir = r.Dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			out = bytes.TrimSpace(out)
+			if isUnknownRevisionError(string(out), string(rev)) {
+				return nil, vcs.ErrCommitNotFound
+			}
+			return nil, ctxErr(ctx, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out))
+		}
+
+		// Each line looks like "<node> <mode> <flag> <path>", where
+		// flag is "*" (executable), "@" (symlink) or " ".
+		var files, nodes, unsearched []string
+		unsearchedNodes := map[string]string{} // file -> node
+		pending := map[string]bool{}           // nodes in unsearchedNodes
+		for _, line := range strings.Split(string(out), "\n") {
+			if line == "" {
+				continue
+			}
+			if len(line) < 48 || line[40] != ' ' || line[44] != ' ' || line[46] != ' ' {
+				return nil, fmt.Errorf("invalid `hg manifest --debug` output: %q", line)
+			}
+			node, file := line[:40], line[47:]
+			if !m.MatchPath(file) {
+				continue
+			}
+			files, nodes = append(files, file), append(nodes, node)
+			if !s.Searched(node) && !pending[node] {
+				pending[node] = true
+				unsearchedNodes[file] = node
+				unsearched = append(unsearched, file)
+			}
+		}
+
+		for len(unsearched) > 0 {
+			batch := unsearched
+			if len(batch) > searchCatBatchSize {
+				batch = batch[:searchCatBatchSize]
+			}
+			unsearched = unsearched[len(batch):]
+			// Each batch gets its own directory, because a path can be
+			// a file in one revision and a directory in another, and
+			// so that a file that hg doesn't write isn't read from an
+			// earlier batch.
+			dir, err := ioutil.TempDir(tmp, "")
+			if err != nil {
+				return nil, err
+			}
+			if err := r.catFiles(ctx, rev, batch, dir); err != nil {
+				return nil, err
+			}
+			for _, file := range batch {
+				data, err := readCatFile(m, dir, file)
+				if err != nil {
+					return nil, err
+				}
+				s.AddContents(unsearchedNodes[file], file, data)
+			}
+			if err := os.RemoveAll(dir); err != nil {
+				return nil, err
+			}
+		}
+		for i, file := range files {
+			s.AddFile(rev, file, nodes[i])
+		}
+	}
+	return s.Results(), nil
+}
+
+// catFiles writes the files of the commit at to the directory dir
+// (at their paths relative to dir).
+func (r *Repository) catFiles(ctx context.Context, at vcs.CommitID, files []string, dir string) error {
+	args := []string{"cat", "--rev=" + string(at), "--output=" + filepath.Join(dir, "%p"), "--"}
+	for _, file := range files {
+		args = append(args, "path:"+file)
+	}
+	cmd := exec.CommandContext(ctx, "hg", args...)
+	cmd.Dir = r.Dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return ctxErr(ctx, fmt.Errorf("exec `hg cat` failed: %s. Output was:\n\n%s", err, out))
+	}
+	return nil
+}
+
+// readCatFile reads a file written by catFiles to dir. It returns nil
+// if the file is larger than the max file size of the search.
+func readCatFile(m *internal.SearchMatcher, dir, file string) ([]byte, error) {
+	name := filepath.Join(dir, filepath.FromSlash(file))
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !m.MatchSize(fi.Size()) {
+		return nil, nil
+	}
+	return ioutil.ReadFile(name)
+}
+
 func (r *Repository) Committers(opt vcs.CommittersOptions) ([]*vcs.Committer, error) {
+	return r.CommittersContext(context.Background(), opt)
+}
+
+func (r *Repository) CommittersContext(ctx context.Context, opt vcs.CommittersOptions) ([]*vcs.Committer, error) {
 	return nil, fmt.Errorf("Committers() not implemented for vcs type: hg")
 }
 
 func (r *Repository) FileSystem(at vcs.CommitID) (vfs.FileSystem, error) {
+	return r.FileSystemContext(context.Background(), at)
+}
+
+func (r *Repository) FileSystemContext(ctx context.Context, at vcs.CommitID) (vfs.FileSystem, error) {
 	return &hgFSCmd{
+		ctx: ctx,
 		dir: r.Dir,
 		at:  at,
 	}, nil
 }
 
 type hgFSCmd struct {
+	ctx context.Context // bounds all of the commands run by the file system
 	dir string
 	at  vcs.CommitID
 }
 
 func (fs *hgFSCmd) Open(name string) (vfs.ReadSeekCloser, error) {
 	name = internal.Rel(name)
-	cmd := exec.Command("hg", "cat", "--rev="+string(fs.at), "--", name)
+	cmd := exec.CommandContext(fs.ctx, "hg", "cat", "--rev="+string(fs.at), "--", name)
 	cmd.Dir = fs.dir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		if bytes.Contains(out, []byte("no such file in rev")) {
 			return nil, os.ErrNotExist
 		}
-		return nil, fmt.Errorf("exec `hg cat` failed: %s. Output was:\n\n%s", err, out)
+		return nil, ctxErr(fs.ctx, fmt.Errorf("exec `hg cat` failed: %s. Output was:\n\n%s", err, out))
 	}
 	return util.NopCloser{bytes.NewReader(out)}, nil
 }
@@ -470,12 +1956,12 @@ func (fs *hgFSCmd) Stat(path string) (os.FileInfo, error) {
 	path = internal.Rel(path)
 	var mtime time.Time
 
-	cmd := exec.Command("hg", "log", "-l1", `--template={date|date}`,
+	cmd := exec.CommandContext(fs.ctx, "hg", "log", "-l1", `--template={date|date}`,
 		"-r "+string(fs.at)+":0", "--", path)
 	cmd.Dir = fs.dir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return nil, err
+		return nil, ctxErr(fs.ctx, err)
 	}
 
 	mtime, err = time.Parse("Mon Jan 02 15:04:05 2006 -0700",
@@ -486,10 +1972,13 @@ func (fs *hgFSCmd) Stat(path string) (os.FileInfo, error) {
 	}
 
 	// this just determines if the file exists.
-	cmd = exec.Command("hg", "locate", "--rev="+string(fs.at), "--", path)
+	cmd = exec.CommandContext(fs.ctx, "hg", "locate", "--rev="+string(fs.at), "--", path)
 	cmd.Dir = fs.dir
 	err = cmd.Run()
 	if err != nil {
+		if err := fs.ctx.Err(); err != nil {
+			return nil, err
+		}
 		// hg doesn't track dirs, so use a workaround to see if path is a dir.
 		if _, err := fs.ReadDir(path); err == nil {
 			return &util.FileInfo{Name_: filepath.Base(path), Mode_: os.ModeDir,
@@ -516,11 +2005,11 @@ func (fs *hgFSCmd) ReadDir(path string) ([]os.FileInfo, error) {
 	// the dir specified by path, plus all files one level deeper (but no
 	// deeper). This lets us list the files *and* subdirs in the dir without
 	// needlessly listing recursively.
-	cmd := exec.Command("hg", "locate", "--rev="+string(fs.at), "--include="+path, "--exclude="+filepath.Clean(path)+"/*/*/*")
+	cmd := exec.CommandContext(fs.ctx, "hg", "locate", "--rev="+string(fs.at), "--include="+path, "--exclude="+filepath.Clean(path)+"/*/*/*")
 	cmd.Dir = fs.dir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return nil, fmt.Errorf("exec `hg cat` failed: %s. Output was:\n\n%s", err, out)
+		return nil, ctxErr(fs.ctx, fmt.Errorf("exec `hg cat` failed: %s. Output was:\n\n%s", err, out))
 	}
 
 	subdirs := make(map[string]struct{})
@@ -0,0 +1,50 @@
+package hgcmd
+
+import (
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestMapLineRange(t *testing.T) {
+	// hunk returns a hunk without context lines ("@@ -os,ol +ns,nl @@").
+	hunk := func(os, ol, ns, nl int32) *vcs.DiffHunk {
+		return &vcs.DiffHunk{OrigStartLine: os, OrigLines: ol, NewStartLine: ns, NewLines: nl}
+	}
+	insert2 := hunk(1, 0, 2, 1)  // inserts new line 2
+	delete2 := hunk(2, 1, 1, 0)  // deletes orig line 2
+	replace3 := hunk(3, 2, 3, 1) // replaces orig lines 3-4 with new line 3
+	insert8 := hunk(8, 0, 8, 2)  // inserts new lines 8-9
+	for _, tc := range []struct {
+		hunks              []*vcs.DiffHunk
+		startLine, endLine int
+
+		wantChanged                        []*vcs.DiffHunk
+		wantOrigStartLine, wantOrigEndLine int
+	}{
+		{nil, 1, 3, nil, 1, 3},
+		{[]*vcs.DiffHunk{insert2}, 2, 2, []*vcs.DiffHunk{insert2}, 2, 1},
+		{[]*vcs.DiffHunk{insert2}, 1, 3, []*vcs.DiffHunk{insert2}, 1, 2},
+		{[]*vcs.DiffHunk{insert2}, 3, 4, nil, 2, 3},
+		{[]*vcs.DiffHunk{delete2}, 1, 2, []*vcs.DiffHunk{delete2}, 1, 3},
+		{[]*vcs.DiffHunk{delete2}, 1, 1, nil, 1, 1},
+		{[]*vcs.DiffHunk{delete2}, 2, 3, nil, 3, 4},
+		{[]*vcs.DiffHunk{replace3, insert8}, 3, 5, []*vcs.DiffHunk{replace3}, 3, 6},
+		{[]*vcs.DiffHunk{replace3, insert8}, 4, 12, []*vcs.DiffHunk{insert8}, 5, 11},
+		{[]*vcs.DiffHunk{replace3, insert8}, 1, 2, nil, 1, 2},
+	} {
+		changed, origStartLine, origEndLine := mapLineRange(tc.hunks, tc.startLine, tc.endLine)
+		if len(changed) != len(tc.wantChanged) {
+			t.Errorf("%d-%d in %v: got %d changed hunks, want %d", tc.startLine, tc.endLine, tc.hunks, len(changed), len(tc.wantChanged))
+		} else {
+			for i := range changed {
+				if changed[i] != tc.wantChanged[i] {
+					t.Errorf("%d-%d in %v: got changed hunk %d %v, want %v", tc.startLine, tc.endLine, tc.hunks, i, changed[i], tc.wantChanged[i])
+				}
+			}
+		}
+		if origStartLine != tc.wantOrigStartLine || origEndLine != tc.wantOrigEndLine {
+			t.Errorf("%d-%d in %v: got orig lines %d-%d, want %d-%d", tc.startLine, tc.endLine, tc.hunks, origStartLine, origEndLine, tc.wantOrigStartLine, tc.wantOrigEndLine)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package vcs
+
+import "context"
+
+// A FileHistorian is a repository that can list the history of a
+// file, following it across renames and copies.
+type FileHistorian interface {
+	// FileHistory returns the commits that modified the file at path
+	// in head or in its ancestors, newest first, together with the
+	// path that the file had at each commit. If the file was renamed
+	// or copied, its history continues under its previous path.
+	FileHistory(path string, head CommitID, opt *FileHistoryOptions) ([]*FileHistoryEntry, error)
+}
+
+// A FileHistorianContext is a FileHistorian that accepts a
+// context.Context. See RepositoryContext for the semantics of ctx.
+type FileHistorianContext interface {
+	FileHistoryContext(ctx context.Context, path string, head CommitID, opt *FileHistoryOptions) ([]*FileHistoryEntry, error)
+}
+
+// FileHistoryOptions configures a file history.
+type FileHistoryOptions struct {
+	N    uint // limit the number of returned entries to this many (0 means no limit)
+	Skip uint // skip this many entries at the beginning
+}
+
+// A FileHistoryEntry is a commit in a file's history.
+type FileHistoryEntry struct {
+	Commit *Commit
+
+	// Path is the file's path after Commit. OrigPath is its path
+	// before Commit if Commit renamed or copied it (and is empty
+	// otherwise).
+	Path     string
+	OrigPath string
+
+	// Status is how Commit changed the file: one of FileAdded,
+	// FileDeleted, FileModified, FileRenamed or FileCopied.
+	Status string
+}
+
+// A LineHistorian is a repository that can list the history of a
+// range of lines in a file (e.g., a function), like `git log -L`. It
+// is the history counterpart of Blamer, which attributes each line to
+// only the last commit that changed it.
+type LineHistorian interface {
+	// LineHistory returns the commits that changed any of the lines
+	// startLine through endLine (1-indexed, inclusive) of the file at
+	// path in head or in its ancestors, newest first. The range is
+	// followed through the changes to the file: at each commit, it
+	// covers the lines that became the requested lines in head.
+	LineHistory(path string, startLine, endLine int, head CommitID, opt *LineHistoryOptions) ([]*LineHistoryEntry, error)
+}
+
+// A LineHistorianContext is a LineHistorian that accepts a
+// context.Context. See RepositoryContext for the semantics of ctx.
+type LineHistorianContext interface {
+	LineHistoryContext(ctx context.Context, path string, startLine, endLine int, head CommitID, opt *LineHistoryOptions) ([]*LineHistoryEntry, error)
+}
+
+// LineHistoryOptions configures a line history.
+type LineHistoryOptions struct {
+	N uint // limit the number of returned entries to this many (0 means no limit)
+}
+
+// A LineHistoryEntry is a commit in the history of a range of lines.
+type LineHistoryEntry struct {
+	Commit *Commit
+
+	// Path is the file's path after Commit.
+	Path string
+
+	// StartLine and EndLine are the 1-indexed, inclusive line range
+	// after Commit.
+	StartLine, EndLine int
+
+	// Hunks are the parts of Commit's diff of the file that change
+	// the line range. Their NewStartLine and NewLines may extend
+	// beyond StartLine and EndLine (e.g., to include context lines).
+	Hunks []*DiffHunk
+}
+
+// A HistorySearcher is a repository that can search its history for
+// the commits that added or removed text (e.g., to find the commit
+// that introduced a string), like `git log -S` and `git log -G`.
+type HistorySearcher interface {
+	// SearchHistory returns the commits in opt.Head and its ancestors
+	// (excluding opt.Base and its ancestors) whose changes match the
+	// query of opt, newest first, together with the files and hunks
+	// whose changes match. A commit's changes are its diff against
+	// its parent; merge commits are not searched.
+	SearchHistory(opt HistorySearchOptions) ([]*HistorySearchResult, error)
+}
+
+// A HistorySearcherContext is a HistorySearcher that accepts a
+// context.Context. See RepositoryContext for the semantics of ctx.
+type HistorySearcherContext interface {
+	SearchHistoryContext(ctx context.Context, opt HistorySearchOptions) ([]*HistorySearchResult, error)
+}
+
+// HistorySearchOptions configures a history search.
+type HistorySearchOptions struct {
+	Head CommitID // search this commit and its ancestors (required)
+	Base CommitID // exclude this commit and its ancestors (optional, like `git log Base..Head`)
+	Path string   // only search the changes to this file or the files under this directory (optional)
+
+	N uint // limit the number of returned commits to this many (0 means no limit)
+
+	// Query is matched against the lines that each commit adds or
+	// removes, with the same semantics as SearchOptions.Query.
+	// QueryType is one of FixedQuery, IgnoreCaseQuery, RegexpQuery or
+	// ExtendedRegexpQuery.
+	Query     string
+	QueryType string
+
+	// Mode is HistorySearchOccurrences (the default if empty) or
+	// HistorySearchLines.
+	Mode string
+}
+
+// Values for HistorySearchOptions.Mode.
+const (
+	// HistorySearchOccurrences selects the changes to files that
+	// change the number of occurrences of the query in the file,
+	// like `git log -S`. Commits that only move a matching line
+	// within a file are not selected.
+	HistorySearchOccurrences = "occurrences"
+
+	// HistorySearchLines selects the changes to files that add or
+	// remove a line matching the query, like `git log -G`.
+	HistorySearchLines = "lines"
+)
+
+// A HistorySearchResult is a commit whose changes match a history
+// search.
+type HistorySearchResult struct {
+	Commit *Commit
+
+	// Files are the parts of Commit's diff whose changes match the
+	// query. Each file only has the hunks that add or remove a line
+	// matching the query.
+	Files []*FileDiff
+}
@@ -0,0 +1,500 @@
+package vcs_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestRepository_FileHistory(t *testing.T) {
+	t.Parallel()
+
+	gitCommit := func(msg string) string {
+		return "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -a -m " + msg + " --author='a <a@a.com>' --date 2006-01-02T15:04:05Z"
+	}
+	gitCommands := []string{
+		"printf 'line1\\nline2\\nline3\\n' > f",
+		"echo x > other",
+		"git add f other",
+		gitCommit("add-f"),
+		"echo line4 >> f",
+		gitCommit("modify-f"),
+		"echo y >> other",
+		gitCommit("modify-other"),
+		"git mv f g",
+		gitCommit("rename-f-g"),
+		"echo line5 >> g",
+		gitCommit("modify-g"),
+	}
+	hgCommit := func(msg string) string {
+		return "hg commit -m " + msg + " --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'"
+	}
+	hgCommands := []string{
+		"printf 'line1\\nline2\\nline3\\n' > f",
+		"echo x > other",
+		"hg add f other",
+		hgCommit("add-f"),
+		"echo line4 >> f",
+		hgCommit("modify-f"),
+		"echo y >> other",
+		hgCommit("modify-other"),
+		"hg mv f g",
+		hgCommit("rename-f-g"),
+		"echo line5 >> g",
+		hgCommit("modify-g"),
+	}
+
+	// entry is a FileHistoryEntry without the commit (except for its
+	// message).
+	type entry struct {
+		Message, Path, OrigPath, Status string
+	}
+	wantEntries := []entry{
+		{"modify-g", "g", "", vcs.FileModified},
+		{"rename-f-g", "g", "f", vcs.FileRenamed},
+		{"modify-f", "f", "", vcs.FileModified},
+		{"add-f", "f", "", vcs.FileAdded},
+	}
+
+	tests := map[string]struct {
+		repo interface {
+			vcs.FileHistorian
+			ResolveRevision(spec string) (vcs.CommitID, error)
+		}
+		head string
+		path string
+		opt  *vcs.FileHistoryOptions
+
+		wantEntries []entry
+	}{
+		"git cmd": {
+			repo:        makeGitRepositoryCmd(t, gitCommands...),
+			head:        "master",
+			path:        "g",
+			wantEntries: wantEntries,
+		},
+		"git cmd N and Skip": {
+			repo:        makeGitRepositoryCmd(t, gitCommands...),
+			head:        "master",
+			path:        "g",
+			opt:         &vcs.FileHistoryOptions{N: 2, Skip: 1},
+			wantEntries: wantEntries[1:3],
+		},
+		"git cmd older head": {
+			repo:        makeGitRepositoryCmd(t, gitCommands...),
+			head:        "master~2",
+			path:        "f",
+			wantEntries: wantEntries[2:],
+		},
+		"git go-git": {
+			repo:        makeGitRepositoryGoGit(t, gitCommands...),
+			head:        "master",
+			path:        "g",
+			wantEntries: wantEntries,
+		},
+		"hg cmd": {
+			repo:        makeHgRepositoryCmd(t, hgCommands...),
+			head:        "tip",
+			path:        "g",
+			wantEntries: wantEntries,
+		},
+		"hg cmd N and Skip": {
+			repo:        makeHgRepositoryCmd(t, hgCommands...),
+			head:        "tip",
+			path:        "g",
+			opt:         &vcs.FileHistoryOptions{N: 2, Skip: 1},
+			wantEntries: wantEntries[1:3],
+		},
+		"hg native": {
+			repo:        makeHgRepositoryNative(t, hgCommands...),
+			head:        "tip",
+			path:        "g",
+			wantEntries: wantEntries,
+		},
+	}
+
+	for label, test := range tests {
+		if strings.HasPrefix(label, "hg ") {
+			continue // hg broken, see issue #104.
+		}
+
+		head, err := test.repo.ResolveRevision(test.head)
+		if err != nil {
+			t.Errorf("%s: ResolveRevision(%q): %s", label, test.head, err)
+			continue
+		}
+
+		entries, err := test.repo.FileHistory(test.path, head, test.opt)
+		if err != nil {
+			t.Errorf("%s: FileHistory(%q, %q): %s", label, test.path, head, err)
+			continue
+		}
+		var got []entry
+		for _, e := range entries {
+			got = append(got, entry{e.Commit.Message, e.Path, e.OrigPath, e.Status})
+		}
+		if !reflect.DeepEqual(got, test.wantEntries) {
+			t.Errorf("%s: got entries\n%s\n\nwant\n%s", label, asJSON(got), asJSON(test.wantEntries))
+		}
+		if len(entries) > 0 && entries[0].Commit.ID == "" {
+			t.Errorf("%s: got empty commit ID", label)
+		}
+
+		// A nonexistent head is an error.
+		if _, err := test.repo.FileHistory(test.path, "0000000000000000000000000000000000000000", test.opt); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: FileHistory of nonexistent head: got err %v, want %v", label, err, vcs.ErrCommitNotFound)
+		}
+	}
+}
+
+func TestRepository_LineHistory(t *testing.T) {
+	t.Parallel()
+
+	gitCommit := func(msg string) string {
+		return "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -a -m " + msg + " --author='a <a@a.com>' --date 2006-01-02T15:04:05Z"
+	}
+	gitCommands := []string{
+		"printf 'line1\\nline2\\nline3\\n' > f",
+		"git add f",
+		gitCommit("add-f"),
+		"printf 'line0\\nline1\\nline2\\nline3\\n' > f",
+		gitCommit("prepend-line0"),
+		"git mv f g",
+		gitCommit("rename-f-g"),
+		"printf 'line0\\nline1\\nline2 changed\\nline3\\nline4\\n' > g",
+		gitCommit("change-line2-add-line4"),
+	}
+	hgCommit := func(msg string) string {
+		return "hg commit -m " + msg + " --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'"
+	}
+	hgCommands := []string{
+		"printf 'line1\\nline2\\nline3\\n' > f",
+		"hg add f",
+		hgCommit("add-f"),
+		"printf 'line0\\nline1\\nline2\\nline3\\n' > f",
+		hgCommit("prepend-line0"),
+		"hg mv f g",
+		hgCommit("rename-f-g"),
+		"printf 'line0\\nline1\\nline2 changed\\nline3\\nline4\\n' > g",
+		hgCommit("change-line2-add-line4"),
+	}
+
+	// entry is a LineHistoryEntry without the commit (except for its
+	// message) and hunks.
+	type entry struct {
+		Message            string
+		Path               string
+		StartLine, EndLine int
+	}
+
+	tests := map[string]struct {
+		repo interface {
+			vcs.LineHistorian
+			ResolveRevision(spec string) (vcs.CommitID, error)
+		}
+		head               string
+		startLine, endLine int
+		opt                *vcs.LineHistoryOptions
+
+		wantEntries []entry
+	}{
+		"git cmd": {
+			repo:      makeGitRepositoryCmd(t, gitCommands...),
+			head:      "master",
+			startLine: 2,
+			endLine:   3,
+			wantEntries: []entry{
+				{"change-line2-add-line4", "g", 2, 3},
+				{"add-f", "f", 1, 2},
+			},
+		},
+		"git cmd unchanged lines": {
+			repo:      makeGitRepositoryCmd(t, gitCommands...),
+			head:      "master",
+			startLine: 4,
+			endLine:   4,
+			wantEntries: []entry{
+				{"add-f", "f", 3, 3},
+			},
+		},
+		"git cmd N": {
+			repo:      makeGitRepositoryCmd(t, gitCommands...),
+			head:      "master",
+			startLine: 1,
+			endLine:   5,
+			opt:       &vcs.LineHistoryOptions{N: 2},
+			wantEntries: []entry{
+				{"change-line2-add-line4", "g", 1, 5},
+				{"prepend-line0", "f", 1, 4},
+			},
+		},
+		"git go-git": {
+			repo:      makeGitRepositoryGoGit(t, gitCommands...),
+			head:      "master",
+			startLine: 2,
+			endLine:   3,
+			wantEntries: []entry{
+				{"change-line2-add-line4", "g", 2, 3},
+				{"add-f", "f", 1, 2},
+			},
+		},
+		"hg cmd": {
+			repo:      makeHgRepositoryCmd(t, hgCommands...),
+			head:      "tip",
+			startLine: 2,
+			endLine:   3,
+			wantEntries: []entry{
+				{"change-line2-add-line4", "g", 2, 3},
+				{"add-f", "f", 1, 2},
+			},
+		},
+		"hg native": {
+			repo:      makeHgRepositoryNative(t, hgCommands...),
+			head:      "tip",
+			startLine: 2,
+			endLine:   3,
+			wantEntries: []entry{
+				{"change-line2-add-line4", "g", 2, 3},
+				{"add-f", "f", 1, 2},
+			},
+		},
+	}
+
+	for label, test := range tests {
+		if strings.HasPrefix(label, "hg ") {
+			continue // hg broken, see issue #104.
+		}
+
+		head, err := test.repo.ResolveRevision(test.head)
+		if err != nil {
+			t.Errorf("%s: ResolveRevision(%q): %s", label, test.head, err)
+			continue
+		}
+
+		entries, err := test.repo.LineHistory("g", test.startLine, test.endLine, head, test.opt)
+		if err != nil {
+			t.Errorf("%s: LineHistory(%d, %d, %q): %s", label, test.startLine, test.endLine, head, err)
+			continue
+		}
+		var got []entry
+		for _, e := range entries {
+			got = append(got, entry{e.Commit.Message, e.Path, e.StartLine, e.EndLine})
+			if len(e.Hunks) == 0 {
+				t.Errorf("%s: got no hunks for commit %q", label, e.Commit.Message)
+			}
+		}
+		if !reflect.DeepEqual(got, test.wantEntries) {
+			t.Errorf("%s: got entries\n%s\n\nwant\n%s", label, asJSON(got), asJSON(test.wantEntries))
+		}
+	}
+}
+
+func TestRepository_SearchHistory(t *testing.T) {
+	t.Parallel()
+
+	gitCommit := func(msg string) string {
+		return "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -a -m " + msg + " --author='a <a@a.com>' --date 2006-01-02T15:04:05Z"
+	}
+	gitCommands := []string{
+		"printf 'foo bar\\na\\nb\\nc\\n' > f",
+		"printf 'x\\n' > g",
+		"git add f g",
+		gitCommit("add-f"),
+		"printf 'a\\nb\\nc\\nfoo bar\\n' > f",
+		gitCommit("move-foo"),
+		"printf 'x\\nFoo()\\n' > g",
+		gitCommit("add-Foo-g"),
+		"printf 'a\\nb\\nc\\n' > f",
+		gitCommit("remove-foo"),
+		"printf 'x\\nFoo()\\ny\\n' > g",
+		gitCommit("add-y"),
+	}
+	hgCommit := func(msg string) string {
+		return "hg commit -m " + msg + " --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'"
+	}
+	hgCommands := []string{
+		"printf 'foo bar\\na\\nb\\nc\\n' > f",
+		"printf 'x\\n' > g",
+		"hg add f g",
+		hgCommit("add-f"),
+		"printf 'a\\nb\\nc\\nfoo bar\\n' > f",
+		hgCommit("move-foo"),
+		"printf 'x\\nFoo()\\n' > g",
+		hgCommit("add-Foo-g"),
+		"printf 'a\\nb\\nc\\n' > f",
+		hgCommit("remove-foo"),
+		"printf 'x\\nFoo()\\ny\\n' > g",
+		hgCommit("add-y"),
+	}
+
+	// result is a HistorySearchResult with only the commit message
+	// and file names.
+	type result struct {
+		Message string
+		Files   []string
+	}
+
+	type repo interface {
+		vcs.HistorySearcher
+		ResolveRevision(spec string) (vcs.CommitID, error)
+	}
+	gitRepo := makeGitRepositoryCmd(t, gitCommands...)
+	hgCmdRepo := makeHgRepositoryCmd(t, hgCommands...)
+	hgNativeRepo := makeHgRepositoryNative(t, hgCommands...)
+	tests := map[string]struct {
+		repo       repo
+		head, base string
+		opt        vcs.HistorySearchOptions
+
+		wantResults []result
+	}{
+		"git cmd occurrences": {
+			repo: gitRepo,
+			head: "master",
+			opt:  vcs.HistorySearchOptions{Query: "foo", QueryType: vcs.FixedQuery},
+			wantResults: []result{
+				{"remove-foo", []string{"f"}},
+				{"add-f", []string{"f"}},
+			},
+		},
+		"git cmd lines": {
+			repo: gitRepo,
+			head: "master",
+			opt:  vcs.HistorySearchOptions{Query: "foo", QueryType: vcs.FixedQuery, Mode: vcs.HistorySearchLines},
+			wantResults: []result{
+				{"remove-foo", []string{"f"}},
+				{"move-foo", []string{"f"}},
+				{"add-f", []string{"f"}},
+			},
+		},
+		"git cmd ignore-case": {
+			repo: gitRepo,
+			head: "master",
+			opt:  vcs.HistorySearchOptions{Query: "foo", QueryType: vcs.IgnoreCaseQuery},
+			wantResults: []result{
+				{"remove-foo", []string{"f"}},
+				{"add-Foo-g", []string{"g"}},
+				{"add-f", []string{"f"}},
+			},
+		},
+		"git cmd regexp": {
+			repo: gitRepo,
+			head: "master",
+			opt:  vcs.HistorySearchOptions{Query: `Fo+\(`, QueryType: vcs.RegexpQuery},
+			wantResults: []result{
+				{"add-Foo-g", []string{"g"}},
+			},
+		},
+		"git cmd Path": {
+			repo: gitRepo,
+			head: "master",
+			opt:  vcs.HistorySearchOptions{Query: "foo", QueryType: vcs.IgnoreCaseQuery, Path: "g"},
+			wantResults: []result{
+				{"add-Foo-g", []string{"g"}},
+			},
+		},
+		"git cmd Base": {
+			repo: gitRepo,
+			head: "master",
+			base: "master~3",
+			opt:  vcs.HistorySearchOptions{Query: "foo", QueryType: vcs.FixedQuery, Mode: vcs.HistorySearchLines},
+			wantResults: []result{
+				{"remove-foo", []string{"f"}},
+			},
+		},
+		"git cmd N": {
+			repo: gitRepo,
+			head: "master",
+			opt:  vcs.HistorySearchOptions{Query: "o+", QueryType: vcs.ExtendedRegexpQuery, N: 2},
+			wantResults: []result{
+				{"remove-foo", []string{"f"}},
+				{"add-Foo-g", []string{"g"}},
+			},
+		},
+		"git cmd regexp N": {
+			repo: gitRepo,
+			head: "master",
+			opt:  vcs.HistorySearchOptions{Query: `(?i)^FO+ bar$`, QueryType: vcs.RegexpQuery, Mode: vcs.HistorySearchLines, N: 2},
+			wantResults: []result{
+				{"remove-foo", []string{"f"}},
+				{"move-foo", []string{"f"}},
+			},
+		},
+		"git go-git": {
+			repo: makeGitRepositoryGoGit(t, gitCommands...),
+			head: "master",
+			opt:  vcs.HistorySearchOptions{Query: "foo", QueryType: vcs.FixedQuery},
+			wantResults: []result{
+				{"remove-foo", []string{"f"}},
+				{"add-f", []string{"f"}},
+			},
+		},
+		"hg cmd": {
+			repo: hgCmdRepo,
+			head: "tip",
+			opt:  vcs.HistorySearchOptions{Query: "foo", QueryType: vcs.FixedQuery},
+			wantResults: []result{
+				{"remove-foo", []string{"f"}},
+				{"add-f", []string{"f"}},
+			},
+		},
+		"hg cmd Base": {
+			repo: hgCmdRepo,
+			head: "tip",
+			base: "1",
+			opt:  vcs.HistorySearchOptions{Query: "foo", QueryType: vcs.FixedQuery, Mode: vcs.HistorySearchLines},
+			wantResults: []result{
+				{"remove-foo", []string{"f"}},
+			},
+		},
+		"hg native": {
+			repo: hgNativeRepo,
+			head: "tip",
+			opt:  vcs.HistorySearchOptions{Query: "foo", QueryType: vcs.IgnoreCaseQuery, Path: "g"},
+			wantResults: []result{
+				{"add-Foo-g", []string{"g"}},
+			},
+		},
+	}
+
+	for label, test := range tests {
+		if strings.HasPrefix(label, "hg ") {
+			continue // hg broken, see issue #104.
+		}
+
+		opt := test.opt
+		var err error
+		if opt.Head, err = test.repo.ResolveRevision(test.head); err != nil {
+			t.Errorf("%s: ResolveRevision(%q): %s", label, test.head, err)
+			continue
+		}
+		if test.base != "" {
+			if opt.Base, err = test.repo.ResolveRevision(test.base); err != nil {
+				t.Errorf("%s: ResolveRevision(%q): %s", label, test.base, err)
+				continue
+			}
+		}
+
+		results, err := test.repo.SearchHistory(opt)
+		if err != nil {
+			t.Errorf("%s: SearchHistory(%+v): %s", label, opt, err)
+			continue
+		}
+		var got []result
+		for _, r := range results {
+			res := result{Message: r.Commit.Message}
+			for _, f := range r.Files {
+				res.Files = append(res.Files, f.NewName)
+				if len(f.Hunks) == 0 {
+					t.Errorf("%s: got no hunks for file %q in commit %q", label, f.NewName, r.Commit.Message)
+				}
+			}
+			got = append(got, res)
+		}
+		if !reflect.DeepEqual(got, test.wantResults) {
+			t.Errorf("%s: got results\n%s\n\nwant\n%s", label, asJSON(got), asJSON(test.wantResults))
+		}
+	}
+}
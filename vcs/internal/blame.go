@@ -0,0 +1,23 @@
+package internal
+
+import (
+	"bytes"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+// ParseIgnoreRevs parses the list of commits in a
+// BlameOptions.IgnoreRevsFile (in the format of git's
+// .git-blame-ignore-revs), skipping empty lines and "#" comments.
+func ParseIgnoreRevs(data []byte) []vcs.CommitID {
+	var revs []vcs.CommitID
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if i := bytes.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			revs = append(revs, vcs.CommitID(line))
+		}
+	}
+	return revs
+}
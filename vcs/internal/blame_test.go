@@ -0,0 +1,22 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestParseIgnoreRevs(t *testing.T) {
+	tests := map[string][]vcs.CommitID{
+		"":                   nil,
+		"\n# comment\n\n":    nil,
+		"a\nb # reformat\n":  {"a", "b"},
+		"  c\r\n#d\ne  \n\n": {"c", "e"},
+	}
+	for data, want := range tests {
+		if revs := ParseIgnoreRevs([]byte(data)); !reflect.DeepEqual(revs, want) {
+			t.Errorf("%q: got %q, want %q", data, revs, want)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+// CommitPatterns are the compiled Author, Committer and Message
+// patterns of a vcs.CommitsOptions. Every backend matches them in Go
+// (with RE2 syntax), because git and hg each use a different regexp
+// syntax; git and hg only prefilter commits (see RequiredLiteral).
+type CommitPatterns struct {
+	Author, Committer, Message *regexp.Regexp
+}
+
+// CompileCommitPatterns compiles the patterns of opt. It returns nil
+// if opt has no patterns.
+func CompileCommitPatterns(opt vcs.CommitsOptions) (*CommitPatterns, error) {
+	if opt.Author == "" && opt.Committer == "" && opt.Message == "" {
+		return nil, nil
+	}
+	var p CommitPatterns
+	var err error
+	if opt.Author != "" {
+		if p.Author, err = regexp.Compile(opt.Author); err != nil {
+			return nil, err
+		}
+	}
+	if opt.Committer != "" {
+		if p.Committer, err = regexp.Compile(opt.Committer); err != nil {
+			return nil, err
+		}
+	}
+	if opt.Message != "" {
+		// Like git log --grep, ^ and $ match at the start and end of
+		// each line.
+		if p.Message, err = regexp.Compile("(?m)" + opt.Message); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+// Match reports whether c matches the patterns. Signatures are
+// matched in the form "Name <email>". Commits without a committer
+// (hg has none) are matched against the author instead.
+func (p *CommitPatterns) Match(c *vcs.Commit) bool {
+	signature := func(s vcs.Signature) string { return s.Name + " <" + s.Email + ">" }
+	committer := c.Committer
+	if committer == nil {
+		committer = &c.Author
+	}
+	switch {
+	case p.Author != nil && !p.Author.MatchString(signature(c.Author)):
+		return false
+	case p.Committer != nil && !p.Committer.MatchString(signature(*committer)):
+		return false
+	case p.Message != nil && !p.Message.MatchString(c.Message):
+		return false
+	}
+	return true
+}
+
+// RequiredLiteral returns the longest string that every match of the
+// regexp pattern contains, or "" if there is none (or pattern is
+// invalid). The string contains no newlines, so it can also be
+// searched for line by line. If foldCase is true, the string must only
+// be matched case-insensitively.
+//
+// A git or hg search for the string (which doesn't depend on their
+// regexp syntax) selects a superset of the commits that match
+// pattern.
+func RequiredLiteral(pattern string) (lit string, foldCase bool) {
+	return parseRequiredLiteral(pattern, syntax.Perl)
+}
+
+// parseRequiredLiteral is like RequiredLiteral for a pattern with the
+// syntax flags.
+func parseRequiredLiteral(pattern string, flags syntax.Flags) (lit string, foldCase bool) {
+	re, err := syntax.Parse(pattern, flags)
+	if err != nil {
+		return "", false
+	}
+	return requiredLiteral(re.Simplify())
+}
+
+func requiredLiteral(re *syntax.Regexp) (lit string, foldCase bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		foldCase = re.Flags&syntax.FoldCase != 0
+		split := func(r rune) bool {
+			// git and hg only fold the case of ASCII letters, so
+			// split at letters that also fold to other letters
+			// (like k to the Kelvin sign).
+			return r == '\n' || foldCase && !asciiFold(r)
+		}
+		for _, s := range strings.FieldsFunc(string(re.Rune), split) {
+			if len(s) > len(lit) {
+				lit = s
+			}
+		}
+		return lit, lit != "" && foldCase
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiteral(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiteral(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		// Simplify rewrites repetitions like d{1,2} as nested
+		// concatenations, so flatten them and join the adjacent
+		// literals first.
+		var subs []*syntax.Regexp
+		for _, sub := range flattenConcat(re) {
+			if last := len(subs) - 1; last >= 0 && sub.Op == syntax.OpLiteral && subs[last].Op == syntax.OpLiteral && sub.Flags&syntax.FoldCase == subs[last].Flags&syntax.FoldCase {
+				joined := *subs[last]
+				joined.Rune = append(append([]rune(nil), joined.Rune...), sub.Rune...)
+				subs[last] = &joined
+				continue
+			}
+			subs = append(subs, sub)
+		}
+		for _, sub := range subs {
+			if s, fold := requiredLiteral(sub); len(s) > len(lit) {
+				lit, foldCase = s, fold
+			}
+		}
+		return lit, foldCase
+	}
+	return "", false
+}
+
+// flattenConcat returns the subexpressions of the concatenation re,
+// with those that are concatenations themselves replaced by their
+// subexpressions.
+func flattenConcat(re *syntax.Regexp) []*syntax.Regexp {
+	var subs []*syntax.Regexp
+	for _, sub := range re.Sub {
+		if sub.Op == syntax.OpConcat {
+			subs = append(subs, flattenConcat(sub)...)
+		} else {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+// asciiFold reports whether r and the runes that it folds to are
+// all ASCII.
+func asciiFold(r rune) bool {
+	for f := r; ; {
+		if f > unicode.MaxASCII {
+			return false
+		}
+		if f = unicode.SimpleFold(f); f == r {
+			return true
+		}
+	}
+}
+
+// FilterCommits returns an iterator over the commits of it that
+// match, skipping the first skip of them and stopping after n of them
+// (unless n is 0).
+func FilterCommits(it vcs.CommitIterator, match func(*vcs.Commit) bool, skip, n uint) vcs.CommitIterator {
+	return &filterIterator{CommitIterator: it, match: match, skip: skip, n: n}
+}
+
+type filterIterator struct {
+	vcs.CommitIterator
+	match   func(*vcs.Commit) bool
+	skip, n uint
+	seen    uint // number of matching commits returned so far
+}
+
+func (it *filterIterator) Next() *vcs.Commit {
+	if it.n != 0 && it.seen >= it.n {
+		return nil
+	}
+	for c := it.CommitIterator.Next(); c != nil; c = it.CommitIterator.Next() {
+		if !it.match(c) {
+			continue
+		}
+		if it.skip > 0 {
+			it.skip--
+			continue
+		}
+		it.seen++
+		return c
+	}
+	return nil
+}
+
+// ListCommits returns the commits that a Commits call with opt
+// returns, and their total (unless opt.NoTotal), reading them from it,
+// which must iterate over all of the commits matching opt (ignoring
+// opt.N and opt.Skip). It reads the commits after the listed ones only
+// to count them.
+func ListCommits(it vcs.CommitIterator, opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
+	var commits []*vcs.Commit
+	var total uint
+	for c := it.Next(); c != nil; c = it.Next() {
+		if total >= opt.Skip && (opt.N == 0 || uint(len(commits)) < opt.N) {
+			commits = append(commits, c)
+		}
+		total++
+		if opt.NoTotal && opt.N != 0 && uint(len(commits)) >= opt.N {
+			break
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, 0, err
+	}
+	if opt.NoTotal {
+		total = 0
+	}
+	return commits, total, nil
+}
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestRequiredLiteral(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		lit      string
+		foldCase bool
+	}{
+		{"", "", false},
+		{"^fix:", "fix:", false},
+		{`(?i)^FIX: [yz]$`, "fix: ", true},
+		{`a\d+bcd`, "bcd", false},
+		{"(abc)+x", "abc", false},
+		{"ab|cd", "", false},
+		{"(?:xyz)?a", "a", false},
+		{"ab(?-s:.)*cde\nf", "cde", false},
+		{"(?i)mask", "ma", true}, // "s" and "k" also fold to non-ASCII letters
+		{"(", "", false},
+	}
+	for _, test := range tests {
+		lit, foldCase := RequiredLiteral(test.pattern)
+		if foldCase && lit != "" {
+			// Parse folds case-insensitive literals to upper case.
+			lit = strings.ToLower(lit)
+		}
+		if lit != test.lit || foldCase != test.foldCase {
+			t.Errorf("RequiredLiteral(%q): got %q, %v, want %q, %v", test.pattern, lit, foldCase, test.lit, test.foldCase)
+		}
+	}
+}
+
+func TestListCommits(t *testing.T) {
+	commits := []*vcs.Commit{{ID: "a", Message: "x"}, {ID: "b", Message: "y"}, {ID: "c", Message: "x"}, {ID: "d", Message: "x"}}
+	patterns, err := CompileCommitPatterns(vcs.CommitsOptions{Message: "^x$"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		opt       vcs.CommitsOptions
+		wantIDs   []vcs.CommitID
+		wantTotal uint
+	}{
+		{vcs.CommitsOptions{}, []vcs.CommitID{"a", "c", "d"}, 3},
+		{vcs.CommitsOptions{Skip: 1, N: 1}, []vcs.CommitID{"c"}, 3},
+		{vcs.CommitsOptions{N: 2, NoTotal: true}, []vcs.CommitID{"a", "c"}, 0},
+	}
+	for _, test := range tests {
+		it := FilterCommits(&sliceIterator{commits: commits}, patterns.Match, 0, 0)
+		list, total, err := ListCommits(it, test.opt)
+		if err != nil {
+			t.Errorf("%+v: ListCommits: %s", test.opt, err)
+			continue
+		}
+		var ids []vcs.CommitID
+		for _, c := range list {
+			ids = append(ids, c.ID)
+		}
+		if !reflect.DeepEqual(ids, test.wantIDs) || total != test.wantTotal {
+			t.Errorf("%+v: got %v (total %d), want %v (total %d)", test.opt, ids, total, test.wantIDs, test.wantTotal)
+		}
+	}
+
+	it := FilterCommits(&sliceIterator{commits: commits}, patterns.Match, 1, 1)
+	if c := it.Next(); c == nil || c.ID != "c" {
+		t.Errorf("got first filtered commit %v, want c", c)
+	}
+	if c := it.Next(); c != nil {
+		t.Errorf("got second filtered commit %v, want none", c)
+	}
+}
+
+type sliceIterator struct{ commits []*vcs.Commit }
+
+func (it *sliceIterator) Next() *vcs.Commit {
+	if len(it.commits) == 0 {
+		return nil
+	}
+	c := it.commits[0]
+	it.commits = it.commits[1:]
+	return c
+}
+
+func (it *sliceIterator) Err() error   { return nil }
+func (it *sliceIterator) Close() error { return nil }
@@ -0,0 +1,321 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+// ParseDiff parses a git-style unified diff (the output of `git diff`
+// or `hg diff --git`) into per-file diffs. The file names in the diff
+// are expected to begin with origPrefix and newPrefix (e.g., "a/" and
+// "b/"), which are stripped from the names in the returned FileDiffs.
+func ParseDiff(raw []byte, origPrefix, newPrefix string) ([]*vcs.FileDiff, error) {
+	p := diffParser{origPrefix: origPrefix, newPrefix: newPrefix}
+	if err := p.parse(raw); err != nil {
+		return nil, err
+	}
+	return p.files, nil
+}
+
+type diffParser struct {
+	origPrefix, newPrefix string
+
+	files []*vcs.FileDiff
+	f     *vcs.FileDiff // current file
+	h     *vcs.DiffHunk // current hunk
+
+	// origLeft and newLeft are the number of lines remaining in the
+	// current hunk. Hunk bodies must be delimited by counting lines,
+	// because a body line like "--- x" (deletion of the line "-- x")
+	// looks like a file header.
+	origLeft, newLeft int32
+
+	inBinaryPatch bool // skipping the body of a "GIT binary patch"
+}
+
+func (p *diffParser) parse(raw []byte) error {
+	raw = bytes.TrimSuffix(raw, []byte("\n"))
+	if len(raw) == 0 {
+		return nil
+	}
+	for i, line := range strings.Split(string(raw), "\n") {
+		if err := p.parseLine(line); err != nil {
+			return fmt.Errorf("parsing diff line %d: %s", i+1, err)
+		}
+	}
+	if p.h != nil && (p.origLeft > 0 || p.newLeft > 0) {
+		return fmt.Errorf("unexpected end of diff in hunk %q", hunkHeader(p.h))
+	}
+	return nil
+}
+
+func (p *diffParser) parseLine(line string) error {
+	if p.h != nil && (p.origLeft > 0 || p.newLeft > 0) {
+		return p.parseHunkLine(line)
+	}
+	if p.h != nil && strings.HasPrefix(line, `\`) {
+		// "\ No newline at end of file" after the last line of a hunk.
+		return p.parseHunkLine(line)
+	}
+
+	switch {
+	case strings.HasPrefix(line, "diff --git "):
+		p.startFile()
+		p.f.OrigName, p.f.NewName = p.parseGitHeaderNames(line[len("diff --git "):])
+		return nil
+
+	case strings.HasPrefix(line, "@@ "):
+		if p.f == nil {
+			return fmt.Errorf("hunk outside of file: %q", line)
+		}
+		return p.startHunk(line)
+
+	case p.inBinaryPatch:
+		return nil
+
+	case strings.HasPrefix(line, "--- "):
+		if p.f == nil || p.h != nil {
+			// A plain unified diff without "diff --git" lines.
+			p.startFile()
+		}
+		p.f.OrigName = p.parseName(line[len("--- "):], p.origPrefix)
+		if p.f.OrigName == "" && p.f.Status == vcs.FileModified {
+			p.f.Status = vcs.FileAdded
+		}
+		return nil
+
+	case p.f == nil:
+		// Ignore any preamble before the first file.
+		return nil
+
+	case strings.HasPrefix(line, "+++ "):
+		p.f.NewName = p.parseName(line[len("+++ "):], p.newPrefix)
+		if p.f.NewName == "" && p.f.Status == vcs.FileModified {
+			p.f.Status = vcs.FileDeleted
+		}
+		return nil
+	}
+
+	if p.h != nil {
+		return fmt.Errorf("unexpected line after hunk: %q", line)
+	}
+	return p.parseExtendedHeader(line)
+}
+
+func (p *diffParser) startFile() {
+	p.f = &vcs.FileDiff{Status: vcs.FileModified}
+	p.files = append(p.files, p.f)
+	p.h = nil
+	p.inBinaryPatch = false
+}
+
+// parseExtendedHeader parses git's extended header lines (e.g., "new
+// file mode 100644" or "rename from foo").
+func (p *diffParser) parseExtendedHeader(line string) error {
+	var err error
+	switch {
+	case strings.HasPrefix(line, "old mode "):
+		p.f.OrigMode, err = parseMode(line[len("old mode "):])
+	case strings.HasPrefix(line, "new mode "):
+		p.f.NewMode, err = parseMode(line[len("new mode "):])
+	case strings.HasPrefix(line, "new file mode "):
+		p.f.Status = vcs.FileAdded
+		p.f.OrigName = ""
+		p.f.NewMode, err = parseMode(line[len("new file mode "):])
+	case strings.HasPrefix(line, "deleted file mode "):
+		p.f.Status = vcs.FileDeleted
+		p.f.NewName = ""
+		p.f.OrigMode, err = parseMode(line[len("deleted file mode "):])
+	case strings.HasPrefix(line, "rename from "):
+		p.f.Status = vcs.FileRenamed
+		p.f.OrigName = p.parseName(line[len("rename from "):], "")
+	case strings.HasPrefix(line, "rename to "):
+		p.f.Status = vcs.FileRenamed
+		p.f.NewName = p.parseName(line[len("rename to "):], "")
+	case strings.HasPrefix(line, "copy from "):
+		p.f.Status = vcs.FileCopied
+		p.f.OrigName = p.parseName(line[len("copy from "):], "")
+	case strings.HasPrefix(line, "copy to "):
+		p.f.Status = vcs.FileCopied
+		p.f.NewName = p.parseName(line[len("copy to "):], "")
+	case strings.HasPrefix(line, "index "):
+		// "index <orig>..<new>[ <mode>]"
+		fields := strings.Fields(line[len("index "):])
+		ids := strings.SplitN(fields[0], "..", 2)
+		if len(ids) != 2 {
+			return fmt.Errorf("invalid index line: %q", line)
+		}
+		p.f.OrigID, p.f.NewID = blobID(ids[0]), blobID(ids[1])
+		if len(fields) > 1 {
+			p.f.OrigMode, err = parseMode(fields[1])
+			p.f.NewMode = p.f.OrigMode
+		}
+	case strings.HasPrefix(line, "Binary files ") && strings.HasSuffix(line, " differ"):
+		p.f.Binary = true
+	case line == "GIT binary patch":
+		p.f.Binary = true
+		p.inBinaryPatch = true
+	}
+	// Other lines (e.g., "similarity index 90%") carry no
+	// information that FileDiff represents.
+	return err
+}
+
+func (p *diffParser) startHunk(line string) error {
+	// "@@ -l,s +l,s @@ section", where the ",s" parts are optional.
+	end := strings.Index(line[len("@@ "):], " @@")
+	if end == -1 {
+		return fmt.Errorf("invalid hunk header: %q", line)
+	}
+	ranges := strings.Fields(line[len("@@ ") : len("@@ ")+end])
+	if len(ranges) != 2 || !strings.HasPrefix(ranges[0], "-") || !strings.HasPrefix(ranges[1], "+") {
+		return fmt.Errorf("invalid hunk header: %q", line)
+	}
+	h := &vcs.DiffHunk{Section: strings.TrimPrefix(line[len("@@ ")+end+len(" @@"):], " ")}
+	var err error
+	if h.OrigStartLine, h.OrigLines, err = parseHunkRange(ranges[0][1:]); err != nil {
+		return err
+	}
+	if h.NewStartLine, h.NewLines, err = parseHunkRange(ranges[1][1:]); err != nil {
+		return err
+	}
+	p.f.Hunks = append(p.f.Hunks, h)
+	p.h = h
+	p.origLeft, p.newLeft = h.OrigLines, h.NewLines
+	return nil
+}
+
+func (p *diffParser) parseHunkLine(line string) error {
+	if strings.HasPrefix(line, `\`) {
+		if len(p.h.Lines) == 0 {
+			return fmt.Errorf("%q at beginning of hunk", line)
+		}
+		p.h.Lines[len(p.h.Lines)-1].NoNewline = true
+		return nil
+	}
+
+	var op string
+	if line == "" {
+		// Some tools strip the trailing space from empty context lines.
+		op = vcs.DiffLineContext
+	} else {
+		op = line[:1]
+		line = line[1:]
+	}
+	switch op {
+	case vcs.DiffLineContext:
+		p.origLeft--
+		p.newLeft--
+	case vcs.DiffLineDeleted:
+		p.origLeft--
+	case vcs.DiffLineAdded:
+		p.newLeft--
+	default:
+		return fmt.Errorf("invalid line in hunk %q: %q", hunkHeader(p.h), op+line)
+	}
+	if p.origLeft < 0 || p.newLeft < 0 {
+		return fmt.Errorf("too many lines in hunk %q", hunkHeader(p.h))
+	}
+	p.h.Lines = append(p.h.Lines, &vcs.DiffLine{Op: op, Text: line})
+	return nil
+}
+
+// parseName parses a file name from a diff header, stripping prefix
+// and any trailing timestamp. It returns "" for "/dev/null".
+func (p *diffParser) parseName(s, prefix string) string {
+	if i := strings.Index(s, "\t"); i != -1 {
+		s = s[:i]
+	}
+	if strings.HasPrefix(s, `"`) {
+		if uq, err := strconv.Unquote(s); err == nil {
+			s = uq
+		}
+	}
+	if s == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+// parseGitHeaderNames parses the names from the rest of a "diff --git
+// <orig> <new>" line. They are only used when the file has no "---"
+// and "+++" lines (e.g., binary files and pure mode changes).
+// Unquoted names are ambiguous if they contain spaces, so this
+// assumes that both names are the same, which holds for all diffs
+// without rename or copy lines (which provide the names themselves).
+func (p *diffParser) parseGitHeaderNames(s string) (origName, newName string) {
+	if strings.HasPrefix(s, `"`) {
+		if i := closingQuote(s); i != -1 && i+2 < len(s) {
+			return p.parseName(s[:i+1], p.origPrefix), p.parseName(s[i+2:], p.newPrefix)
+		}
+	}
+	n := len(s) - 1 - len(p.origPrefix) - len(p.newPrefix)
+	if n > 0 && n%2 == 0 {
+		n /= 2
+		origName, newName = s[:len(p.origPrefix)+n], s[len(p.origPrefix)+n+1:]
+		if strings.HasPrefix(origName, p.origPrefix) && strings.HasPrefix(newName, p.newPrefix) &&
+			origName[len(p.origPrefix):] == newName[len(p.newPrefix):] {
+			return origName[len(p.origPrefix):], newName[len(p.newPrefix):]
+		}
+	}
+	if i := strings.Index(s, " "+p.newPrefix); i != -1 {
+		return strings.TrimPrefix(s[:i], p.origPrefix), s[i+1+len(p.newPrefix):]
+	}
+	return "", ""
+}
+
+// closingQuote returns the index of the quote that ends the quoted
+// string at the beginning of s, or -1 if there is none.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func parseHunkRange(s string) (start, lines int32, err error) {
+	lines = 1 // a missing line count means 1
+	if i := strings.Index(s, ","); i != -1 {
+		n, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid hunk range %q: %s", s, err)
+		}
+		lines = int32(n)
+		s = s[:i]
+	}
+	n, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid hunk range %q: %s", s, err)
+	}
+	return int32(n), lines, nil
+}
+
+func parseMode(s string) (uint32, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid file mode %q: %s", s, err)
+	}
+	return uint32(mode), nil
+}
+
+// blobID returns id, or "" if id is all zeroes (which denotes a
+// nonexistent blob).
+func blobID(id string) string {
+	if strings.Trim(id, "0") == "" {
+		return ""
+	}
+	return id
+}
+
+func hunkHeader(h *vcs.DiffHunk) string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OrigStartLine, h.OrigLines, h.NewStartLine, h.NewLines)
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestParseDiff(t *testing.T) {
+	tests := map[string]struct {
+		raw  string
+		want []*vcs.FileDiff
+	}{
+		"empty": {
+			raw:  "",
+			want: nil,
+		},
+		"added": {
+			raw: "diff --git a/f b/f\nnew file mode 100644\nindex 0000000..a29bdeb\n--- /dev/null\n+++ b/f\n@@ -0,0 +1 @@\n+line1\n",
+			want: []*vcs.FileDiff{
+				{NewName: "f", NewMode: 0100644, NewID: "a29bdeb", Status: vcs.FileAdded, Hunks: []*vcs.DiffHunk{
+					{OrigStartLine: 0, OrigLines: 0, NewStartLine: 1, NewLines: 1, Lines: []*vcs.DiffLine{
+						{Op: vcs.DiffLineAdded, Text: "line1"},
+					}},
+				}},
+			},
+		},
+		"deleted": {
+			raw: "diff --git a/f b/f\ndeleted file mode 100755\nindex a29bdeb..0000000\n--- a/f\n+++ /dev/null\n@@ -1 +0,0 @@\n-line1\n",
+			want: []*vcs.FileDiff{
+				{OrigName: "f", OrigMode: 0100755, OrigID: "a29bdeb", Status: vcs.FileDeleted, Hunks: []*vcs.DiffHunk{
+					{OrigStartLine: 1, OrigLines: 1, NewStartLine: 0, NewLines: 0, Lines: []*vcs.DiffLine{
+						{Op: vcs.DiffLineDeleted, Text: "line1"},
+					}},
+				}},
+			},
+		},
+		"renamed and mode changed, then binary": {
+			raw: "diff --git a/f b/g\nold mode 100644\nnew mode 100755\nsimilarity index 100%\nrename from f\nrename to g\ndiff --git a/b.bin b/b.bin\nindex c0d0fb4..83db48f 100644\nBinary files a/b.bin and b/b.bin differ\n",
+			want: []*vcs.FileDiff{
+				{OrigName: "f", NewName: "g", OrigMode: 0100644, NewMode: 0100755, Status: vcs.FileRenamed},
+				{OrigName: "b.bin", NewName: "b.bin", OrigMode: 0100644, NewMode: 0100644, OrigID: "c0d0fb4", NewID: "83db48f", Status: vcs.FileModified, Binary: true},
+			},
+		},
+		"header-like lines in hunk, no newline": {
+			raw: "diff --git a/f b/f\nindex c0d0fb4..83db48f 100644\n--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@ func f()\n--- x\n+++ y\n line2\n\\ No newline at end of file\n",
+			want: []*vcs.FileDiff{
+				{OrigName: "f", NewName: "f", OrigMode: 0100644, NewMode: 0100644, OrigID: "c0d0fb4", NewID: "83db48f", Status: vcs.FileModified, Hunks: []*vcs.DiffHunk{
+					{OrigStartLine: 1, OrigLines: 2, NewStartLine: 1, NewLines: 2, Section: "func f()", Lines: []*vcs.DiffLine{
+						{Op: vcs.DiffLineDeleted, Text: "-- x"},
+						{Op: vcs.DiffLineAdded, Text: "++ y"},
+						{Op: vcs.DiffLineContext, Text: "line2", NoNewline: true},
+					}},
+				}},
+			},
+		},
+		"quoted names": {
+			raw: "diff --git \"a/a b\" \"b/a b\"\nold mode 100644\nnew mode 100755\n",
+			want: []*vcs.FileDiff{
+				{OrigName: "a b", NewName: "a b", OrigMode: 0100644, NewMode: 0100755, Status: vcs.FileModified},
+			},
+		},
+	}
+	for label, test := range tests {
+		files, err := ParseDiff([]byte(test.raw), "a/", "b/")
+		if err != nil {
+			t.Errorf("%s: ParseDiff: %s", label, err)
+			continue
+		}
+		if !reflect.DeepEqual(files, test.want) {
+			t.Errorf("%s: got files\n%s\n\nwant\n%s", label, asJSON(files), asJSON(test.want))
+		}
+	}
+}
+
+func TestParseDiff_truncated(t *testing.T) {
+	raw := "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n line1\n"
+	if _, err := ParseDiff([]byte(raw), "a/", "b/"); err == nil {
+		t.Error("ParseDiff of truncated hunk: want error, got nil")
+	}
+}
+
+func asJSON(v interface{}) string {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
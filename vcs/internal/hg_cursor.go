@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+// An HgCommitsCursor is the state of an hg CommitsPage walk, which
+// visits the ancestors of the head by descending revision number.
+// Because a revision's parents have lower revision numbers than the
+// revision itself, the commits that the walk visits next are the
+// ancestors of Heads, and those excluded by the base are the
+// ancestors of Bases.
+//
+// Heads are the unvisited parents of the visited commits, and Bases
+// are the parents of the base's ancestors that were passed by the
+// walk, so neither depends on the number of commits visited so far.
+type HgCommitsCursor struct {
+	Heads []int
+	Bases []int
+}
+
+// ParseHgCommitsCursor parses a cursor returned by
+// (*HgCommitsCursor).String. It returns vcs.ErrInvalidCursor if s is
+// not a valid cursor.
+func ParseHgCommitsCursor(s string) (*HgCommitsCursor, error) {
+	parts := strings.Split(s, ";")
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, vcs.ErrInvalidCursor
+	}
+	var cur HgCommitsCursor
+	for i, revs := range []*[]int{&cur.Heads, &cur.Bases} {
+		if parts[i] == "" {
+			continue
+		}
+		for _, field := range strings.Split(parts[i], ",") {
+			rev, err := strconv.Atoi(field)
+			if err != nil || rev < 0 {
+				return nil, vcs.ErrInvalidCursor
+			}
+			*revs = append(*revs, rev)
+		}
+	}
+	return &cur, nil
+}
+
+// String returns the cursor as a string of the form
+// "head,head;base,base", with the revisions in descending order and
+// without duplicates.
+func (c *HgCommitsCursor) String() string {
+	return formatRevs(c.Heads) + ";" + formatRevs(c.Bases)
+}
+
+func formatRevs(revs []int) string {
+	revs = append([]int(nil), revs...)
+	sort.Sort(sort.Reverse(sort.IntSlice(revs)))
+	var fields []string
+	for i, rev := range revs {
+		if i == 0 || rev != revs[i-1] {
+			fields = append(fields, strconv.Itoa(rev))
+		}
+	}
+	return strings.Join(fields, ",")
+}
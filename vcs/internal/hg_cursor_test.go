@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestHgCommitsCursor(t *testing.T) {
+	tests := map[string]*HgCommitsCursor{
+		"3;":      {Heads: []int{3}},
+		"5,2;4,0": {Heads: []int{2, 5, 2}, Bases: []int{0, 4}},
+	}
+	for want, cur := range tests {
+		if s := cur.String(); s != want {
+			t.Errorf("%v: got String %q, want %q", cur, s, want)
+			continue
+		}
+		parsed, err := ParseHgCommitsCursor(want)
+		if err != nil {
+			t.Errorf("ParseHgCommitsCursor(%q): %s", want, err)
+			continue
+		}
+		if parsed.String() != want {
+			t.Errorf("ParseHgCommitsCursor(%q): got %v", want, parsed)
+		}
+	}
+
+	for _, s := range []string{"", "3", ";1", "a;", "1,,2;", "-1;", "1;2;3"} {
+		if cur, err := ParseHgCommitsCursor(s); err != vcs.ErrInvalidCursor {
+			t.Errorf("ParseHgCommitsCursor(%q): got %v (error %v), want %v", s, cur, err, vcs.ErrInvalidCursor)
+		}
+	}
+	if cur, _ := ParseHgCommitsCursor("7,1;"); !reflect.DeepEqual(cur.Heads, []int{7, 1}) || cur.Bases != nil {
+		t.Errorf("got %+v, want heads [7 1] and no bases", cur)
+	}
+}
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"fmt"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+// A HistoryMatcher matches the query of a vcs.HistorySearchOptions
+// against the diffs of commits. Backends use it to select the commits
+// and hunks in Go (even if they use a VCS command to find the commits
+// that may match) so that history searches have the same semantics on
+// all backends.
+type HistoryMatcher struct {
+	m     *SearchMatcher
+	lines bool // HistorySearchLines mode
+}
+
+// CompileHistorySearch compiles the query of opt.
+func CompileHistorySearch(opt vcs.HistorySearchOptions) (*HistoryMatcher, error) {
+	var lines bool
+	switch opt.Mode {
+	case "", vcs.HistorySearchOccurrences:
+	case vcs.HistorySearchLines:
+		lines = true
+	default:
+		return nil, fmt.Errorf("unrecognized history search Mode: %q", opt.Mode)
+	}
+	m, err := CompileSearch(vcs.SearchOptions{Query: opt.Query, QueryType: opt.QueryType})
+	if err != nil {
+		return nil, err
+	}
+	return &HistoryMatcher{m: m, lines: lines}, nil
+}
+
+// RequiredLiteral returns a string that every added or removed line
+// that matches the query contains, or "" if there is none, so that
+// VCS commands can prefilter the commits that may match (see
+// RequiredLiteral).
+func (hm *HistoryMatcher) RequiredLiteral() (lit string, foldCase bool) {
+	return hm.m.RequiredLiteral()
+}
+
+// MatchDiff returns the files of a commit's diff whose changes match
+// the query, with only the hunks that add or remove a matching line.
+// It returns nil if the commit doesn't match.
+func (hm *HistoryMatcher) MatchDiff(files []*vcs.FileDiff) []*vcs.FileDiff {
+	var matched []*vcs.FileDiff
+	for _, f := range files {
+		var hunks []*vcs.DiffHunk
+		var added, deleted int // occurrences of the query
+		for _, h := range f.Hunks {
+			hunkMatches := false
+			for _, l := range h.Lines {
+				if l.Op == vcs.DiffLineContext {
+					continue
+				}
+				var n int
+				if hm.lines {
+					if hm.m.Match([]byte(l.Text)) {
+						n = 1
+					}
+				} else {
+					n = len(hm.m.submatches([]byte(l.Text)))
+				}
+				if n == 0 {
+					continue
+				}
+				hunkMatches = true
+				if l.Op == vcs.DiffLineAdded {
+					added += n
+				} else {
+					deleted += n
+				}
+			}
+			if hunkMatches {
+				hunks = append(hunks, h)
+			}
+		}
+
+		// Lines that are in both the original and new file aren't in
+		// the diff, so the number of occurrences in the file changes
+		// iff the added and deleted lines have different numbers of
+		// occurrences.
+		if len(hunks) == 0 || (!hm.lines && added == deleted) {
+			continue
+		}
+		fm := *f
+		fm.Hunks = hunks
+		matched = append(matched, &fm)
+	}
+	return matched
+}
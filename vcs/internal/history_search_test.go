@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestHistoryMatcher_MatchDiff(t *testing.T) {
+	hunk := func(lines ...string) *vcs.DiffHunk {
+		h := &vcs.DiffHunk{}
+		for _, l := range lines {
+			h.Lines = append(h.Lines, &vcs.DiffLine{Op: l[:1], Text: l[1:]})
+		}
+		return h
+	}
+	moved := &vcs.FileDiff{NewName: "moved", Hunks: []*vcs.DiffHunk{
+		hunk("+foo", " a"),
+		hunk(" b", "-foo"),
+	}}
+	changed := &vcs.FileDiff{NewName: "changed", Hunks: []*vcs.DiffHunk{
+		hunk(" foo", "-x", "+y"),
+		hunk("-foo foo", "+foo"),
+	}}
+	context := &vcs.FileDiff{NewName: "context", Hunks: []*vcs.DiffHunk{
+		hunk(" foo", "+x"),
+	}}
+	files := []*vcs.FileDiff{moved, changed, context}
+
+	tests := []struct {
+		mode      string
+		wantFiles map[string]int // file name -> number of hunks
+	}{
+		{vcs.HistorySearchOccurrences, map[string]int{"changed": 1}},
+		{vcs.HistorySearchLines, map[string]int{"moved": 2, "changed": 1}},
+	}
+	for _, test := range tests {
+		hm, err := CompileHistorySearch(vcs.HistorySearchOptions{Query: "foo", QueryType: vcs.FixedQuery, Mode: test.mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotFiles := map[string]int{}
+		for _, f := range hm.MatchDiff(files) {
+			gotFiles[f.NewName] = len(f.Hunks)
+		}
+		if !reflect.DeepEqual(gotFiles, test.wantFiles) {
+			t.Errorf("%s: got files %v, want %v", test.mode, gotFiles, test.wantFiles)
+		}
+	}
+	if len(changed.Hunks) != 2 {
+		t.Error("MatchDiff modified its argument")
+	}
+
+	if _, err := CompileHistorySearch(vcs.HistorySearchOptions{QueryType: vcs.FixedQuery, Mode: "foo"}); err == nil {
+		t.Error("invalid Mode: got no error")
+	}
+}
+
+func TestHistoryMatcher_RequiredLiteral(t *testing.T) {
+	tests := []struct {
+		opt      vcs.HistorySearchOptions
+		lit      string
+		foldCase bool
+	}{
+		{vcs.HistorySearchOptions{Query: "a.b", QueryType: vcs.FixedQuery}, "a.b", false},
+		{vcs.HistorySearchOptions{Query: "foo", QueryType: vcs.IgnoreCaseQuery}, "FOO", true},
+		{vcs.HistorySearchOptions{Query: `Fo+\(`, QueryType: vcs.RegexpQuery}, "F", false},
+		{vcs.HistorySearchOptions{Query: `[ab]cd{1,2}`, QueryType: vcs.ExtendedRegexpQuery}, "cd", false},
+		{vcs.HistorySearchOptions{Query: `a|b`, QueryType: vcs.RegexpQuery}, "", false},
+	}
+	for _, test := range tests {
+		hm, err := CompileHistorySearch(test.opt)
+		if err != nil {
+			t.Errorf("%+v: CompileHistorySearch: %s", test.opt, err)
+			continue
+		}
+		if lit, foldCase := hm.RequiredLiteral(); lit != test.lit || foldCase != test.foldCase {
+			t.Errorf("%+v: got RequiredLiteral %q, %v, want %q, %v", test.opt, lit, foldCase, test.lit, test.foldCase)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package internal
+
+// maxLineDiffEdits bounds the number of edits (added and deleted
+// lines) that MatchLines searches for. The memory needed by the diff
+// grows with the square of the number of edits, so files that are
+// rewritten more extensively are treated as entirely changed (except
+// for their common prefix and suffix).
+const maxLineDiffEdits = 2000
+
+// MatchLines computes a minimal diff between the lines a and b (with
+// Myers's algorithm) and returns, for each line of b, the index of
+// the line of a that it matches, or -1 if the line was added.
+func MatchLines(a, b []string) []int {
+	match := make([]int, len(b))
+	for i := range match {
+		match[i] = -1
+	}
+
+	// Lines in the common prefix and suffix always match.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		match[prefix] = prefix
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		match[len(b)-1-suffix] = len(a) - 1 - suffix
+		suffix++
+	}
+
+	a, b = a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	for _, m := range myersMatches(a, b) {
+		match[prefix+m[1]] = prefix + m[0]
+	}
+	return match
+}
+
+// myersMatches returns the pairs of indexes of matching lines in a
+// and b in a minimal diff of a and b, or nil if the diff has more than
+// maxLineDiffEdits edits.
+func myersMatches(a, b []string) [][2]int {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return nil
+	}
+	max := n + m
+	if max > maxLineDiffEdits {
+		max = maxLineDiffEdits
+	}
+
+	// v[k+max] is the furthest x reached on diagonal k (x - y = k).
+	// trace[d] is v (restricted to diagonals -d..d) before step d.
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[max-d:max+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1] // down (insertion)
+			} else {
+				x = v[max+k-1] + 1 // right (deletion)
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				return myersBacktrack(trace, n, m)
+			}
+		}
+	}
+	return nil
+}
+
+// myersBacktrack follows the path found by myersMatches back from
+// (n, m) and returns the matching lines on it in order.
+func myersBacktrack(trace [][]int, n, m int) [][2]int {
+	var matches [][2]int
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		// trace[d] holds diagonals -d..d+1 at offsets 0..2d+1.
+		vAt := func(k int) int { return trace[d][k+d] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && vAt(k-1) < vAt(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := vAt(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			matches = append(matches, [2]int{x, y})
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	return matches
+}
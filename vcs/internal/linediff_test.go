@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchLines(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want []int
+	}{
+		{"", "", []int{}},
+		{"a b c", "", []int{}},
+		{"", "a b", []int{-1, -1}},
+		{"a b c", "a b c", []int{0, 1, 2}},
+		{"a b c", "a x c", []int{0, -1, 2}},
+		{"a b c", "a c", []int{0, 2}},
+		{"a b c", "x a b c y", []int{-1, 0, 1, 2, -1}},
+		{"a b c d e", "c d e a b", []int{2, 3, 4, -1, -1}},
+		{"a b x c d", "a y b c z d", []int{0, -1, 1, 3, -1, 4}},
+		{"x a b y", "a x b", []int{1, -1, 2}},
+	}
+	for _, test := range tests {
+		a, b := strings.Fields(test.a), strings.Fields(test.b)
+		if match := MatchLines(a, b); !reflect.DeepEqual(match, test.want) {
+			t.Errorf("%q -> %q: got %v, want %v", test.a, test.b, match, test.want)
+		}
+	}
+}
+
+func TestMatchLines_tooManyEdits(t *testing.T) {
+	// Only the common prefix and suffix match when the diff is too
+	// large.
+	var a, b []string
+	a = append(a, "p")
+	b = append(b, "p")
+	for i := 0; i < maxLineDiffEdits; i++ {
+		a = append(a, "a")
+		b = append(b, "b")
+	}
+	a = append(a, "s")
+	b = append(b, "s")
+
+	match := MatchLines(a, b)
+	if match[0] != 0 || match[len(b)-1] != len(a)-1 {
+		t.Errorf("common prefix and suffix didn't match: %d, %d", match[0], match[len(b)-1])
+	}
+	for i := 1; i < len(b)-1; i++ {
+		if match[i] != -1 {
+			t.Fatalf("line %d: got match %d, want -1", i, match[i])
+		}
+	}
+}
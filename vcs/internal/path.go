@@ -1,6 +1,9 @@
 package internal
 
-import "strings"
+import (
+	"path"
+	"strings"
+)
 
 // Rel strips the leading "/" prefix from the path string, effectively turning
 // an absolute path into one relative to the root directory. A path that is just
@@ -14,3 +17,34 @@ func Rel(path string) string {
 	}
 	return strings.TrimPrefix(path, "/")
 }
+
+// MatchGlob reports whether the slash-separated path name matches the
+// glob pattern, with the semantics of git's glob pathspecs: "*", "?"
+// and "[...]" match within a path element (as in path.Match), a "**"
+// element matches any number of path elements, and a pattern that
+// matches a directory matches all of the files in it.
+func MatchGlob(pattern, name string) (bool, error) {
+	return matchGlobElems(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(name, "/"))
+}
+
+func matchGlobElems(pattern, name []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if ok, err := matchGlobElems(pattern[1:], name[i:]); ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(name) == 0 {
+			return false, nil
+		}
+		if ok, err := path.Match(pattern[0], name[0]); !ok || err != nil {
+			return false, err
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	// The pattern matched the whole path or one of its directories.
+	return true, nil
+}
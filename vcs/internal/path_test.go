@@ -0,0 +1,42 @@
+package internal
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"a.go", "a.go", true},
+		{"*.go", "a.go", true},
+		{"*.go", "d/a.go", false},
+		{"d/*.go", "d/a.go", true},
+		{"d/*.go", "d/e/a.go", false},
+		{"**/*.go", "a.go", true},
+		{"**/*.go", "d/e/a.go", true},
+		{"d/**/a.go", "d/a.go", true},
+		{"d/**/a.go", "d/e/f/a.go", true},
+		{"d/**", "d/e/a.go", true},
+		{"d/**", "e/a.go", false},
+		{"vendor", "vendor/a/b.go", true},
+		{"vendor/", "vendor/a/b.go", true},
+		{"vendor", "a/vendor/b.go", false},
+		{"**/vendor", "a/vendor/b.go", true},
+		{"vend?r", "vendor/b.go", true},
+		{"a.go", "a.gox", false},
+	}
+	for _, test := range tests {
+		match, err := MatchGlob(test.pattern, test.name)
+		if err != nil {
+			t.Errorf("MatchGlob(%q, %q): %s", test.pattern, test.name, err)
+			continue
+		}
+		if match != test.want {
+			t.Errorf("MatchGlob(%q, %q): got %v, want %v", test.pattern, test.name, match, test.want)
+		}
+	}
+
+	if _, err := MatchGlob("[", "a"); err == nil {
+		t.Error("MatchGlob([, a): got no error")
+	}
+}
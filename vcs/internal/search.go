@@ -0,0 +1,382 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+// A SearchMatcher matches the query of a vcs.SearchOptions against
+// lines of text, and its path and size options against files.
+// Backends use it to match lines in Go (even if they use a search tool
+// to find the files that may match) so that searches have the same
+// semantics on all backends.
+type SearchMatcher struct {
+	re        *regexp.Regexp
+	wholeWord bool
+	posix     bool // whether the query is an ExtendedRegexpQuery
+
+	include, exclude []string // glob patterns (see MatchGlob)
+	extensions       []string // with a leading "."
+	maxFileSize      int64
+}
+
+// nonWordChar matches a character that can't be part of a word, the
+// same as in grep -w.
+const nonWordChar = "[^0-9A-Za-z_]"
+
+// CompileSearch compiles the query of opt.
+func CompileSearch(opt vcs.SearchOptions) (*SearchMatcher, error) {
+	var expr string
+	compile := regexp.Compile
+	switch opt.QueryType {
+	case vcs.FixedQuery:
+		expr = regexp.QuoteMeta(opt.Query)
+	case vcs.IgnoreCaseQuery:
+		expr = "(?i)" + regexp.QuoteMeta(opt.Query)
+	case vcs.RegexpQuery:
+		expr = opt.Query
+	case vcs.ExtendedRegexpQuery:
+		expr = opt.Query
+		compile = regexp.CompilePOSIX
+	default:
+		return nil, fmt.Errorf("unrecognized QueryType: %q", opt.QueryType)
+	}
+	if _, err := compile(expr); err != nil {
+		return nil, err
+	}
+	if opt.WholeWord {
+		// Only groups without flags are valid POSIX syntax. The query
+		// is the second group.
+		expr = "(^|" + nonWordChar + ")(" + expr + ")(" + nonWordChar + "|$)"
+	}
+	re, err := compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, patterns := range [][]string{opt.IncludePatterns, opt.ExcludePatterns} {
+		for _, pattern := range patterns {
+			if _, err := MatchGlob(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid glob pattern %q: %s", pattern, err)
+			}
+		}
+	}
+	var extensions []string
+	for _, ext := range opt.Extensions {
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions = append(extensions, ext)
+	}
+
+	return &SearchMatcher{
+		re:          re,
+		wholeWord:   opt.WholeWord,
+		posix:       opt.QueryType == vcs.ExtendedRegexpQuery,
+		include:     opt.IncludePatterns,
+		exclude:     opt.ExcludePatterns,
+		extensions:  extensions,
+		maxFileSize: opt.MaxFileSize,
+	}, nil
+}
+
+// RequiredLiteral returns a string that every matching line contains,
+// or "" if there is none, so that VCS commands can prefilter the files
+// (or commits) that may match (see RequiredLiteral).
+func (m *SearchMatcher) RequiredLiteral() (lit string, foldCase bool) {
+	flags := syntax.Perl
+	if m.posix {
+		flags = syntax.POSIX
+	}
+	return parseRequiredLiteral(m.re.String(), flags)
+}
+
+// MatchPath reports whether the file at the slash-separated path name
+// should be searched, according to the include and exclude patterns
+// and the extensions of the search options.
+func (m *SearchMatcher) MatchPath(name string) bool {
+	if len(m.include) > 0 && !matchAnyGlob(m.include, name) {
+		return false
+	}
+	if matchAnyGlob(m.exclude, name) {
+		return false
+	}
+	if len(m.extensions) > 0 {
+		ext := path.Ext(name)
+		for _, e := range m.extensions {
+			if ext == e {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// MatchSize reports whether a file of the given size (in bytes)
+// should be searched, according to the max file size of the search
+// options.
+func (m *SearchMatcher) MatchSize(size int64) bool {
+	return m.maxFileSize <= 0 || size <= m.maxFileSize
+}
+
+func matchAnyGlob(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		// The patterns were validated by CompileSearch.
+		if ok, _ := MatchGlob(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether line (without its trailing newline) matches
+// the query.
+func (m *SearchMatcher) Match(line []byte) bool {
+	return m.re.Match(line)
+}
+
+// submatches returns the non-overlapping parts of a matching line
+// that match the query. Empty matches are omitted.
+func (m *SearchMatcher) submatches(line []byte) []*vcs.SearchSubmatch {
+	var submatches []*vcs.SearchSubmatch
+	add := func(start, end int) {
+		if end > start {
+			submatches = append(submatches, &vcs.SearchSubmatch{Start: uint32(start), End: uint32(end)})
+		}
+	}
+	if !m.wholeWord {
+		for _, loc := range m.re.FindAllIndex(line, -1) {
+			add(loc[0], loc[1])
+		}
+		return submatches
+	}
+
+	// The characters around a whole word are part of the regexp's
+	// match, so a word that shares a delimiter with the previous one
+	// is only found by searching again after the previous word.
+	for start := 0; start <= len(line); {
+		loc := m.re.FindSubmatchIndex(line[start:])
+		if loc == nil {
+			break
+		}
+		wordStart, wordEnd := start+loc[4], start+loc[5] // the second group
+		add(wordStart, wordEnd)
+		if wordEnd > start {
+			start = wordEnd
+		} else {
+			start++
+		}
+	}
+	return submatches
+}
+
+// SearchFile searches the contents of a file and returns a result for
+// each group of matching lines and their context lines, like grep
+// --context does: groups that overlap or are adjacent are merged.
+func (m *SearchMatcher) SearchFile(file string, data []byte, contextLines int) []*vcs.SearchResult {
+	lines := bytes.Split(data, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	// lineStarts[i] is the byte offset of the (0-indexed) line i.
+	lineStarts := make([]int, len(lines))
+	for i := 1; i < len(lines); i++ {
+		lineStarts[i] = lineStarts[i-1] + len(lines[i-1]) + 1
+	}
+
+	var results []*vcs.SearchResult
+	submatches := map[int][]*vcs.SearchSubmatch{} // by 0-indexed line
+	for i, line := range lines {
+		if !m.Match(line) {
+			continue
+		}
+		submatches[i] = m.submatches(line)
+
+		start, end := i-contextLines, i+contextLines // 0-indexed, inclusive
+		if start < 0 {
+			start = 0
+		}
+		if end > len(lines)-1 {
+			end = len(lines) - 1
+		}
+		if n := len(results); n > 0 && start <= int(results[n-1].EndLine) {
+			results[n-1].EndLine = uint32(end + 1)
+			continue
+		}
+		results = append(results, &vcs.SearchResult{File: file, StartLine: uint32(start + 1), EndLine: uint32(end + 1)})
+	}
+	for _, r := range results {
+		first, last := int(r.StartLine)-1, int(r.EndLine)-1
+		r.StartByte = uint32(lineStarts[first])
+		r.EndByte = uint32(lineStarts[last] + len(lines[last]))
+		r.Match = append([]byte(nil), data[r.StartByte:r.EndByte]...)
+		for i := first; i <= last; i++ {
+			sm, match := submatches[i]
+			r.Lines = append(r.Lines, &vcs.SearchLine{Line: uint32(i + 1), Context: !match, Submatches: sm})
+		}
+	}
+	return results
+}
+
+// IsBinary reports whether data is the contents of a binary file,
+// which isn't searched. Like git, it considers a file binary if it has
+// a NUL byte in its first 8000 bytes.
+func IsBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) != -1
+}
+
+// A SearchPage collects the results of a search with the pagination
+// of a vcs.SearchOptions: it skips the first Offset results and holds
+// at most N results (if N is nonzero). A SearchPage created by
+// NewSearchStream passes the results to a function instead.
+type SearchPage struct {
+	Results []*vcs.SearchResult
+
+	// Stats are the statistics of the search. Backends increment
+	// FilesScanned (and call Truncate); Add updates the other fields.
+	Stats vcs.SearchStats
+
+	offset, n int32
+	fn        func(*vcs.SearchResult) bool // if set, results are passed to fn
+	stopped   bool                         // fn returned false
+}
+
+// NewSearchPage returns an empty page of results for opt.
+func NewSearchPage(opt vcs.SearchOptions) *SearchPage {
+	return &SearchPage{offset: opt.Offset, n: opt.N}
+}
+
+// NewSearchStream returns a page for opt that passes each result to fn
+// (instead of holding it). If fn returns false, the page is full.
+func NewSearchStream(opt vcs.SearchOptions, fn func(*vcs.SearchResult) bool) *SearchPage {
+	return &SearchPage{offset: opt.Offset, n: opt.N, fn: fn}
+}
+
+// Add adds results (in order) to the page and reports whether the page
+// is full. If the page fills up before all of results are added, the
+// search is truncated.
+func (p *SearchPage) Add(results []*vcs.SearchResult) bool {
+	for _, r := range results {
+		if p.Full() {
+			p.Stats.Truncated = true
+			break
+		}
+		if p.offset > 0 {
+			p.offset--
+			continue
+		}
+		p.Stats.Matches++
+		if p.fn == nil {
+			p.Results = append(p.Results, r)
+		} else if !p.fn(r) {
+			p.stopped = true
+		}
+	}
+	return p.Full()
+}
+
+// Truncate records that the search stopped, because the page is full,
+// with candidate files left to search.
+func (p *SearchPage) Truncate() {
+	p.Stats.Truncated = true
+}
+
+// Full reports whether the page holds N results (or, for a stream,
+// whether N results were passed to fn or fn returned false).
+func (p *SearchPage) Full() bool {
+	return p.stopped || p.n > 0 && int32(p.Stats.Matches) >= p.n
+}
+
+// A MultiSearch collects the results of searching several revisions.
+// The contents of each file (identified by an ID, such as a git blob
+// ID) are only searched once, even if they are in several revisions
+// or at several paths.
+type MultiSearch struct {
+	m   *SearchMatcher
+	opt vcs.SearchOptions
+
+	byContents map[string][]*vcs.SearchResult    // by contents ID
+	byFile     map[[2]string][]*vcs.SearchResult // by file name and contents ID
+	results    []*vcs.SearchResult
+}
+
+// NewMultiSearch returns an empty search of several revisions for the
+// matcher compiled from opt.
+func NewMultiSearch(m *SearchMatcher, opt vcs.SearchOptions) *MultiSearch {
+	return &MultiSearch{
+		m:          m,
+		opt:        opt,
+		byContents: map[string][]*vcs.SearchResult{},
+		byFile:     map[[2]string][]*vcs.SearchResult{},
+	}
+}
+
+// Searched reports whether the contents with the given ID were
+// searched (by AddContents).
+func (s *MultiSearch) Searched(id string) bool {
+	_, ok := s.byContents[id]
+	return ok
+}
+
+// AddContents searches the contents with the given ID, which were
+// read from the file name. If data is nil (e.g., because the contents
+// are too large to be searched), there are no results.
+func (s *MultiSearch) AddContents(id, name string, data []byte) {
+	var results []*vcs.SearchResult
+	if data != nil && s.m.MatchSize(int64(len(data))) && !IsBinary(data) {
+		results = s.m.SearchFile(name, data, int(s.opt.ContextLines))
+	}
+	if results == nil {
+		results = []*vcs.SearchResult{}
+	}
+	s.byContents[id] = results
+}
+
+// AddFile adds the file name, whose contents (with the given ID) were
+// added with AddContents, in the revision rev. Revisions must be added
+// in order.
+func (s *MultiSearch) AddFile(rev vcs.CommitID, name, id string) {
+	key := [2]string{name, id}
+	results, ok := s.byFile[key]
+	if !ok {
+		for _, r := range s.byContents[id] {
+			fr := *r
+			fr.File = name
+			fr.Revisions = nil
+			results = append(results, &fr)
+		}
+		s.byFile[key] = results
+		s.results = append(s.results, results...)
+	}
+	for _, r := range results {
+		if n := len(r.Revisions); n == 0 || r.Revisions[n-1] != rev {
+			r.Revisions = append(r.Revisions, rev)
+		}
+	}
+}
+
+// Full reports whether the search has all the results of its page
+// (if the search options have an N), so that contents that weren't
+// searched yet don't need to be. Files with results still need to be
+// added for the results to list all of their revisions.
+func (s *MultiSearch) Full() bool {
+	return s.opt.N > 0 && len(s.results) >= int(s.opt.Offset)+int(s.opt.N)
+}
+
+// Results returns the results (in the order that they were first
+// added), paginated according to the search options.
+func (s *MultiSearch) Results() []*vcs.SearchResult {
+	page := NewSearchPage(s.opt)
+	page.Add(s.results)
+	return page.Results
+}
@@ -0,0 +1,319 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestSearchMatcher_Match(t *testing.T) {
+	tests := []struct {
+		opt  vcs.SearchOptions
+		line string
+		want bool
+	}{
+		{vcs.SearchOptions{Query: "a.c", QueryType: vcs.FixedQuery}, "xa.cx", true},
+		{vcs.SearchOptions{Query: "a.c", QueryType: vcs.FixedQuery}, "abc", false},
+		{vcs.SearchOptions{Query: "abc", QueryType: vcs.FixedQuery}, "ABC", false},
+		{vcs.SearchOptions{Query: "abc", QueryType: vcs.IgnoreCaseQuery}, "xABCx", true},
+		{vcs.SearchOptions{Query: "a.c", QueryType: vcs.IgnoreCaseQuery}, "ABC", false},
+		{vcs.SearchOptions{Query: "ÄBC", QueryType: vcs.IgnoreCaseQuery}, "äbc", true},
+		{vcs.SearchOptions{Query: `a\d+c`, QueryType: vcs.RegexpQuery}, "a123c", true},
+		{vcs.SearchOptions{Query: `(?i)^abc$`, QueryType: vcs.RegexpQuery}, "ABC", true},
+		{vcs.SearchOptions{Query: `^abc$`, QueryType: vcs.RegexpQuery}, "xabc", false},
+		{vcs.SearchOptions{Query: `a[0-9]+c|z`, QueryType: vcs.ExtendedRegexpQuery}, "a123c", true},
+		{vcs.SearchOptions{Query: `a[0-9]+c`, QueryType: vcs.ExtendedRegexpQuery}, "abc", false},
+
+		{vcs.SearchOptions{Query: "foo", QueryType: vcs.FixedQuery, WholeWord: true}, "foo", true},
+		{vcs.SearchOptions{Query: "foo", QueryType: vcs.FixedQuery, WholeWord: true}, "a.foo(b)", true},
+		{vcs.SearchOptions{Query: "foo", QueryType: vcs.FixedQuery, WholeWord: true}, "foobar", false},
+		{vcs.SearchOptions{Query: "foo", QueryType: vcs.FixedQuery, WholeWord: true}, "foo_bar", false},
+		{vcs.SearchOptions{Query: "foo", QueryType: vcs.FixedQuery, WholeWord: true}, "foobar foo", true},
+		{vcs.SearchOptions{Query: "FOO", QueryType: vcs.IgnoreCaseQuery, WholeWord: true}, "x foo", true},
+		{vcs.SearchOptions{Query: `fo+|foobar`, QueryType: vcs.RegexpQuery, WholeWord: true}, "foobar", true},
+		{vcs.SearchOptions{Query: `fo+`, QueryType: vcs.ExtendedRegexpQuery, WholeWord: true}, "fooo.", true},
+		{vcs.SearchOptions{Query: `fo+`, QueryType: vcs.ExtendedRegexpQuery, WholeWord: true}, "foox", false},
+	}
+	for _, test := range tests {
+		m, err := CompileSearch(test.opt)
+		if err != nil {
+			t.Errorf("%+v: CompileSearch: %s", test.opt, err)
+			continue
+		}
+		if match := m.Match([]byte(test.line)); match != test.want {
+			t.Errorf("%+v: Match(%q): got %v, want %v", test.opt, test.line, match, test.want)
+		}
+	}
+}
+
+func TestCompileSearch_invalid(t *testing.T) {
+	for _, opt := range []vcs.SearchOptions{
+		{Query: "a", QueryType: "foo"},
+		{Query: "(", QueryType: vcs.RegexpQuery},
+		{Query: `\d`, QueryType: vcs.ExtendedRegexpQuery}, // not POSIX
+	} {
+		if _, err := CompileSearch(opt); err == nil {
+			t.Errorf("%+v: got no error", opt)
+		}
+	}
+}
+
+func TestSearchMatcher_SearchFile(t *testing.T) {
+	m, err := CompileSearch(vcs.SearchOptions{Query: "x", QueryType: vcs.FixedQuery})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("x\na\nb\nc\nx\nd\nx\ne\nf\ng\n")
+
+	results := m.SearchFile("f", data, 1)
+	want := []struct {
+		startLine, endLine uint32
+		startByte, endByte uint32
+		match              string
+		contextLines       []uint32
+	}{
+		{1, 2, 0, 3, "x\na", []uint32{2}},
+		{4, 8, 6, 15, "c\nx\nd\nx\ne", []uint32{4, 6, 8}},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, r := range results {
+		if r.File != "f" || r.StartLine != want[i].startLine || r.EndLine != want[i].endLine || string(r.Match) != want[i].match {
+			t.Errorf("result %d: got %s:%d-%d %q, want %d-%d %q", i, r.File, r.StartLine, r.EndLine, r.Match, want[i].startLine, want[i].endLine, want[i].match)
+		}
+		if r.StartByte != want[i].startByte || r.EndByte != want[i].endByte {
+			t.Errorf("result %d: got bytes %d-%d, want %d-%d", i, r.StartByte, r.EndByte, want[i].startByte, want[i].endByte)
+		}
+		if string(data[r.StartByte:r.EndByte]) != string(r.Match) {
+			t.Errorf("result %d: bytes %d-%d of the file are %q, not the match %q", i, r.StartByte, r.EndByte, data[r.StartByte:r.EndByte], r.Match)
+		}
+		var contextLines []uint32
+		for j, line := range r.Lines {
+			if line.Line != r.StartLine+uint32(j) {
+				t.Errorf("result %d: line %d has number %d", i, j, line.Line)
+			}
+			if line.Context {
+				contextLines = append(contextLines, line.Line)
+			}
+		}
+		if !reflect.DeepEqual(contextLines, want[i].contextLines) {
+			t.Errorf("result %d: got context lines %v, want %v", i, contextLines, want[i].contextLines)
+		}
+	}
+}
+
+func TestSearchMatcher_submatches(t *testing.T) {
+	tests := []struct {
+		opt  vcs.SearchOptions
+		line string
+		want []vcs.SearchSubmatch
+	}{
+		{vcs.SearchOptions{Query: "ab", QueryType: vcs.FixedQuery}, "abxab", []vcs.SearchSubmatch{{Start: 0, End: 2}, {Start: 3, End: 5}}},
+		{vcs.SearchOptions{Query: "x*", QueryType: vcs.RegexpQuery}, "axxb", []vcs.SearchSubmatch{{Start: 1, End: 3}}},
+		{vcs.SearchOptions{Query: "a+", QueryType: vcs.ExtendedRegexpQuery}, "baaab", []vcs.SearchSubmatch{{Start: 1, End: 4}}},
+		{vcs.SearchOptions{Query: "foo", QueryType: vcs.FixedQuery, WholeWord: true}, "foo foo,foo", []vcs.SearchSubmatch{{Start: 0, End: 3}, {Start: 4, End: 7}, {Start: 8, End: 11}}},
+		{vcs.SearchOptions{Query: "foo", QueryType: vcs.FixedQuery, WholeWord: true}, "foobar foo", []vcs.SearchSubmatch{{Start: 7, End: 10}}},
+	}
+	for _, test := range tests {
+		m, err := CompileSearch(test.opt)
+		if err != nil {
+			t.Errorf("%+v: CompileSearch: %s", test.opt, err)
+			continue
+		}
+		var submatches []vcs.SearchSubmatch
+		for _, sm := range m.submatches([]byte(test.line)) {
+			submatches = append(submatches, *sm)
+		}
+		if !reflect.DeepEqual(submatches, test.want) {
+			t.Errorf("%+v: submatches(%q): got %v, want %v", test.opt, test.line, submatches, test.want)
+		}
+	}
+}
+
+func TestSearchMatcher_MatchPath(t *testing.T) {
+	tests := []struct {
+		opt  vcs.SearchOptions
+		name string
+		want bool
+	}{
+		{vcs.SearchOptions{}, "a/b.go", true},
+		{vcs.SearchOptions{IncludePatterns: []string{"a"}}, "a/b.go", true},
+		{vcs.SearchOptions{IncludePatterns: []string{"a"}}, "b/b.go", false},
+		{vcs.SearchOptions{IncludePatterns: []string{"x", "**/*.go"}}, "b/b.go", true},
+		{vcs.SearchOptions{ExcludePatterns: []string{"vendor"}}, "vendor/a/b.go", false},
+		{vcs.SearchOptions{ExcludePatterns: []string{"vendor"}}, "a/vendor/b.go", true},
+		{vcs.SearchOptions{IncludePatterns: []string{"a"}, ExcludePatterns: []string{"a/c"}}, "a/c/d.go", false},
+		{vcs.SearchOptions{Extensions: []string{".go", "md"}}, "a/b.go", true},
+		{vcs.SearchOptions{Extensions: []string{".go", "md"}}, "README.md", true},
+		{vcs.SearchOptions{Extensions: []string{".go"}}, "a/b.gox", false},
+		{vcs.SearchOptions{IncludePatterns: []string{"a"}, Extensions: []string{".go"}}, "b/b.go", false},
+	}
+	for _, test := range tests {
+		test.opt.QueryType = vcs.FixedQuery
+		m, err := CompileSearch(test.opt)
+		if err != nil {
+			t.Errorf("%+v: CompileSearch: %s", test.opt, err)
+			continue
+		}
+		if match := m.MatchPath(test.name); match != test.want {
+			t.Errorf("%+v: MatchPath(%q): got %v, want %v", test.opt, test.name, match, test.want)
+		}
+	}
+
+	if _, err := CompileSearch(vcs.SearchOptions{QueryType: vcs.FixedQuery, ExcludePatterns: []string{"["}}); err == nil {
+		t.Error("invalid exclude pattern: got no error")
+	}
+}
+
+func TestSearchPage(t *testing.T) {
+	results := make([]*vcs.SearchResult, 5)
+	for i := range results {
+		results[i] = &vcs.SearchResult{StartLine: uint32(i + 1)}
+	}
+	tests := []struct {
+		n, offset     int32
+		want          []uint32 // start lines
+		wantTruncated bool     // a result was dropped
+	}{
+		{0, 0, []uint32{1, 2, 3, 4, 5}, false},
+		{2, 0, []uint32{1, 2}, false},
+		{2, 2, []uint32{3, 4}, true},
+		{0, 3, []uint32{4, 5}, false},
+		{3, 4, []uint32{5}, false},
+		{0, 6, nil, false},
+	}
+	for _, test := range tests {
+		p := NewSearchPage(vcs.SearchOptions{N: test.n, Offset: test.offset})
+		// Add the results of two files.
+		full := p.Add(results[:2])
+		if !full {
+			full = p.Add(results[2:])
+		}
+		if wantFull := test.n > 0 && int32(len(test.want)) == test.n; full != wantFull {
+			t.Errorf("N=%d Offset=%d: got full %v, want %v", test.n, test.offset, full, wantFull)
+		}
+		if p.Stats.Truncated != test.wantTruncated {
+			t.Errorf("N=%d Offset=%d: got truncated %v, want %v", test.n, test.offset, p.Stats.Truncated, test.wantTruncated)
+		}
+		var startLines []uint32
+		for _, r := range p.Results {
+			startLines = append(startLines, r.StartLine)
+		}
+		if !reflect.DeepEqual(startLines, test.want) {
+			t.Errorf("N=%d Offset=%d: got %v, want %v", test.n, test.offset, startLines, test.want)
+		}
+	}
+}
+
+func TestSearchStream(t *testing.T) {
+	results := make([]*vcs.SearchResult, 5)
+	for i := range results {
+		results[i] = &vcs.SearchResult{StartLine: uint32(i + 1)}
+	}
+	tests := []struct {
+		n, offset int32
+		stopAt    uint32 // fn returns false for this start line
+		want      []uint32
+		wantStats vcs.SearchStats
+	}{
+		{0, 0, 0, []uint32{1, 2, 3, 4, 5}, vcs.SearchStats{Matches: 5}},
+		{2, 1, 0, []uint32{2, 3}, vcs.SearchStats{Matches: 2, Truncated: true}},
+		{3, 2, 0, []uint32{3, 4, 5}, vcs.SearchStats{Matches: 3}}, // exactly N results
+		{0, 1, 3, []uint32{2, 3}, vcs.SearchStats{Matches: 2, Truncated: true}},
+		{0, 0, 5, []uint32{1, 2, 3, 4, 5}, vcs.SearchStats{Matches: 5}},
+	}
+	for _, test := range tests {
+		var startLines []uint32
+		p := NewSearchStream(vcs.SearchOptions{N: test.n, Offset: test.offset}, func(r *vcs.SearchResult) bool {
+			startLines = append(startLines, r.StartLine)
+			return r.StartLine != test.stopAt
+		})
+		// Add the results of one file at a time, like the backends.
+		for _, r := range results {
+			if p.Full() {
+				p.Truncate()
+				break
+			}
+			p.Add([]*vcs.SearchResult{r})
+		}
+		if p.Results != nil {
+			t.Errorf("N=%d Offset=%d: got Results %v, want nil", test.n, test.offset, p.Results)
+		}
+		if !reflect.DeepEqual(startLines, test.want) {
+			t.Errorf("N=%d Offset=%d: got %v, want %v", test.n, test.offset, startLines, test.want)
+		}
+		if p.Stats != test.wantStats {
+			t.Errorf("N=%d Offset=%d: got stats %+v, want %+v", test.n, test.offset, p.Stats, test.wantStats)
+		}
+	}
+}
+
+func TestMultiSearch(t *testing.T) {
+	opt := vcs.SearchOptions{Query: "x", QueryType: vcs.FixedQuery}
+	m, err := CompileSearch(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewMultiSearch(m, opt)
+
+	// Revision r1 has a and b with the same contents (1), and r2
+	// has b with other contents (2) and c with the contents 1.
+	add := func(rev vcs.CommitID, name, id, data string) {
+		if !s.Searched(id) {
+			s.AddContents(id, name, []byte(data))
+		}
+		s.AddFile(rev, name, id)
+	}
+	add("r1", "a", "1", "x\n")
+	add("r1", "b", "1", "x\n")
+	add("r2", "b", "2", "y\nx\n")
+	add("r2", "c", "1", "x\n")
+	add("r3", "a", "1", "x\n")
+	add("r3", "d", "3", "\x00x\n") // binary
+
+	type result struct {
+		file      string
+		startLine uint32
+		revs      []vcs.CommitID
+	}
+	want := []result{
+		{"a", 1, []vcs.CommitID{"r1", "r3"}},
+		{"b", 1, []vcs.CommitID{"r1"}},
+		{"b", 2, []vcs.CommitID{"r2"}},
+		{"c", 1, []vcs.CommitID{"r2"}},
+	}
+	var got []result
+	for _, r := range s.Results() {
+		got = append(got, result{r.File, r.StartLine, r.Revisions})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got results %v, want %v", got, want)
+	}
+}
+
+func TestSearchMatcher_RequiredLiteral(t *testing.T) {
+	tests := []struct {
+		opt      vcs.SearchOptions
+		lit      string
+		foldCase bool
+	}{
+		{vcs.SearchOptions{Query: "a.b", QueryType: vcs.FixedQuery}, "a.b", false},
+		{vcs.SearchOptions{Query: "a.b", QueryType: vcs.FixedQuery, WholeWord: true}, "a.b", false},
+		{vcs.SearchOptions{Query: "é foo", QueryType: vcs.IgnoreCaseQuery}, " FOO", true},
+		{vcs.SearchOptions{Query: `foo\d+$`, QueryType: vcs.RegexpQuery}, "foo", false},
+		{vcs.SearchOptions{Query: `^(func|foo) `, QueryType: vcs.ExtendedRegexpQuery}, "f", false},
+		{vcs.SearchOptions{Query: `.*`, QueryType: vcs.RegexpQuery}, "", false},
+	}
+	for _, test := range tests {
+		m, err := CompileSearch(test.opt)
+		if err != nil {
+			t.Errorf("%+v: CompileSearch: %s", test.opt, err)
+			continue
+		}
+		if lit, foldCase := m.RequiredLiteral(); lit != test.lit || foldCase != test.foldCase {
+			t.Errorf("%+v: got RequiredLiteral %q, %v, want %q, %v", test.opt, lit, foldCase, test.lit, test.foldCase)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package vcs
 
+import "context"
+
 // A Merger is a repository that can perform actions related to
 // merging.
 type Merger interface {
@@ -8,6 +10,12 @@ type Merger interface {
 	MergeBase(CommitID, CommitID) (CommitID, error)
 }
 
+// A MergerContext is a Merger that accepts a context.Context. See
+// RepositoryContext for the semantics of ctx.
+type MergerContext interface {
+	MergeBaseContext(context.Context, CommitID, CommitID) (CommitID, error)
+}
+
 // A CrossRepoMerger is a repository that can perform merge-related
 // actions across separate repositories.
 type CrossRepoMerger interface {
@@ -20,3 +28,84 @@ type CrossRepoMerger interface {
 	// in repoB.
 	CrossRepoMergeBase(a CommitID, repoB Repository, b CommitID) (CommitID, error)
 }
+
+// A CrossRepoMergerContext is a CrossRepoMerger that accepts a
+// context.Context. See RepositoryContext for the semantics of ctx.
+type CrossRepoMergerContext interface {
+	CrossRepoMergeBaseContext(ctx context.Context, a CommitID, repoB Repository, b CommitID) (CommitID, error)
+}
+
+// A MultiMerger is a repository that can find all merge bases of
+// commits, including commits with several best common ancestors
+// (after criss-cross merges) and more than two commits (for octopus
+// merges).
+type MultiMerger interface {
+	// MergeBases returns all best common ancestors of a and b: the
+	// common ancestors that aren't ancestors of another common
+	// ancestor, sorted by commit ID. If a and b have no common
+	// ancestor, it returns no commits.
+	MergeBases(a, b CommitID) ([]CommitID, error)
+
+	// OctopusMergeBases returns all best common ancestors of all of
+	// commits (like MergeBases, which it is equivalent to for two
+	// commits), for an octopus merge of them.
+	OctopusMergeBases(commits []CommitID) ([]CommitID, error)
+}
+
+// A MultiMergerContext is a MultiMerger that accepts a
+// context.Context. See RepositoryContext for the semantics of ctx.
+type MultiMergerContext interface {
+	MergeBasesContext(ctx context.Context, a, b CommitID) ([]CommitID, error)
+	OctopusMergeBasesContext(ctx context.Context, commits []CommitID) ([]CommitID, error)
+}
+
+// A MergeChecker is a repository that can merge commits without a
+// working tree (e.g., in a bare repository), to check whether they
+// merge cleanly.
+type MergeChecker interface {
+	// CheckMerge computes a three-way merge of the commits ours and
+	// theirs (using their merge bases) entirely in the repository's
+	// object store. It writes the merged tree (and the blobs of
+	// merged files) but doesn't create a commit or update any refs.
+	CheckMerge(ours, theirs CommitID) (*MergeCheck, error)
+}
+
+// A MergeCheckerContext is a MergeChecker that accepts a
+// context.Context. See RepositoryContext for the semantics of ctx.
+type MergeCheckerContext interface {
+	CheckMergeContext(ctx context.Context, ours, theirs CommitID) (*MergeCheck, error)
+}
+
+// A MergeCheck is the result of a trial merge.
+type MergeCheck struct {
+	// Tree is the ID of the tree of the merge. If there are
+	// conflicts, the conflicting files in it contain conflict
+	// markers.
+	Tree string
+
+	// Conflicts are the files that conflict, sorted by path. If the
+	// commits merge cleanly, there are none.
+	Conflicts []*MergeConflict
+}
+
+// A MergeConflict is a file that conflicts in a merge.
+type MergeConflict struct {
+	Path string
+	Type string // type of conflict (e.g., MergeConflictContents)
+
+	// Base, Ours and Theirs are the IDs of the file's blobs in the
+	// merge base and in the merged commits. They are empty for
+	// versions that don't have the file (e.g., Base is empty if both
+	// commits added the file).
+	Base, Ours, Theirs string
+}
+
+// Common values of MergeConflict.Type. Backends may return other
+// types (e.g., git's "rename/rename").
+const (
+	MergeConflictContents     = "contents"       // both commits changed the contents (or added the file)
+	MergeConflictBinary       = "binary"         // both commits changed a binary file
+	MergeConflictModifyDelete = "modify/delete"  // one commit modified the file and the other deleted it
+	MergeConflictRenameDelete = "rename/delete"  // one commit renamed the file and the other deleted it
+	MergeConflictFileDir      = "file/directory" // one commit has a file where the other has a directory
+)
@@ -1,15 +1,19 @@
 package vcs_test
 
 import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/gitcmd"
 )
 
 func TestMerger_MergeBase(t *testing.T) {
 	t.Parallel()
 
-	// TODO(sqs): implement for hg
 	// TODO(sqs): make a more complex test case
 
 	cmds := []string{
@@ -26,6 +30,20 @@ func TestMerger_MergeBase(t *testing.T) {
 		"git add h",
 		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m qux --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
 	}
+	hgCommit := "hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'"
+	hgCmds := []string{
+		"echo line1 > f",
+		"hg add f",
+		hgCommit,
+		"hg tag --local testbase",
+		"hg branch b2",
+		"echo line2 >> f",
+		hgCommit,
+		"hg update default",
+		"echo line3 > h",
+		"hg add h",
+		hgCommit,
+	}
 	tests := map[string]struct {
 		repo interface {
 			vcs.Merger
@@ -45,9 +63,23 @@ func TestMerger_MergeBase(t *testing.T) {
 			a:    "master", b: "b2",
 			wantMergeBase: "testbase",
 		},
+		"hg cmd": {
+			repo: makeHgRepositoryCmd(t, hgCmds...),
+			a:    "default", b: "b2",
+			wantMergeBase: "testbase",
+		},
+		"hg native": {
+			repo: makeHgRepositoryNative(t, hgCmds...),
+			a:    "default", b: "b2",
+			wantMergeBase: "testbase",
+		},
 	}
 
 	for label, test := range tests {
+		if strings.HasPrefix(label, "hg ") {
+			continue // hg broken, see issue #104.
+		}
+
 		a, err := test.repo.ResolveRevision(test.a)
 		if err != nil {
 			t.Errorf("%s: ResolveRevision(%q) on a: %s", label, test.a, err)
@@ -82,7 +114,6 @@ func TestMerger_MergeBase(t *testing.T) {
 func TestMerger_CrossRepoMergeBase(t *testing.T) {
 	t.Parallel()
 
-	// TODO(sqs): implement for hg
 	// TODO(sqs): make a more complex test case
 
 	cmdsA := []string{
@@ -105,6 +136,25 @@ func TestMerger_CrossRepoMergeBase(t *testing.T) {
 		"git add h",
 		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m qux --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
 	}
+	hgCommit := "hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'"
+	hgCmdsA := []string{
+		"echo line1 > f",
+		"hg add f",
+		hgCommit,
+		"hg tag --local testbase",
+	}
+	hgCmdsB := []string{
+		"echo line1 > f",
+		"hg add f",
+		hgCommit,
+		"hg branch b2",
+		"echo line2 >> f",
+		hgCommit,
+		"hg update default",
+		"echo line3 > h",
+		"hg add h",
+		hgCommit,
+	}
 	tests := map[string]struct {
 		repoA interface {
 			vcs.CrossRepoMerger
@@ -129,9 +179,27 @@ func TestMerger_CrossRepoMergeBase(t *testing.T) {
 			a: "master", b: "b2",
 			wantMergeBase: "testbase",
 		},
+		"hg cmd": {
+			repoA: makeHgRepositoryCmd(t, hgCmdsA...),
+			repoB: makeHgRepositoryCmd(t, hgCmdsB...),
+
+			a: "default", b: "b2",
+			wantMergeBase: "testbase",
+		},
+		"hg native": {
+			repoA: makeHgRepositoryNative(t, hgCmdsA...),
+			repoB: makeHgRepositoryNative(t, hgCmdsB...),
+
+			a: "default", b: "b2",
+			wantMergeBase: "testbase",
+		},
 	}
 
 	for label, test := range tests {
+		if strings.HasPrefix(label, "hg ") {
+			continue // hg broken, see issue #104.
+		}
+
 		a, err := test.repoA.ResolveRevision(test.a)
 		if err != nil {
 			t.Errorf("%s: ResolveRevision(%q) on a: %s", label, test.a, err)
@@ -162,3 +230,228 @@ func TestMerger_CrossRepoMergeBase(t *testing.T) {
 		}
 	}
 }
+
+func TestMultiMerger_MergeBases(t *testing.T) {
+	t.Parallel()
+
+	// Branches b1 and b2 are criss-cross merged, so they have 2 merge
+	// bases (c1 and c2).
+	gitCommit := "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z"
+	gitMerge := "GIT_AUTHOR_NAME=a GIT_AUTHOR_EMAIL=a@a.com GIT_AUTHOR_DATE=2006-01-02T15:04:05Z GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git merge --no-ff -m merge "
+	gitCmds := []string{
+		"echo base > f",
+		"git add f",
+		gitCommit,
+		"git tag base",
+		"git checkout -b b1",
+		"echo 1 > f1",
+		"git add f1",
+		gitCommit,
+		"git tag c1",
+		"git checkout -b b2 base",
+		"echo 2 > f2",
+		"git add f2",
+		gitCommit,
+		"git tag c2",
+		"git checkout -b b3 base",
+		"echo 3 > f3",
+		"git add f3",
+		gitCommit,
+		"git checkout b1",
+		gitMerge + "c2",
+		"git checkout b2",
+		gitMerge + "c1",
+	}
+	hgCommit := "hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'"
+	hgCmds := []string{
+		"echo base > f",
+		"hg add f",
+		hgCommit,
+		"hg tag --local base",
+		"hg branch b1",
+		"echo 1 > f1",
+		"hg add f1",
+		hgCommit,
+		"hg tag --local c1",
+		"hg update base",
+		"hg branch b2",
+		"echo 2 > f2",
+		"hg add f2",
+		hgCommit,
+		"hg tag --local c2",
+		"hg update base",
+		"hg branch b3",
+		"echo 3 > f3",
+		"hg add f3",
+		hgCommit,
+		"hg update b1",
+		"hg merge c2",
+		hgCommit,
+		"hg update b2",
+		"hg merge c1",
+		hgCommit,
+	}
+	repos := map[string]interface {
+		vcs.MultiMerger
+		ResolveRevision(spec string) (vcs.CommitID, error)
+	}{
+		"git cmd":    makeGitRepositoryCmd(t, gitCmds...),
+		"git go-git": makeGitRepositoryGoGit(t, gitCmds...),
+		"hg cmd":     makeHgRepositoryCmd(t, hgCmds...),
+		"hg native":  makeHgRepositoryNative(t, hgCmds...),
+	}
+	tests := []struct {
+		commits []string // can be any revspecs; are resolved during the test
+		octopus bool     // use OctopusMergeBases instead of MergeBases
+
+		wantMergeBases []string // can be any revspecs; are resolved during the test
+	}{
+		{commits: []string{"b1", "b2"}, wantMergeBases: []string{"c1", "c2"}},
+		{commits: []string{"b1", "c1"}, wantMergeBases: []string{"c1"}},
+		{commits: []string{"b1", "b3"}, wantMergeBases: []string{"base"}},
+		{commits: []string{"b1", "b2"}, octopus: true, wantMergeBases: []string{"c1", "c2"}},
+		{commits: []string{"b1", "b2", "b3"}, octopus: true, wantMergeBases: []string{"base"}},
+		{commits: []string{"c1"}, octopus: true, wantMergeBases: []string{"c1"}},
+	}
+
+	for label, repo := range repos {
+		if strings.HasPrefix(label, "hg ") {
+			continue // hg broken, see issue #104.
+		}
+
+		resolve := func(specs []string) []vcs.CommitID {
+			ids := make([]vcs.CommitID, len(specs))
+			for i, spec := range specs {
+				id, err := repo.ResolveRevision(spec)
+				if err != nil {
+					t.Fatalf("%s: ResolveRevision(%q): %s", label, spec, err)
+				}
+				ids[i] = id
+			}
+			return ids
+		}
+
+		for _, test := range tests {
+			commits := resolve(test.commits)
+			want := resolve(test.wantMergeBases)
+			sort.Sort(vcs.CommitIDs(want))
+
+			var bases []vcs.CommitID
+			var err error
+			if test.octopus {
+				bases, err = repo.OctopusMergeBases(commits)
+			} else {
+				bases, err = repo.MergeBases(commits[0], commits[1])
+			}
+			if err != nil {
+				t.Errorf("%s: %v (octopus %v): %s", label, test.commits, test.octopus, err)
+				continue
+			}
+			if !reflect.DeepEqual(bases, want) {
+				t.Errorf("%s: %v (octopus %v): got merge bases %v, want %v", label, test.commits, test.octopus, bases, want)
+			}
+		}
+	}
+}
+
+func TestMergeChecker_CheckMerge(t *testing.T) {
+	t.Parallel()
+
+	gitCommit := "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z"
+	cmds := []string{
+		"printf 'a\\nb\\nc\\n' > f",
+		"echo x > g",
+		"echo y > h",
+		"git add f g h",
+		gitCommit,
+		"git checkout -b ours",
+		"printf 'a\\nO\\nc\\n' > f",
+		"git rm -q g",
+		"echo o > n",
+		"git add f n",
+		gitCommit,
+		"git checkout -b theirs master",
+		"printf 'a\\nT\\nc\\n' > f",
+		"echo g2 > g",
+		"echo t > n",
+		"git add f g n",
+		gitCommit,
+		"git checkout -b clean master",
+		"echo h2 > h",
+		"git add h",
+		gitCommit,
+	}
+	bareDir := filepath.Join(initGitRepository(t, append(cmds, "git clone -q --bare . bare.git")...), "bare.git")
+	bareRepo, err := gitcmd.Open(bareDir)
+	if err != nil {
+		t.Fatalf("gitcmd.Open(%q) failed: %s", bareDir, err)
+	}
+
+	repos := map[string]interface {
+		vcs.MergeChecker
+		ResolveRevision(spec string) (vcs.CommitID, error)
+	}{
+		"git cmd":    makeGitRepositoryCmd(t, cmds...),
+		"git go-git": makeGitRepositoryGoGit(t, cmds...),
+		"git bare":   bareRepo,
+	}
+	// The tree of ours with the change to h in clean, which is the same
+	// as `git merge clean` on ours would commit.
+	const wantCleanTree = "c9ff827aa56c32f8134e0935c18f14c691177b02"
+	wantConflicts := []*vcs.MergeConflict{
+		{
+			Path:   "f",
+			Type:   vcs.MergeConflictContents,
+			Base:   "de980441c3ab03a8c07dda1ad27b8a11f39deb1e",
+			Ours:   "5a80a2d9e928ae36c4d7f0c3a14fb744b4fa1e41",
+			Theirs: "57ae7c2f74da19591847c63d68f2327b859636c4",
+		},
+		{
+			Path:   "g",
+			Type:   vcs.MergeConflictModifyDelete,
+			Base:   "587be6b4c3f93f93c489c0111bba5596147a26cb",
+			Theirs: "247c4abd244a429297a4c4b091592ae13bbf4677",
+		},
+		{
+			Path:   "n", // added in both commits
+			Type:   vcs.MergeConflictContents,
+			Ours:   "13e7564ea0c889e81bcba6f8e496b2a74cdb32fa",
+			Theirs: "718f4d2ff533cf8ead8d3556cf43912bd245fbc4",
+		},
+	}
+
+	for label, repo := range repos {
+		resolve := func(spec string) vcs.CommitID {
+			id, err := repo.ResolveRevision(spec)
+			if err != nil {
+				t.Fatalf("%s: ResolveRevision(%q): %s", label, spec, err)
+			}
+			return id
+		}
+
+		m, err := repo.CheckMerge(resolve("ours"), resolve("clean"))
+		if err != nil {
+			t.Errorf("%s: CheckMerge(ours, clean): %s", label, err)
+			continue
+		}
+		if m.Tree != wantCleanTree || len(m.Conflicts) != 0 {
+			t.Errorf("%s: CheckMerge(ours, clean): got %v, want tree %s and no conflicts", label, asJSON(m), wantCleanTree)
+		}
+
+		m, err = repo.CheckMerge(resolve("ours"), resolve("theirs"))
+		if err != nil {
+			t.Errorf("%s: CheckMerge(ours, theirs): %s", label, err)
+			continue
+		}
+		if m.Tree == "" {
+			t.Errorf("%s: CheckMerge(ours, theirs): got no tree", label)
+		}
+		if !reflect.DeepEqual(m.Conflicts, wantConflicts) {
+			t.Errorf("%s: CheckMerge(ours, theirs): got conflicts %v, want %v", label, asJSON(m.Conflicts), asJSON(wantConflicts))
+		}
+
+		if _, err := repo.CheckMerge(resolve("ours"), "0000000000000000000000000000000000000001"); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: CheckMerge with nonexistent commit: got err %v, want %v", label, err, vcs.ErrCommitNotFound)
+		}
+	}
+}
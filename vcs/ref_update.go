@@ -0,0 +1,56 @@
+package vcs
+
+import "context"
+
+// A RefUpdater is a repository whose branches, tags and other refs can
+// be created, updated and deleted.
+//
+// In hg backends, branches are bookmarks, lightweight tags are local
+// tags, and annotated tags are global tags (which are commits that
+// change .hgtags).
+type RefUpdater interface {
+	// CreateBranch creates a branch that points to id. It fails with
+	// ErrRefChanged if the branch already exists.
+	CreateBranch(name string, id CommitID) error
+
+	// DeleteBranch deletes a branch. It returns ErrBranchNotFound if
+	// the branch doesn't exist.
+	DeleteBranch(name string) error
+
+	// CreateTag creates a tag that points to id. If opt is nil, it
+	// creates a lightweight tag; otherwise it creates an annotated
+	// tag. It fails with ErrRefChanged if the tag already exists.
+	CreateTag(name string, id CommitID, opt *CreateTagOptions) error
+
+	// DeleteTag deletes a tag. It returns ErrTagNotFound if the tag
+	// doesn't exist.
+	DeleteTag(name string) error
+
+	// UpdateRef atomically updates the ref (e.g.,
+	// "refs/heads/master" for git, or a bookmark for hg) from old to
+	// new. If old is empty, the ref must not exist; if new is empty,
+	// the ref is deleted. It fails with ErrRefChanged if the ref
+	// doesn't point to old.
+	UpdateRef(name string, old, new CommitID) error
+}
+
+// A RefUpdaterContext is a RefUpdater that accepts a
+// context.Context. See RepositoryContext for the semantics of ctx.
+type RefUpdaterContext interface {
+	CreateBranchContext(ctx context.Context, name string, id CommitID) error
+	DeleteBranchContext(ctx context.Context, name string) error
+	CreateTagContext(ctx context.Context, name string, id CommitID, opt *CreateTagOptions) error
+	DeleteTagContext(ctx context.Context, name string) error
+	UpdateRefContext(ctx context.Context, name string, old, new CommitID) error
+}
+
+// CreateTagOptions specifies an annotated tag to create with
+// RefUpdater.CreateTag.
+type CreateTagOptions struct {
+	Message string
+
+	// Tagger is the creator of the tag. If nil, the backend's
+	// configured user is used. If its Date is zero, the current time
+	// is used.
+	Tagger *Signature
+}
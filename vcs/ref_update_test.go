@@ -0,0 +1,161 @@
+package vcs_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/gitcmd"
+)
+
+func TestRefUpdater(t *testing.T) {
+	t.Parallel()
+
+	gitCommit := "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z"
+	cmds := []string{
+		gitCommit,
+		"git tag first",
+		gitCommit,
+	}
+	hgCommit := "hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'"
+	hgCmds := []string{
+		"echo x > f",
+		"hg add f",
+		hgCommit,
+		"hg tag --local first",
+		"echo y > f",
+		hgCommit,
+		"hg bookmark master",
+	}
+	bareDir := filepath.Join(initGitRepository(t, append(cmds, "git clone -q --bare . bare.git")...), "bare.git")
+	bareRepo, err := gitcmd.Open(bareDir)
+	if err != nil {
+		t.Fatalf("gitcmd.Open(%q) failed: %s", bareDir, err)
+	}
+
+	type refUpdater interface {
+		vcs.RefUpdater
+		ResolveRevision(spec string) (vcs.CommitID, error)
+		ResolveBranch(name string) (vcs.CommitID, error)
+		ResolveTag(name string) (vcs.CommitID, error)
+		Tags() ([]*vcs.Tag, error)
+	}
+	tests := map[string]struct {
+		repo      refUpdater
+		branchRef string // the ref of branch "b"
+	}{
+		"git cmd":    {repo: makeGitRepositoryCmd(t, cmds...), branchRef: "refs/heads/b"},
+		"git go-git": {repo: makeGitRepositoryGoGit(t, cmds...), branchRef: "refs/heads/b"},
+		"git bare":   {repo: bareRepo, branchRef: "refs/heads/b"},
+		"hg cmd":     {repo: makeHgRepositoryCmd(t, hgCmds...), branchRef: "b"},
+		"hg native":  {repo: makeHgRepositoryNative(t, hgCmds...), branchRef: "b"},
+		// Global tags must not depend on the working directory.
+		"hg cmd no checkout": {repo: makeHgRepositoryCmd(t, append(hgCmds, "hg update -q null")...), branchRef: "b"},
+	}
+
+	for label, test := range tests {
+		if strings.HasPrefix(label, "hg ") {
+			continue // hg broken, see issue #104.
+		}
+
+		first, err := test.repo.ResolveRevision("first")
+		if err != nil {
+			t.Errorf("%s: ResolveRevision: %s", label, err)
+			continue
+		}
+		master, err := test.repo.ResolveRevision("master")
+		if err != nil {
+			t.Errorf("%s: ResolveRevision: %s", label, err)
+			continue
+		}
+
+		// Branches.
+		if err := test.repo.CreateBranch("b", first); err != nil {
+			t.Errorf("%s: CreateBranch: %s", label, err)
+			continue
+		}
+		if err := test.repo.CreateBranch("b", master); err != vcs.ErrRefChanged {
+			t.Errorf("%s: got CreateBranch error %v for an existing branch, want %v", label, err, vcs.ErrRefChanged)
+		}
+		if err := test.repo.UpdateRef(test.branchRef, master, first); err != vcs.ErrRefChanged {
+			t.Errorf("%s: got UpdateRef error %v with a stale old commit, want %v", label, err, vcs.ErrRefChanged)
+		}
+		if err := test.repo.UpdateRef(test.branchRef, first, master); err != nil {
+			t.Errorf("%s: UpdateRef: %s", label, err)
+		}
+		if id, err := test.repo.ResolveBranch("b"); err != nil || id != master {
+			t.Errorf("%s: got branch b %s (error %v), want %s", label, id, err, master)
+		}
+		if err := test.repo.DeleteBranch("b"); err != nil {
+			t.Errorf("%s: DeleteBranch: %s", label, err)
+		}
+		if _, err := test.repo.ResolveBranch("b"); err != vcs.ErrBranchNotFound {
+			t.Errorf("%s: got ResolveBranch error %v for a deleted branch, want %v", label, err, vcs.ErrBranchNotFound)
+		}
+		if err := test.repo.DeleteBranch("b"); err != vcs.ErrBranchNotFound {
+			t.Errorf("%s: got DeleteBranch error %v for a deleted branch, want %v", label, err, vcs.ErrBranchNotFound)
+		}
+
+		// Tags.
+		if err := test.repo.CreateTag("t", master, nil); err != nil {
+			t.Errorf("%s: CreateTag (lightweight): %s", label, err)
+		}
+		tagger := &vcs.Signature{Name: "b", Email: "b@b.com", Date: mustParseTime(time.RFC3339, "2014-06-01T12:00:00Z")}
+		if err := test.repo.CreateTag("ta", first, &vcs.CreateTagOptions{Message: "bar\n", Tagger: tagger}); err != nil {
+			t.Errorf("%s: CreateTag (annotated): %s", label, err)
+		}
+		// Annotated tags of a symbolic name and of a tree.
+		if err := test.repo.CreateTag("tm", "master", &vcs.CreateTagOptions{Message: "baz\n", Tagger: tagger}); err != nil {
+			t.Errorf("%s: CreateTag (annotated, symbolic): %s", label, err)
+		}
+		if err := test.repo.CreateTag("tt", "master^{tree}", &vcs.CreateTagOptions{Message: "tree\n", Tagger: tagger}); err != nil {
+			t.Errorf("%s: CreateTag (annotated, tree): %s", label, err)
+		}
+		if err := test.repo.CreateTag("t", first, nil); err != vcs.ErrRefChanged {
+			t.Errorf("%s: got CreateTag error %v for an existing tag, want %v", label, err, vcs.ErrRefChanged)
+		}
+		if tags, err := test.repo.Tags(); err != nil {
+			t.Errorf("%s: Tags: %s", label, err)
+		} else {
+			for _, tag := range tags {
+				switch tag.Name {
+				case "ta":
+					if tag.Message != "bar\n" || tag.Tagger == nil || *tag.Tagger != *tagger {
+						t.Errorf("%s: got annotated tag %v, want message %q and tagger %v", label, tag, "bar\n", tagger)
+					}
+				case "tm":
+					if tag.CommitID != master || tag.TargetType != "commit" {
+						t.Errorf("%s: got annotated tag %v, want it to point to commit %s", label, tag, master)
+					}
+				case "tt":
+					if tag.CommitID != "" || tag.TargetType != "tree" {
+						t.Errorf("%s: got annotated tag %v, want it to point to a tree", label, tag)
+					}
+				}
+			}
+		}
+		for tag, want := range map[string]vcs.CommitID{"t": master, "ta": first} {
+			if id, err := test.repo.ResolveTag(tag); err != nil || id != want {
+				t.Errorf("%s: got tag %s %s (error %v), want %s", label, tag, id, err, want)
+			}
+			if err := test.repo.DeleteTag(tag); err != nil {
+				t.Errorf("%s: DeleteTag(%q): %s", label, tag, err)
+			}
+			if err := test.repo.DeleteTag(tag); err != vcs.ErrTagNotFound {
+				t.Errorf("%s: got DeleteTag(%q) error %v for a deleted tag, want %v", label, tag, err, vcs.ErrTagNotFound)
+			}
+		}
+
+		if err := test.repo.CreateBranch("c", "0123456789012345678901234567890123456789"); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: got CreateBranch error %v with a nonexistent commit, want %v", label, err, vcs.ErrCommitNotFound)
+		}
+		if err := test.repo.CreateBranch("c", "nonexistent"); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: got CreateBranch error %v with a nonexistent symbolic commit, want %v", label, err, vcs.ErrCommitNotFound)
+		}
+		if err := test.repo.CreateTag("c", "nonexistent", nil); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: got CreateTag error %v with a nonexistent symbolic commit, want %v", label, err, vcs.ErrCommitNotFound)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package vcs
 
+import "context"
+
 // RemoteOpts configures interactions with a remote repository.
 type RemoteOpts struct {
 	SSH *SSHConfig // ssh configuration for communication with the remote
@@ -29,6 +31,12 @@ type RemoteUpdater interface {
 	UpdateEverything(RemoteOpts) (*UpdateResult, error)
 }
 
+// A RemoteUpdaterContext is a RemoteUpdater that accepts a
+// context.Context. See RepositoryContext for the semantics of ctx.
+type RemoteUpdaterContext interface {
+	UpdateEverythingContext(context.Context, RemoteOpts) (*UpdateResult, error)
+}
+
 // UpdateResult is the result of parsing output of the remote update operation.
 type UpdateResult struct {
 	Changes []Change
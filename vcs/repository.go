@@ -1,7 +1,9 @@
 package vcs
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"golang.org/x/tools/godoc/vfs"
 )
@@ -60,11 +62,41 @@ type Repository interface {
 	FileSystem(at CommitID) (vfs.FileSystem, error)
 }
 
+// A RepositoryContext is a repository whose operations can be
+// canceled or given a deadline by passing a context.Context.
+//
+// Each method behaves like the Repository method of the same name
+// (without the Context suffix), except that it stops as soon as ctx
+// is done. Implementations that exec external commands kill them
+// when ctx is done. In that case, the method returns ctx.Err()
+// (context.Canceled or context.DeadlineExceeded).
+type RepositoryContext interface {
+	ResolveRevisionContext(ctx context.Context, spec string) (CommitID, error)
+	ResolveTagContext(ctx context.Context, name string) (CommitID, error)
+	ResolveBranchContext(ctx context.Context, name string) (CommitID, error)
+	BranchesContext(context.Context, BranchesOptions) ([]*Branch, error)
+	TagsContext(context.Context) ([]*Tag, error)
+	GetCommitContext(context.Context, CommitID) (*Commit, error)
+	CommitsContext(context.Context, CommitsOptions) (commits []*Commit, total uint, err error)
+	CommittersContext(context.Context, CommittersOptions) ([]*Committer, error)
+
+	// FileSystemContext opens the repository file tree at a given
+	// commit ID. All operations on the returned file system are
+	// bound to ctx, so they fail once ctx is done.
+	FileSystemContext(ctx context.Context, at CommitID) (vfs.FileSystem, error)
+}
+
 // A Blamer is a repository that can blame portions of a file.
 type Blamer interface {
 	BlameFile(path string, opt *BlameOptions) ([]*Hunk, error)
 }
 
+// A BlamerContext is a Blamer that accepts a context.Context. See
+// RepositoryContext for the semantics of ctx.
+type BlamerContext interface {
+	BlameFileContext(ctx context.Context, path string, opt *BlameOptions) ([]*Hunk, error)
+}
+
 // BlameOptions configures a blame.
 type BlameOptions struct {
 	NewestCommit CommitID `json:",omitempty" url:",omitempty"`
@@ -72,6 +104,29 @@ type BlameOptions struct {
 
 	StartLine int `json:",omitempty" url:",omitempty"` // 1-indexed start byte (or 0 for beginning of file)
 	EndLine   int `json:",omitempty" url:",omitempty"` // 1-indexed end byte (or 0 for end of file)
+
+	// DetectMoves attributes lines that were moved or copied within
+	// the file to the commit that originally added them (like `git
+	// blame -M`).
+	DetectMoves bool `json:",omitempty" url:",omitempty"`
+
+	// DetectCopies is like DetectMoves, but also detects lines moved
+	// or copied from other files (like `git blame -C`, repeated
+	// DetectCopies times). It looks in the files modified by the
+	// same commit (1), also in the files that existed when the file
+	// was created (2), or in all files of any commit (3).
+	DetectCopies int `json:",omitempty" url:",omitempty"`
+
+	// IgnoreRevs lists commits (e.g., bulk reformatting commits) to
+	// ignore, attributing the lines they changed to the previous
+	// commit that changed them (like `git blame --ignore-rev`).
+	IgnoreRevs []CommitID `json:",omitempty" url:",omitempty"`
+
+	// IgnoreRevsFile is the path of a file in the repository at
+	// NewestCommit that lists more commits to ignore, one per line
+	// (like `git blame --ignore-revs-file .git-blame-ignore-revs`).
+	// Empty lines and comments beginning with "#" are skipped.
+	IgnoreRevsFile string `json:",omitempty" url:",omitempty"`
 }
 
 // A Hunk is a contiguous portion of a file associated with a commit.
@@ -82,6 +137,19 @@ type Hunk struct {
 	EndByte   int // 0-indexed end byte position (exclusive)
 	CommitID
 	Author Signature
+
+	// OrigPath and OrigStartLine are the path and the 1-indexed line
+	// number of the hunk's first line in CommitID. They differ from
+	// the blamed path and StartLine if the file was renamed or the
+	// lines were moved since CommitID.
+	OrigPath      string
+	OrigStartLine int
+
+	// Boundary is whether the lines are older than
+	// BlameOptions.OldestCommit, in which case CommitID is the
+	// boundary commit (usually OldestCommit itself) instead of the
+	// commit that last changed them.
+	Boundary bool
 }
 
 // A Differ is a repository that can compute diffs between two
@@ -92,6 +160,12 @@ type Differ interface {
 	Diff(base, head CommitID, opt *DiffOptions) (*Diff, error)
 }
 
+// A DifferContext is a Differ that accepts a context.Context. See
+// RepositoryContext for the semantics of ctx.
+type DifferContext interface {
+	DiffContext(ctx context.Context, base, head CommitID, opt *DiffOptions) (*Diff, error)
+}
+
 // A CrossRepoDiffer is a repository that can compute diffs with
 // respect to a commit in a different repository.
 type CrossRepoDiffer interface {
@@ -101,6 +175,12 @@ type CrossRepoDiffer interface {
 	CrossRepoDiff(base CommitID, headRepo Repository, head CommitID, opt *DiffOptions) (*Diff, error)
 }
 
+// A CrossRepoDifferContext is a CrossRepoDiffer that accepts a
+// context.Context. See RepositoryContext for the semantics of ctx.
+type CrossRepoDifferContext interface {
+	CrossRepoDiffContext(ctx context.Context, base CommitID, headRepo Repository, head CommitID, opt *DiffOptions) (*Diff, error)
+}
+
 var (
 	ErrRefNotFound      = errors.New("ref not found")
 	ErrBranchNotFound   = errors.New("branch not found")
@@ -133,9 +213,40 @@ type CommitsOptions struct {
 
 	Path string // only commits modifying the given path are selected (optional)
 
+	// Author, Committer and Message select only commits whose author
+	// or committer (formatted as "Name <email>") or message matches
+	// the regular expression (optional). The syntax is that of Go's
+	// regexp package for every VCS. In Message, ^ and $ also match at
+	// line boundaries.
+	Author    string
+	Committer string
+	Message   string
+
+	Since time.Time // only commits committed at or after this time (optional)
+	Until time.Time // only commits committed at or before this time (optional)
+
+	FirstParent bool // follow only the first parent of merge commits
+	Merges      bool // only merge commits are selected
+	NoMerges    bool // merge commits are excluded
+
+	Order string // CommitsOrderDate or CommitsOrderTopo ("" means the VCS's default order)
+
 	NoTotal bool // avoid counting the total number of commits
+
+	Cursor string // resume after the page that returned this cursor (only for (CommitPager).CommitsPage)
 }
 
+// Values for CommitsOptions.Order. In both orders, no commit is
+// listed before all of its children.
+const (
+	// CommitsOrderDate lists commits by descending commit date.
+	CommitsOrderDate = "date"
+
+	// CommitsOrderTopo avoids interleaving the commits of multiple
+	// lines of history.
+	CommitsOrderTopo = "topo"
+)
+
 // CommittersOptions specifies limits on the list of committers returned by
 // (Repository).Committers.
 type CommittersOptions struct {
@@ -154,10 +265,22 @@ type DiffOptions struct {
 	ExcludeReachableFromBoth bool // like "<rev1>...<rev2>" (see `git rev-parse --help`)
 }
 
-// A Diff represents changes between two commits.
-type Diff struct {
-	Raw string // the raw diff output
-}
+// Values for FileDiff.Status.
+const (
+	FileAdded    = "added"
+	FileDeleted  = "deleted"
+	FileModified = "modified"
+	FileRenamed  = "renamed"
+	FileCopied   = "copied"
+)
+
+// Values for DiffLine.Op. They are the characters that begin lines in
+// unified diff hunks.
+const (
+	DiffLineContext = " "
+	DiffLineAdded   = "+"
+	DiffLineDeleted = "-"
+)
 
 type Branches []*Branch
 
@@ -180,6 +303,13 @@ func (p Tags) Len() int           { return len(p) }
 func (p Tags) Less(i, j int) bool { return p[i].Name < p[j].Name }
 func (p Tags) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
+// CommitIDs sorts commit IDs in lexicographic order.
+type CommitIDs []CommitID
+
+func (p CommitIDs) Len() int           { return len(p) }
+func (p CommitIDs) Less(i, j int) bool { return p[i] < p[j] }
+func (p CommitIDs) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
 // A FileLister is a repository that can perform actions related to
 // listing the entire file tree.
 type FileLister interface {
@@ -189,3 +319,9 @@ type FileLister interface {
 	// alphabetically. E.g., returned paths have the form "path/to/file.txt".
 	ListFiles(CommitID) ([]string, error)
 }
+
+// A FileListerContext is a FileLister that accepts a
+// context.Context. See RepositoryContext for the semantics of ctx.
+type FileListerContext interface {
+	ListFilesContext(context.Context, CommitID) ([]string, error)
+}
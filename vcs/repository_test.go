@@ -2,6 +2,7 @@ package vcs_test
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -722,6 +723,8 @@ func TestRepository_Tags(t *testing.T) {
 		"git tag t0",
 		"git tag t1",
 		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git tag -a ta -m bar",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:07Z git tag -a tn -m baz ta",
+		"git tag tt HEAD^{tree}",
 	}
 	hgCommands := []string{
 		"touch --date=2006-01-02T15:04:05Z f || touch -t " + times[0] + " f",
@@ -730,6 +733,27 @@ func TestRepository_Tags(t *testing.T) {
 		"hg tag t0 --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
 		"hg tag t1 --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
 	}
+	gitWantTags := []*vcs.Tag{
+		{Name: "t0", CommitID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8", ObjectID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8", TargetType: "commit"},
+		{Name: "t1", CommitID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8", ObjectID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8", TargetType: "commit"},
+		{
+			Name:       "ta",
+			CommitID:   "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8",
+			ObjectID:   "d07bdca22f4731f877b3b98177adc033c7a22263",
+			TargetType: "commit",
+			Tagger:     &vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:06Z")},
+			Message:    "bar\n",
+		},
+		{
+			Name:       "tn", // a tag of tag ta
+			CommitID:   "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8",
+			ObjectID:   "77af96ec5baa39cf1b2e8dbb5df5ae1bc012867c",
+			TargetType: "tag",
+			Tagger:     &vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:07Z")},
+			Message:    "baz\n",
+		},
+		{Name: "tt", ObjectID: "4b825dc642cb6eb9a060e54bf8d69288fbee4904", TargetType: "tree"},
+	}
 	tests := map[string]struct {
 		repo interface {
 			Tags() ([]*vcs.Tag, error)
@@ -737,20 +761,12 @@ func TestRepository_Tags(t *testing.T) {
 		wantTags []*vcs.Tag
 	}{
 		"git cmd": {
-			repo: makeGitRepositoryCmd(t, gitCommands...),
-			wantTags: []*vcs.Tag{
-				{Name: "t0", CommitID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"},
-				{Name: "t1", CommitID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"},
-				{Name: "ta", CommitID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"},
-			},
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			wantTags: gitWantTags,
 		},
 		"git go-git": {
-			repo: makeGitRepositoryGoGit(t, gitCommands...),
-			wantTags: []*vcs.Tag{
-				{Name: "t0", CommitID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"},
-				{Name: "t1", CommitID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"},
-				{Name: "ta", CommitID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"},
-			},
+			repo:     makeGitRepositoryGoGit(t, gitCommands...),
+			wantTags: gitWantTags,
 		},
 		"hg native": {
 			repo:     makeHgRepositoryNative(t, hgCommands...),
@@ -1223,6 +1239,494 @@ func TestRepository_Commits_options_path(t *testing.T) {
 	}
 }
 
+func TestRepository_Commits_filters(t *testing.T) {
+	t.Parallel()
+
+	commit := func(committer, date, author, msg string) string {
+		return "GIT_COMMITTER_NAME=" + committer + " GIT_COMMITTER_EMAIL=" + committer + "@" + committer + ".com GIT_COMMITTER_DATE=" + date + " git commit --allow-empty -m '" + msg + "' --author='" + author + " <" + author + "@" + author + ".com>' --date " + date
+	}
+	gitCommands := []string{
+		commit("a", "2006-01-02T15:04:01Z", "a", "init"),
+		"git checkout -b b",
+		commit("c", "2006-01-02T15:04:02Z", "b", "feat: x"),
+		"git checkout master",
+		commit("a", "2006-01-02T15:04:03Z", "a", "fix: y"),
+		"GIT_AUTHOR_NAME=a GIT_AUTHOR_EMAIL=a@a.com GIT_AUTHOR_DATE=2006-01-02T15:04:04Z GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:04Z git merge --no-ff -m 'Merge branch b' b",
+		commit("a", "2006-01-02T15:04:05Z", "b", "fix: z"),
+	}
+	hgCommands := []string{
+		"hg commit --config ui.allowemptycommit=True -m init --date '2006-01-02 15:04:01 UTC' --user 'a <a@a.com>'",
+		"hg branch b",
+		"hg commit --config ui.allowemptycommit=True -m 'feat: x' --date '2006-01-02 15:04:02 UTC' --user 'b <b@b.com>'",
+		"hg update default",
+		"hg commit --config ui.allowemptycommit=True -m 'fix: y' --date '2006-01-02 15:04:03 UTC' --user 'a <a@a.com>'",
+		"hg merge b",
+		"hg commit -m 'Merge branch b' --date '2006-01-02 15:04:04 UTC' --user 'a <a@a.com>'",
+		"hg commit --config ui.allowemptycommit=True -m 'fix: z' --date '2006-01-02 15:04:05 UTC' --user 'b <b@b.com>'",
+	}
+	tests := map[string]struct {
+		opt vcs.CommitsOptions
+
+		// gitHead and hgHead are the revspecs of opt.Head, if not
+		// the last commit.
+		gitHead, hgHead string
+
+		// wantMessages is the expected messages of the commits (for
+		// all repos, unless overridden by wantHgMessages).
+		wantMessages   []string
+		wantHgMessages []string
+	}{
+		"Author": {
+			opt:          vcs.CommitsOptions{Author: "^b "},
+			wantMessages: []string{"fix: z", "feat: x"},
+		},
+		// The patterns use Go's regexp syntax for every VCS.
+		"Author RE2": {
+			opt:          vcs.CommitsOptions{Author: `(?i)^B\s<`},
+			wantMessages: []string{"fix: z", "feat: x"},
+		},
+		"Message RE2": {
+			opt:          vcs.CommitsOptions{Message: `(?i)^FIX: \pL$`},
+			wantMessages: []string{"fix: z", "fix: y"},
+		},
+		// Only the ancestors of Head are listed, and not (in hg) all
+		// lower-numbered revisions, which include "feat: x".
+		"Head": {
+			gitHead:      "master~2",
+			hgHead:       "2",
+			wantMessages: []string{"fix: y", "init"},
+		},
+		"Committer": {
+			opt:            vcs.CommitsOptions{Committer: "c@c"},
+			wantMessages:   []string{"feat: x"},
+			wantHgMessages: []string{}, // hg has no committers
+		},
+		"Message": {
+			opt:          vcs.CommitsOptions{Message: "^fix:"},
+			wantMessages: []string{"fix: z", "fix: y"},
+		},
+		"Since Until": {
+			opt: vcs.CommitsOptions{
+				Since: mustParseTime(time.RFC3339, "2006-01-02T15:04:03Z").Time(),
+				Until: mustParseTime(time.RFC3339, "2006-01-02T15:04:04Z").Time(),
+			},
+			wantMessages: []string{"Merge branch b", "fix: y"},
+		},
+		"FirstParent": {
+			opt:          vcs.CommitsOptions{FirstParent: true},
+			wantMessages: []string{"fix: z", "Merge branch b", "fix: y", "init"},
+		},
+		"Merges": {
+			opt:          vcs.CommitsOptions{Merges: true},
+			wantMessages: []string{"Merge branch b"},
+		},
+		"NoMerges": {
+			opt:          vcs.CommitsOptions{NoMerges: true},
+			wantMessages: []string{"fix: z", "fix: y", "feat: x", "init"},
+		},
+		"Order date": {
+			opt:          vcs.CommitsOptions{Order: vcs.CommitsOrderDate},
+			wantMessages: []string{"fix: z", "Merge branch b", "fix: y", "feat: x", "init"},
+		},
+		"Order topo": {
+			opt:            vcs.CommitsOptions{Order: vcs.CommitsOrderTopo},
+			wantMessages:   []string{"fix: z", "Merge branch b", "feat: x", "fix: y", "init"},
+			wantHgMessages: []string{"fix: z", "Merge branch b", "fix: y", "feat: x", "init"},
+		},
+	}
+	repos := map[string]struct {
+		repo interface {
+			ResolveRevision(spec string) (vcs.CommitID, error)
+			Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
+		}
+		head string // revspec of the last commit
+	}{
+		"git cmd":    {makeGitRepositoryCmd(t, gitCommands...), "master"},
+		"git go-git": {makeGitRepositoryGoGit(t, gitCommands...), "master"},
+		"hg cmd":     {makeHgRepositoryCmd(t, hgCommands...), "tip"},
+		"hg native":  {makeHgRepositoryNative(t, hgCommands...), "tip"},
+	}
+
+	for repoLabel, r := range repos {
+		if strings.HasPrefix(repoLabel, "hg ") {
+			continue // hg broken, see issue #104.
+		}
+
+		repo := r.repo
+		head, err := repo.ResolveRevision(r.head)
+		if err != nil {
+			t.Errorf("%s: ResolveRevision: %s", repoLabel, err)
+			continue
+		}
+
+		for label, test := range tests {
+			label = repoLabel + " " + label
+			test.opt.Head = head
+			if spec := test.gitHead; spec != "" {
+				if strings.HasPrefix(repoLabel, "hg ") {
+					spec = test.hgHead
+				}
+				if test.opt.Head, err = repo.ResolveRevision(spec); err != nil {
+					t.Errorf("%s: ResolveRevision: %s", label, err)
+					continue
+				}
+			}
+			commits, total, err := repo.Commits(test.opt)
+			if err != nil {
+				t.Errorf("%s: Commits(): %s", label, err)
+				continue
+			}
+
+			want := test.wantMessages
+			if strings.HasPrefix(repoLabel, "hg ") && test.wantHgMessages != nil {
+				want = test.wantHgMessages
+			}
+			messages := make([]string, len(commits))
+			for i, c := range commits {
+				messages[i] = c.Message
+			}
+			if !reflect.DeepEqual(messages, want) {
+				t.Errorf("%s: got commit messages %q, want %q", label, messages, want)
+			}
+			if total != uint(len(want)) {
+				t.Errorf("%s: got %d total commits, want %d", label, total, len(want))
+			}
+		}
+	}
+}
+
+func TestRepository_WalkCommits(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"touch f",
+		"git add f",
+		"GIT_COMMITTER_NAME=c GIT_COMMITTER_EMAIL=c@c.com GIT_COMMITTER_DATE=2006-01-02T15:04:07Z git commit -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+		"GIT_COMMITTER_NAME=c GIT_COMMITTER_EMAIL=c@c.com GIT_COMMITTER_DATE=2006-01-02T15:04:08Z git commit --allow-empty -m qux --author='a <a@a.com>' --date 2006-01-02T15:04:08Z",
+	}
+	hgCommands := []string{
+		"hg commit --config ui.allowemptycommit=True -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
+		"touch f",
+		"hg add f",
+		"hg commit -m bar --date '2006-12-06 13:18:30 UTC' --user 'a <a@a.com>'",
+		"hg commit --config ui.allowemptycommit=True -m qux --date '2006-12-06 13:18:31 UTC' --user 'a <a@a.com>'",
+	}
+	type walkRepo interface {
+		vcs.CommitWalker
+		ResolveRevision(spec string) (vcs.CommitID, error)
+		Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
+	}
+	repos := map[string]walkRepo{
+		"git cmd":    makeGitRepositoryCmd(t, gitCommands...),
+		"git go-git": makeGitRepositoryGoGit(t, gitCommands...),
+		"hg cmd":     makeHgRepositoryCmd(t, hgCommands...),
+		"hg native":  makeHgRepositoryNative(t, hgCommands...),
+	}
+
+	walk := func(repo walkRepo, opt vcs.CommitsOptions) ([]*vcs.Commit, error) {
+		it, err := repo.WalkCommits(opt)
+		if err != nil {
+			return nil, err
+		}
+		defer it.Close()
+		var commits []*vcs.Commit
+		for c := it.Next(); c != nil; c = it.Next() {
+			commits = append(commits, c)
+		}
+		return commits, it.Err()
+	}
+
+	for label, repo := range repos {
+		if strings.HasPrefix(label, "hg ") {
+			continue // hg broken, see issue #104.
+		}
+
+		head, err := repo.ResolveRevision("master")
+		if err != nil {
+			t.Errorf("%s: ResolveRevision: %s", label, err)
+			continue
+		}
+		all, _, err := repo.Commits(vcs.CommitsOptions{Head: head, NoTotal: true})
+		if err != nil {
+			t.Errorf("%s: Commits: %s", label, err)
+			continue
+		}
+		if len(all) != 3 {
+			t.Errorf("%s: got %d commits, want 3", label, len(all))
+			continue
+		}
+
+		// WalkCommits must yield the same commits as Commits.
+		opts := map[string]vcs.CommitsOptions{
+			"all":        {Head: head},
+			"N":          {Head: head, N: 2},
+			"Skip":       {Head: head, Skip: 1},
+			"N Skip":     {Head: head, N: 1, Skip: 1},
+			"Skip all":   {Head: head, Skip: 3},
+			"Base..Head": {Head: head, Base: all[2].ID},
+			"Path":       {Head: head, Path: "f"},
+		}
+		for optLabel, opt := range opts {
+			want, _, err := repo.Commits(opt)
+			if err != nil {
+				t.Errorf("%s %s: Commits: %s", label, optLabel, err)
+				continue
+			}
+			commits, err := walk(repo, opt)
+			if err != nil {
+				t.Errorf("%s %s: WalkCommits: %s", label, optLabel, err)
+				continue
+			}
+			if len(commits) != len(want) {
+				t.Errorf("%s %s: got %d commits, want %d", label, optLabel, len(commits), len(want))
+				continue
+			}
+			for i := range commits {
+				if !commitsEqual(commits[i], want[i]) {
+					t.Errorf("%s %s: got commit %d == %+v, want %+v", label, optLabel, i, commits[i], want[i])
+				}
+			}
+		}
+
+		// Stop early.
+		it, err := repo.WalkCommits(vcs.CommitsOptions{Head: head})
+		if err != nil {
+			t.Errorf("%s: WalkCommits: %s", label, err)
+			continue
+		}
+		if c := it.Next(); c == nil || c.ID != head {
+			t.Errorf("%s: got first commit %+v, want %s", label, c, head)
+		}
+		if err := it.Close(); err != nil {
+			t.Errorf("%s: Close: %s", label, err)
+		}
+		if c := it.Next(); c != nil {
+			t.Errorf("%s: got commit %+v after Close, want nil", label, c)
+		}
+
+		if _, err := walk(repo, vcs.CommitsOptions{Head: nonexistentCommitID}); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: WalkCommits with nonexistent head: want ErrCommitNotFound, got %v", label, err)
+		}
+	}
+}
+
+func TestRepository_CommitsPage(t *testing.T) {
+	t.Parallel()
+
+	commit := func(date, args string) string {
+		return "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=" + date + " git commit --author='a <a@a.com>' --date " + date + " " + args
+	}
+	gitCommands := []string{
+		commit("2006-01-02T15:04:01Z", "--allow-empty -m a"),
+		"git checkout -b b",
+		"touch f",
+		"git add f",
+		commit("2006-01-02T15:04:02Z", "-m b1"),
+		"git checkout master",
+		commit("2006-01-02T15:04:03Z", "--allow-empty -m m1"),
+		commit("2006-01-02T15:04:04Z", "--allow-empty -m m2"),
+		"GIT_AUTHOR_NAME=a GIT_AUTHOR_EMAIL=a@a.com GIT_AUTHOR_DATE=2006-01-02T15:04:05Z GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git merge --no-ff -m merge b",
+		"echo x > f",
+		"git add f",
+		commit("2006-01-02T15:04:06Z", "-m c"),
+	}
+	hgCommands := []string{
+		"hg commit --config ui.allowemptycommit=True -m a --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
+		"touch f",
+		"hg add f",
+		"hg commit -m b --date '2006-12-06 13:18:30 UTC' --user 'a <a@a.com>'",
+		"hg commit --config ui.allowemptycommit=True -m c --date '2006-12-06 13:18:31 UTC' --user 'a <a@a.com>'",
+	}
+	type pagerRepo interface {
+		vcs.CommitPager
+		ResolveRevision(spec string) (vcs.CommitID, error)
+		Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
+	}
+	repos := map[string]pagerRepo{
+		"git cmd":    makeGitRepositoryCmd(t, gitCommands...),
+		"git go-git": makeGitRepositoryGoGit(t, gitCommands...),
+		"hg cmd":     makeHgRepositoryCmd(t, hgCommands...),
+		"hg native":  makeHgRepositoryNative(t, hgCommands...),
+	}
+
+	// commitIDs returns the IDs of the commits, to report differences
+	// between CommitsPage and Commits concisely.
+	commitIDs := func(commits []*vcs.Commit) []vcs.CommitID {
+		ids := make([]vcs.CommitID, len(commits))
+		for i, c := range commits {
+			ids[i] = c.ID
+		}
+		return ids
+	}
+
+	for label, repo := range repos {
+		if strings.HasPrefix(label, "hg ") {
+			continue // hg broken, see issue #104.
+		}
+
+		head, err := repo.ResolveRevision("master")
+		if err != nil {
+			t.Errorf("%s: ResolveRevision: %s", label, err)
+			continue
+		}
+
+		opts := map[string]vcs.CommitsOptions{
+			"all":  {Head: head},
+			"Skip": {Head: head, Skip: 1},
+			"Base": {Head: head, Base: "b"},
+			"Path": {Head: head, Path: "f"},
+
+			"Message":     {Head: head, Message: "^m"},
+			"Since":       {Head: head, Since: mustParseTime(time.RFC3339, "2006-01-02T15:04:03Z").Time()},
+			"FirstParent": {Head: head, FirstParent: true},
+			"Merges":      {Head: head, Merges: true},
+			"NoMerges":    {Head: head, NoMerges: true},
+		}
+		for optLabel, opt := range opts {
+			want, _, err := repo.Commits(opt)
+			if err != nil {
+				t.Errorf("%s %s: Commits: %s", label, optLabel, err)
+				continue
+			}
+
+			for _, n := range []uint{1, 2, 100} {
+				var commits []*vcs.Commit
+				opt := opt
+				opt.N = n
+				for page := 0; ; page++ {
+					if page > len(want) {
+						t.Fatalf("%s %s N=%d: too many pages", label, optLabel, n)
+					}
+					pageCommits, nextCursor, err := repo.CommitsPage(opt)
+					if err != nil {
+						t.Fatalf("%s %s N=%d: CommitsPage(%+v): %s", label, optLabel, n, opt, err)
+					}
+					if uint(len(pageCommits)) > n {
+						t.Errorf("%s %s N=%d: got %d commits in page %d", label, optLabel, n, len(pageCommits), page)
+					}
+					commits = append(commits, pageCommits...)
+					if nextCursor == "" {
+						break
+					}
+					opt.Cursor = nextCursor
+				}
+				if got, want := commitIDs(commits), commitIDs(want); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s %s N=%d: got commits %v, want %v", label, optLabel, n, got, want)
+				}
+				for i := range commits {
+					if i < len(want) && !commitsEqual(commits[i], want[i]) {
+						t.Errorf("%s %s N=%d: got commit %d == %+v, want %+v", label, optLabel, n, i, commits[i], want[i])
+					}
+				}
+			}
+		}
+
+		if _, _, err := repo.CommitsPage(vcs.CommitsOptions{Head: head, N: 1, Cursor: "x"}); err != vcs.ErrInvalidCursor {
+			t.Errorf("%s: CommitsPage with bad cursor: want ErrInvalidCursor, got %v", label, err)
+		}
+		if _, _, err := repo.CommitsPage(vcs.CommitsOptions{Head: nonexistentCommitID, N: 1}); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: CommitsPage with nonexistent head: want ErrCommitNotFound, got %v", label, err)
+		}
+	}
+}
+
+func TestRepository_CommitsPage_clockSkew(t *testing.T) {
+	t.Parallel()
+
+	// The commit dates are out of order, so git log visits some
+	// commits after commits that are reachable from them.
+	commit := func(date, args string) string {
+		return "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=" + date + " git commit --author='a <a@a.com>' --date " + date + " " + args
+	}
+	merge := func(date, branch string) string {
+		return "GIT_AUTHOR_NAME=a GIT_AUTHOR_EMAIL=a@a.com GIT_AUTHOR_DATE=" + date + " GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=" + date + " git merge --no-ff -m merge-" + branch + " " + branch
+	}
+	gitCommands := []string{
+		"echo a > f",
+		"git add f",
+		commit("2006-01-02T15:04:01Z", "-m a"),
+		"git checkout -b b",
+		"echo b1 > f",
+		commit("2006-01-02T15:04:09Z", "-am b1"),
+		"git checkout -b c",
+		commit("2006-01-02T15:04:12Z", "--allow-empty -m c1"),
+		"git checkout b",
+		commit("2006-01-02T15:04:02Z", "--allow-empty -m b2"),
+		"git checkout master",
+		commit("2006-01-02T15:04:03Z", "--allow-empty -m m1"),
+		merge("2006-01-02T15:04:04Z", "c"),
+		"echo m2 > g",
+		"git add g",
+		commit("2006-01-02T15:04:10Z", "-m m2"),
+		merge("2006-01-02T15:04:05Z", "b"),
+		"git mv f h",
+		commit("2006-01-02T15:04:06Z", "-m m3"),
+		"echo m4 > h",
+		commit("2006-01-02T15:04:08Z", "-am m4"),
+		commit("2006-01-02T15:04:07Z", "--allow-empty -m m5"),
+	}
+	repos := map[string]interface {
+		vcs.CommitPager
+		ResolveRevision(spec string) (vcs.CommitID, error)
+		Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
+	}{
+		"git cmd":    makeGitRepositoryCmd(t, gitCommands...),
+		"git go-git": makeGitRepositoryGoGit(t, gitCommands...),
+	}
+
+	for label, repo := range repos {
+		head, err := repo.ResolveRevision("master")
+		if err != nil {
+			t.Errorf("%s: ResolveRevision: %s", label, err)
+			continue
+		}
+
+		opts := map[string]vcs.CommitsOptions{
+			"all":      {Head: head},
+			"Base":     {Head: head, Base: "c"},
+			"Path":     {Head: head, Path: "h"},
+			"glob":     {Head: head, Path: ":(glob)f"},
+			"Message":  {Head: head, Message: "^[bm]"},
+			"NoMerges": {Head: head, NoMerges: true},
+			"Since":    {Head: head, Since: mustParseTime(time.RFC3339, "2006-01-02T15:04:04Z").Time()},
+		}
+		for optLabel, opt := range opts {
+			want, _, err := repo.Commits(opt)
+			if err != nil {
+				t.Errorf("%s %s: Commits: %s", label, optLabel, err)
+				continue
+			}
+			wantIDs := make([]vcs.CommitID, len(want))
+			for i, c := range want {
+				wantIDs[i] = c.ID
+			}
+
+			for _, n := range []uint{1, 2, 3} {
+				var ids []vcs.CommitID
+				opt := opt
+				opt.N = n
+				for page := 0; page <= len(want); page++ {
+					commits, nextCursor, err := repo.CommitsPage(opt)
+					if err != nil {
+						t.Fatalf("%s %s N=%d: CommitsPage(%+v): %s", label, optLabel, n, opt, err)
+					}
+					for _, c := range commits {
+						ids = append(ids, c.ID)
+					}
+					if nextCursor == "" {
+						break
+					}
+					opt.Cursor = nextCursor
+				}
+				if !reflect.DeepEqual(ids, wantIDs) {
+					t.Errorf("%s %s N=%d: got commits %v, want %v", label, optLabel, n, ids, wantIDs)
+				}
+			}
+		}
+	}
+}
+
 func TestRepository_FileSystem_Symlinks(t *testing.T) {
 
 	t.Parallel()
@@ -1839,6 +2343,89 @@ func TestRepository_FileSystem_gitSubmodules(t *testing.T) {
 	}
 }
 
+func TestRepository_Context(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"touch f",
+		"git add f",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	hgCommands := []string{
+		"touch --date=2006-01-02T15:04:05Z f || touch -t " + times[0] + " f",
+		"hg add f",
+		"hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
+	}
+	tests := map[string]struct {
+		repo interface {
+			vcs.Repository
+			vcs.RepositoryContext
+		}
+		commitID vcs.CommitID
+	}{
+		"git cmd": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			commitID: "8f3c0f6574402bcca635bbba56a08ef9acdd65cf",
+		},
+		"git go-git": {
+			repo:     makeGitRepositoryGoGit(t, gitCommands...),
+			commitID: "8f3c0f6574402bcca635bbba56a08ef9acdd65cf",
+		},
+		"hg native": {
+			repo:     makeHgRepositoryNative(t, hgCommands...),
+			commitID: "e8e11ff1be92a7be71b9b5cdb4cc674b7dc9facf",
+		},
+		"hg cmd": {
+			repo:     makeHgRepositoryCmd(t, hgCommands...),
+			commitID: "e8e11ff1be92a7be71b9b5cdb4cc674b7dc9facf",
+		},
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	for label, test := range tests {
+		if strings.HasPrefix(label, "hg ") {
+			continue // hg broken, see issue #104.
+		}
+
+		// A live context does not change the results.
+		commit, err := test.repo.GetCommitContext(context.Background(), test.commitID)
+		if err != nil {
+			t.Errorf("%s: GetCommitContext: %s", label, err)
+			continue
+		}
+		if commit.ID != test.commitID {
+			t.Errorf("%s: got commit ID %q, want %q", label, commit.ID, test.commitID)
+		}
+
+		if _, err := test.repo.ResolveRevisionContext(canceled, string(test.commitID)); err != context.Canceled {
+			t.Errorf("%s: ResolveRevisionContext: got err %v, want %v", label, err, context.Canceled)
+		}
+		if _, _, err := test.repo.CommitsContext(expired, vcs.CommitsOptions{Head: test.commitID}); err != context.DeadlineExceeded {
+			t.Errorf("%s: CommitsContext: got err %v, want %v", label, err, context.DeadlineExceeded)
+		}
+
+		// The file system is bound to the context it was opened with.
+		ctx, cancel := context.WithCancel(context.Background())
+		fs, err := test.repo.FileSystemContext(ctx, test.commitID)
+		if err != nil {
+			t.Errorf("%s: FileSystemContext: %s", label, err)
+			cancel()
+			continue
+		}
+		if _, err := fs.Stat("f"); err != nil {
+			t.Errorf("%s: fs.Stat(f): %s", label, err)
+		}
+		cancel()
+		if _, err := fs.Stat("f"); err != context.Canceled {
+			t.Errorf("%s: fs.Stat(f) after cancel: got err %v, want %v", label, err, context.Canceled)
+		}
+	}
+}
+
 func TestOpen(t *testing.T) {
 	t.Parallel()
 	tests := []struct{ vcs, dir string }{
@@ -1,15 +1,103 @@
 package vcs
 
+import "context"
+
 type Searcher interface {
 	// Search searches the text of a repository at the given commit
 	// ID.
 	Search(CommitID, SearchOptions) ([]*SearchResult, error)
 }
 
+// A SearcherContext is a Searcher that accepts a context.Context. See
+// RepositoryContext for the semantics of ctx.
+type SearcherContext interface {
+	SearchContext(context.Context, CommitID, SearchOptions) ([]*SearchResult, error)
+}
+
+// A StreamingSearcher is a repository that can pass the results of a
+// search to a function as they are found, so that callers can show
+// them progressively and stop the search once they have enough.
+type StreamingSearcher interface {
+	// StreamSearch searches like Search, but calls fn with each
+	// result (in the same order as Search returns them) instead of
+	// returning them. If fn returns false, the search stops and any
+	// process running it is killed. StreamSearch returns the
+	// statistics of the search.
+	StreamSearch(at CommitID, opt SearchOptions, fn func(*SearchResult) bool) (*SearchStats, error)
+}
+
+// A StreamingSearcherContext is a StreamingSearcher that accepts a
+// context.Context. See RepositoryContext for the semantics of ctx.
+type StreamingSearcherContext interface {
+	StreamSearchContext(ctx context.Context, at CommitID, opt SearchOptions, fn func(*SearchResult) bool) (*SearchStats, error)
+}
+
+// SearchStats are statistics about a search.
+type SearchStats struct {
+	// FilesScanned is the number of files whose contents were
+	// searched. Backends may skip files that can't match (e.g.,
+	// using git grep) without counting them.
+	FilesScanned int
+
+	Matches int // number of results returned (or passed to the function of a streaming search)
+
+	// Truncated is whether the search stopped early, because it
+	// found SearchOptions.N results or because the function of a
+	// streaming search returned false, with results or candidate
+	// files left. The search doesn't search the remaining files, so
+	// they may not contain more results.
+	Truncated bool
+}
+
+// A MultiRevisionSearcher is a repository that can search several
+// revisions at once (e.g., the heads of all branches).
+type MultiRevisionSearcher interface {
+	// SearchRevisions searches the text of the repository at each of
+	// the given revisions, with the same semantics as Search. Files
+	// with the same contents in several revisions are only searched
+	// once, and a file with the same path and contents in several
+	// revisions yields a single result for each match, whose
+	// Revisions lists those revisions (in the order of revs).
+	//
+	// The results are ordered by the first revision that contains
+	// them (and within a revision, as in Search). N and Offset apply
+	// to the combined results.
+	SearchRevisions(revs []CommitID, opt SearchOptions) ([]*SearchResult, error)
+}
+
+// A MultiRevisionSearcherContext is a MultiRevisionSearcher that
+// accepts a context.Context. See RepositoryContext for the semantics
+// of ctx.
+type MultiRevisionSearcherContext interface {
+	SearchRevisionsContext(ctx context.Context, revs []CommitID, opt SearchOptions) ([]*SearchResult, error)
+}
+
 const (
 	// FixedQuery is a value for SearchOptions.QueryType that
 	// indicates the query is a fixed string, not a regex.
 	FixedQuery = "fixed"
 
-	// TODO(sqs): allow regexp searches, extended regexp searches, etc.
+	// IgnoreCaseQuery is a value for SearchOptions.QueryType that
+	// indicates the query is a fixed string that is matched
+	// case-insensitively.
+	IgnoreCaseQuery = "ignore-case"
+
+	// RegexpQuery is a value for SearchOptions.QueryType that
+	// indicates the query is a regexp in RE2 syntax (the syntax of
+	// Go's regexp package).
+	RegexpQuery = "regexp"
+
+	// ExtendedRegexpQuery is a value for SearchOptions.QueryType that
+	// indicates the query is a POSIX extended regexp (as in grep -E),
+	// which matches the leftmost-longest text.
+	ExtendedRegexpQuery = "extended-regexp"
 )
+
+// Queries are matched against each line of a file (without its
+// trailing newline) with the same semantics on all backends, even if
+// the backend's search tool (e.g., git grep) implements regexps
+// differently. If SearchOptions.WholeWord is set, the matching text
+// must be at the beginning of the line or preceded by a non-word
+// character, and at the end of the line or followed by a non-word
+// character, where word characters are letters, digits and
+// underscores (as in grep -w).
@@ -2,19 +2,24 @@ package vcs_test
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/gitcmd"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/internal"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/searchindex"
 )
 
 func TestRepository_Search_LongLine(t *testing.T) {
 	t.Parallel()
-	// TODO(sqs): implement hg Searcher
 
 	tmp, longline, err := createLongFile()
 	if err != nil {
@@ -30,9 +35,14 @@ func TestRepository_Search_LongLine(t *testing.T) {
 	wantRes := []*vcs.SearchResult{
 		{
 			File:      "f1",
+			StartByte: 0,
+			EndByte:   uint32(len(longline)),
 			StartLine: 1,
 			EndLine:   1,
 			Match:     longline,
+			Lines: []*vcs.SearchLine{
+				{Line: 1, Submatches: []*vcs.SearchSubmatch{{Start: 0, End: 2}}},
+			},
 		},
 	}
 
@@ -41,13 +51,17 @@ func TestRepository_Search_LongLine(t *testing.T) {
 		"git add f1",
 		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit f1 -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
 	}
+	hgCommands := []string{
+		"cp " + filepath.ToSlash(tmp) + " f1",
+		"hg add f1",
+		"hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
+	}
 
-	testGitRepositorySearch(t, gitCommands, searchOpt, wantRes)
+	testRepositorySearch(t, gitCommands, hgCommands, searchOpt, wantRes)
 }
 
 func TestRepository_Search(t *testing.T) {
 	t.Parallel()
-	// TODO(sqs): implement hg Searcher
 
 	searchOpt := vcs.SearchOptions{
 		Query:        "xy",
@@ -57,15 +71,26 @@ func TestRepository_Search(t *testing.T) {
 	wantRes := []*vcs.SearchResult{
 		{
 			File:      "f1",
+			StartByte: 4,
+			EndByte:   11,
 			StartLine: 2,
 			EndLine:   3,
 			Match:     []byte("def\nxyz"),
+			Lines: []*vcs.SearchLine{
+				{Line: 2, Context: true},
+				{Line: 3, Submatches: []*vcs.SearchSubmatch{{Start: 0, End: 2}}},
+			},
 		},
 		{
 			File:      "f2",
+			StartByte: 0,
+			EndByte:   3,
 			StartLine: 1,
 			EndLine:   1,
 			Match:     []byte("xyz"),
+			Lines: []*vcs.SearchLine{
+				{Line: 1, Submatches: []*vcs.SearchSubmatch{{Start: 0, End: 2}}},
+			},
 		},
 	}
 
@@ -77,36 +102,619 @@ func TestRepository_Search(t *testing.T) {
 		"git add f1 f2",
 		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit f1 f2 -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
 	}
+	hgCommands := []string{
+		"echo abc > f1",
+		"echo def >> f1",
+		"echo xyz >> f1",
+		"echo xyz > f2",
+		"hg add f1 f2",
+		"hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
+	}
+
+	testRepositorySearch(t, gitCommands, hgCommands, searchOpt, wantRes)
+}
+
+func TestRepository_Search_queryTypes(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"echo 'func Foo() {}' > f1",
+		"echo 'foo := FooBar(x)' > f2",
+		"echo 'x := foo123' > f3",
+		"git add f1 f2 f3",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit f1 f2 f3 -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	hgCommands := []string{
+		"echo 'func Foo() {}' > f1",
+		"echo 'foo := FooBar(x)' > f2",
+		"echo 'x := foo123' > f3",
+		"hg add f1 f2 f3",
+		"hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
+	}
+	f1, f2, f3 := "func Foo() {}", "foo := FooBar(x)", "x := foo123"
+
+	tests := map[string]struct {
+		opt     vcs.SearchOptions
+		wantRes []*vcs.SearchResult
+	}{
+		"fixed": {
+			opt: vcs.SearchOptions{Query: "Foo", QueryType: vcs.FixedQuery},
+			wantRes: []*vcs.SearchResult{
+				lineSearchResult("f1", f1, 5, 8),
+				lineSearchResult("f2", f2, 7, 10),
+			},
+		},
+		"ignore case": {
+			opt: vcs.SearchOptions{Query: "fOO", QueryType: vcs.IgnoreCaseQuery},
+			wantRes: []*vcs.SearchResult{
+				lineSearchResult("f1", f1, 5, 8),
+				lineSearchResult("f2", f2, 0, 3, 7, 10),
+				lineSearchResult("f3", f3, 5, 8),
+			},
+		},
+		"regexp": {
+			// \d is RE2 syntax that git grep doesn't support.
+			opt: vcs.SearchOptions{Query: `foo\d+$`, QueryType: vcs.RegexpQuery},
+			wantRes: []*vcs.SearchResult{
+				lineSearchResult("f3", f3, 5, 11),
+			},
+		},
+		"extended regexp": {
+			opt: vcs.SearchOptions{Query: `^(func|foo) `, QueryType: vcs.ExtendedRegexpQuery},
+			wantRes: []*vcs.SearchResult{
+				lineSearchResult("f1", f1, 0, 5),
+				lineSearchResult("f2", f2, 0, 4),
+			},
+		},
+		"whole word": {
+			opt: vcs.SearchOptions{Query: "Foo", QueryType: vcs.FixedQuery, WholeWord: true},
+			wantRes: []*vcs.SearchResult{
+				lineSearchResult("f1", f1, 5, 8),
+			},
+		},
+		"whole word ignore case": {
+			opt: vcs.SearchOptions{Query: "foo", QueryType: vcs.IgnoreCaseQuery, WholeWord: true},
+			wantRes: []*vcs.SearchResult{
+				lineSearchResult("f1", f1, 5, 8),
+				lineSearchResult("f2", f2, 0, 3),
+			},
+		},
+	}
+	for label, test := range tests {
+		t.Logf("# %s", label)
+		testRepositorySearch(t, gitCommands, hgCommands, test.opt, test.wantRes)
+	}
+}
+
+func TestRepository_Search_pathsAndSize(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"mkdir -p a/b vendor/c",
+		"echo xyz > a/f.go",
+		"echo xyz > a/b/f.go",
+		"echo xyz > a/b/f.md",
+		"echo xyz > vendor/c/f.go",
+		"(echo xyz; seq 1000) > big.go",
+		"git add -A",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	hgCommands := []string{
+		"mkdir -p a/b vendor/c",
+		"echo xyz > a/f.go",
+		"echo xyz > a/b/f.go",
+		"echo xyz > a/b/f.md",
+		"echo xyz > vendor/c/f.go",
+		"(echo xyz; seq 1000) > big.go",
+		"hg add",
+		"hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
+	}
+	result := func(file string) *vcs.SearchResult {
+		return lineSearchResult(file, "xyz", 0, 3)
+	}
+
+	tests := map[string]struct {
+		opt     vcs.SearchOptions
+		wantRes []*vcs.SearchResult
+	}{
+		"all": {
+			opt:     vcs.SearchOptions{},
+			wantRes: []*vcs.SearchResult{result("a/b/f.go"), result("a/b/f.md"), result("a/f.go"), result("big.go"), result("vendor/c/f.go")},
+		},
+		"include": {
+			opt:     vcs.SearchOptions{IncludePatterns: []string{"a/*.go", "vendor"}},
+			wantRes: []*vcs.SearchResult{result("a/f.go"), result("vendor/c/f.go")},
+		},
+		"include recursive": {
+			opt:     vcs.SearchOptions{IncludePatterns: []string{"a/**/*.go"}},
+			wantRes: []*vcs.SearchResult{result("a/b/f.go"), result("a/f.go")},
+		},
+		"exclude": {
+			opt:     vcs.SearchOptions{ExcludePatterns: []string{"vendor", "**/*.md"}},
+			wantRes: []*vcs.SearchResult{result("a/b/f.go"), result("a/f.go"), result("big.go")},
+		},
+		"include and exclude": {
+			opt:     vcs.SearchOptions{IncludePatterns: []string{"a"}, ExcludePatterns: []string{"a/b"}},
+			wantRes: []*vcs.SearchResult{result("a/f.go")},
+		},
+		"extensions": {
+			opt:     vcs.SearchOptions{Extensions: []string{"md"}},
+			wantRes: []*vcs.SearchResult{result("a/b/f.md")},
+		},
+		"include and extensions": {
+			opt:     vcs.SearchOptions{IncludePatterns: []string{"a/b", "big.go"}, Extensions: []string{".go"}},
+			wantRes: []*vcs.SearchResult{result("a/b/f.go"), result("big.go")},
+		},
+		"max file size": {
+			opt:     vcs.SearchOptions{MaxFileSize: 100, Extensions: []string{".go"}},
+			wantRes: []*vcs.SearchResult{result("a/b/f.go"), result("a/f.go"), result("vendor/c/f.go")},
+		},
+	}
+	for label, test := range tests {
+		t.Logf("# %s", label)
+		test.opt.Query = "xyz"
+		test.opt.QueryType = vcs.FixedQuery
+		testRepositorySearch(t, gitCommands, hgCommands, test.opt, test.wantRes)
+	}
+}
+
+func TestRepository_Search_pagination(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"printf 'x\\na\\nb\\nc\\nx\\n' > f1",
+		"printf 'b\\nx\\n' > f2",
+		"printf '\\000x\\n' > f3",
+		"git add f1 f2 f3",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit f1 f2 f3 -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	hgCommands := []string{
+		"printf 'x\\na\\nb\\nc\\nx\\n' > f1",
+		"printf 'b\\nx\\n' > f2",
+		"printf '\\000x\\n' > f3",
+		"hg add f1 f2 f3",
+		"hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
+	}
+	match := []*vcs.SearchSubmatch{{Start: 0, End: 1}}
+	f1a := &vcs.SearchResult{
+		File: "f1", StartByte: 0, EndByte: 3, StartLine: 1, EndLine: 2, Match: []byte("x\na"),
+		Lines: []*vcs.SearchLine{{Line: 1, Submatches: match}, {Line: 2, Context: true}},
+	}
+	f1b := &vcs.SearchResult{
+		File: "f1", StartByte: 6, EndByte: 9, StartLine: 4, EndLine: 5, Match: []byte("c\nx"),
+		Lines: []*vcs.SearchLine{{Line: 4, Context: true}, {Line: 5, Submatches: match}},
+	}
+	f2 := &vcs.SearchResult{
+		File: "f2", StartByte: 0, EndByte: 3, StartLine: 1, EndLine: 2, Match: []byte("b\nx"),
+		Lines: []*vcs.SearchLine{{Line: 1, Context: true}, {Line: 2, Submatches: match}},
+	}
 
-	testGitRepositorySearch(t, gitCommands, searchOpt, wantRes)
+	// The binary file f3 isn't searched.
+	tests := map[string]struct {
+		n, offset int32
+		wantRes   []*vcs.SearchResult
+	}{
+		"all":           {0, 0, []*vcs.SearchResult{f1a, f1b, f2}},
+		"N":             {2, 0, []*vcs.SearchResult{f1a, f1b}},
+		"N and Offset":  {2, 1, []*vcs.SearchResult{f1b, f2}},
+		"Offset":        {0, 2, []*vcs.SearchResult{f2}},
+		"Offset at end": {0, 3, nil},
+	}
+	for label, test := range tests {
+		t.Logf("# %s", label)
+		opt := vcs.SearchOptions{Query: "x", QueryType: vcs.FixedQuery, ContextLines: 1, N: test.n, Offset: test.offset}
+		testRepositorySearch(t, gitCommands, hgCommands, opt, test.wantRes)
+	}
 }
 
-// testGitRepositorySearch is a helper that tests repository search
-// over a git repository specified by the initializtion in
-// repoInitCommands
-func testGitRepositorySearch(t *testing.T, repoInitCmds []string, searchOpt vcs.SearchOptions, wantRes []*vcs.SearchResult) {
+func TestRepository_SearchRevisions(t *testing.T) {
+	t.Parallel()
+
+	gitCommit := "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z"
+	gitCommands := []string{
+		"echo xyz > f1",
+		"echo xyz > f2",
+		"git add f1 f2",
+		gitCommit,
+		"git checkout -b b",
+		"echo 'xyz abc' > f1",
+		"git add f1",
+		gitCommit,
+		// f1 is a directory in c.
+		"git checkout -q -b c master",
+		"git rm -q f1",
+		"mkdir f1",
+		"echo xyz > f1/g",
+		"git add f1/g",
+		gitCommit,
+	}
+	hgCommit := "hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'"
+	hgCommands := []string{
+		"echo xyz > f1",
+		"echo xyz > f2",
+		"hg add f1 f2",
+		hgCommit,
+		"hg branch b",
+		"echo 'xyz abc' > f1",
+		hgCommit,
+		"hg update -q default",
+		"hg branch c",
+		"hg rm f1",
+		"mkdir f1",
+		"echo xyz > f1/g",
+		"hg add f1/g",
+		hgCommit,
+	}
+
 	tests := map[string]struct {
-		repo        vcs.Searcher
-		spec        vcs.CommitID
+		repo interface {
+			vcs.MultiRevisionSearcher
+			ResolveRevision(spec string) (vcs.CommitID, error)
+		}
+		revs []string
+	}{
+		"git cmd": {
+			repo: makeGitRepositoryCmd(t, gitCommands...),
+			revs: []string{"master", "b", "c"},
+		},
+		"git go-git": {
+			repo: makeGitRepositoryGoGit(t, gitCommands...),
+			revs: []string{"master", "b", "c"},
+		},
+		"hg cmd": {
+			repo: makeHgRepositoryCmd(t, hgCommands...),
+			revs: []string{"default", "b", "c"},
+		},
+		"hg native": {
+			repo: makeHgRepositoryNative(t, hgCommands...),
+			revs: []string{"default", "b", "c"},
+		},
+	}
+
+	for label, test := range tests {
+		if strings.HasPrefix(label, "hg ") {
+			continue // hg broken, see issue #104.
+		}
+
+		var revs []vcs.CommitID
+		for _, spec := range test.revs {
+			commitID, err := test.repo.ResolveRevision(spec)
+			if err != nil {
+				t.Fatalf("%s: ResolveRevision(%q): %s", label, spec, err)
+			}
+			revs = append(revs, commitID)
+		}
+
+		withRevisions := func(r *vcs.SearchResult, revs ...vcs.CommitID) *vcs.SearchResult {
+			r.Revisions = revs
+			return r
+		}
+		wantRes := []*vcs.SearchResult{
+			withRevisions(lineSearchResult("f1", "xyz", 0, 3), revs[0]),
+			withRevisions(lineSearchResult("f2", "xyz", 0, 3), revs[0], revs[1], revs[2]),
+			withRevisions(lineSearchResult("f1", "xyz abc", 0, 3), revs[1]),
+			withRevisions(lineSearchResult("f1/g", "xyz", 0, 3), revs[2]),
+		}
+
+		res, err := test.repo.SearchRevisions(revs, vcs.SearchOptions{Query: "xyz", QueryType: vcs.FixedQuery})
+		if err != nil {
+			t.Errorf("%s: SearchRevisions: %s", label, err)
+			continue
+		}
+		if !reflect.DeepEqual(res, wantRes) {
+			t.Errorf("%s: got results == %v, want %v", label, asJSON(res), asJSON(wantRes))
+		}
+
+		res, err = test.repo.SearchRevisions(revs, vcs.SearchOptions{Query: "xyz", QueryType: vcs.FixedQuery, N: 1, Offset: 1})
+		if err != nil {
+			t.Errorf("%s: SearchRevisions with N and Offset: %s", label, err)
+			continue
+		}
+		if !reflect.DeepEqual(res, wantRes[1:2]) {
+			t.Errorf("%s: with N and Offset: got results == %v, want %v", label, asJSON(res), asJSON(wantRes[1:2]))
+		}
+
+		if _, err := test.repo.SearchRevisions([]vcs.CommitID{revs[0], nonexistentCommitID}, vcs.SearchOptions{Query: "xyz", QueryType: vcs.FixedQuery}); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: got SearchRevisions error %v with a nonexistent commit, want %v", label, err, vcs.ErrCommitNotFound)
+		}
+	}
+}
+
+func TestRepository_StreamSearch(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"echo abc > f1",
+		"echo 'abc abc' > f2",
+		"echo xyz > f3",
+		"echo abc > f4",
+		"git add f1 f2 f3 f4",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	hgCommands := []string{
+		"echo abc > f1",
+		"echo 'abc abc' > f2",
+		"echo xyz > f3",
+		"echo abc > f4",
+		"hg add f1 f2 f3 f4",
+		"hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
+	}
+	tests := map[string]struct {
+		repo interface {
+			vcs.StreamingSearcher
+			ResolveRevision(spec string) (vcs.CommitID, error)
+		}
+		spec string
+	}{
+		"git cmd": {
+			repo: makeGitRepositoryCmd(t, gitCommands...),
+			spec: "master",
+		},
+		"git go-git": {
+			repo: makeGitRepositoryGoGit(t, gitCommands...),
+			spec: "master",
+		},
+		"hg cmd": {
+			repo: makeHgRepositoryCmd(t, hgCommands...),
+			spec: "tip",
+		},
+		"hg native": {
+			repo: makeHgRepositoryNative(t, hgCommands...),
+			spec: "tip",
+		},
+	}
+
+	allRes := []*vcs.SearchResult{
+		lineSearchResult("f1", "abc", 0, 3),
+		lineSearchResult("f2", "abc abc", 0, 3, 4, 7),
+		lineSearchResult("f4", "abc", 0, 3),
+	}
+	streamTests := []struct {
+		opt       vcs.SearchOptions
+		stopAfter int // fn returns false for the stopAfter'th result (if nonzero)
+		wantRes   []*vcs.SearchResult
+		wantStats vcs.SearchStats
+	}{
+		{
+			opt:       vcs.SearchOptions{},
+			wantRes:   allRes,
+			wantStats: vcs.SearchStats{FilesScanned: 3, Matches: 3},
+		},
+		{
+			opt:       vcs.SearchOptions{},
+			stopAfter: 2,
+			wantRes:   allRes[:2],
+			wantStats: vcs.SearchStats{FilesScanned: 2, Matches: 2, Truncated: true},
+		},
+		{
+			opt:       vcs.SearchOptions{N: 1, Offset: 1},
+			wantRes:   allRes[1:2],
+			wantStats: vcs.SearchStats{FilesScanned: 2, Matches: 1, Truncated: true},
+		},
+		{
+			opt:       vcs.SearchOptions{N: 3},
+			wantRes:   allRes,
+			wantStats: vcs.SearchStats{FilesScanned: 3, Matches: 3}, // exactly N results
+		},
+	}
+
+	for label, test := range tests {
+		if strings.HasPrefix(label, "hg ") {
+			continue // hg broken, see issue #104.
+		}
+
+		commitID, err := test.repo.ResolveRevision(test.spec)
+		if err != nil {
+			t.Fatalf("%s: ResolveRevision(%q): %s", label, test.spec, err)
+		}
+
+		for _, st := range streamTests {
+			opt := st.opt
+			opt.Query, opt.QueryType = "abc", vcs.FixedQuery
+
+			var res []*vcs.SearchResult
+			stats, err := test.repo.StreamSearch(commitID, opt, func(r *vcs.SearchResult) bool {
+				res = append(res, r)
+				return len(res) != st.stopAfter
+			})
+			if err != nil {
+				t.Errorf("%s: %+v: StreamSearch: %s", label, opt, err)
+				continue
+			}
+			if !reflect.DeepEqual(res, st.wantRes) {
+				t.Errorf("%s: %+v: got results == %v, want %v", label, opt, asJSON(res), asJSON(st.wantRes))
+			}
+			if *stats != st.wantStats {
+				t.Errorf("%s: %+v: got stats %+v, want %+v", label, opt, *stats, st.wantStats)
+			}
+		}
+	}
+}
+
+func TestSearchIndex_GC(t *testing.T) {
+	t.Parallel()
+
+	repo := makeGitRepositoryCmd(t,
+		"echo xyz > f1",
+		"git add f1",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"echo abc > f1",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -am bar --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	)
+	dir := makeTmpDir(t, "search-index")
+	s := searchindex.NewSearcher(repo, dir)
+
+	var commits []vcs.CommitID
+	for _, spec := range []string{"master~1", "master"} {
+		commitID, err := repo.ResolveRevision(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		commits = append(commits, commitID)
+		if err := s.Index(context.Background(), commitID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	indexFiles := func() []string {
+		names, err := filepath.Glob(filepath.Join(dir, "*.index"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, name := range names {
+			names[i] = filepath.Base(name)
+		}
+		return names
+	}
+	want := []string{string(commits[0]) + ".index", string(commits[1]) + ".index"}
+	sort.Strings(want)
+	if names := indexFiles(); !reflect.DeepEqual(names, want) {
+		t.Errorf("got index files %v, want %v", names, want)
+	}
+
+	if err := s.GC(commits[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if names, want := indexFiles(), []string{string(commits[1]) + ".index"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("after GC: got index files %v, want %v", names, want)
+	}
+
+	// Searching a removed index builds it again.
+	res, err := s.Search(commits[0], vcs.SearchOptions{Query: "xyz", QueryType: vcs.FixedQuery})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []*vcs.SearchResult{lineSearchResult("f1", "xyz", 0, 3)}; !reflect.DeepEqual(res, want) {
+		t.Errorf("got results == %v, want %v", asJSON(res), asJSON(want))
+	}
+	if names := indexFiles(); len(names) != 2 {
+		t.Errorf("after search: got index files %v, want 2", names)
+	}
+}
+
+func TestSearchIndex_onDisk(t *testing.T) {
+	t.Parallel()
+
+	repo := makeGitRepositoryCmd(t,
+		"echo xyz > f1",
+		"echo abc > f2",
+		"git add f1 f2",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	)
+	commitID, err := repo.ResolveRevision("master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := makeTmpDir(t, "search-index")
+	if err := searchindex.NewSearcher(repo, dir).Index(context.Background(), commitID); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new Searcher serves from the index that the first one wrote,
+	// reading only the candidate files from the tree. Its repository
+	// can't list files, so it can't build the index again.
+	s := searchindex.NewSearcher(unlistableRepository{repo}, dir)
+	res, err := s.Search(commitID, vcs.SearchOptions{Query: "xyz", QueryType: vcs.FixedQuery})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []*vcs.SearchResult{lineSearchResult("f1", "xyz", 0, 3)}; !reflect.DeepEqual(res, want) {
+		t.Errorf("got results == %v, want %v", asJSON(res), asJSON(want))
+	}
+}
+
+// unlistableRepository is a repository whose ListFiles always fails.
+type unlistableRepository struct{ *gitcmd.Repository }
+
+func (r unlistableRepository) ListFiles(vcs.CommitID) ([]string, error) {
+	return nil, errors.New("ListFiles called")
+}
+
+func (r unlistableRepository) ListFilesContext(context.Context, vcs.CommitID) ([]string, error) {
+	return nil, errors.New("ListFiles called")
+}
+
+// lineSearchResult returns the result for a match in a file with a
+// single line, whose submatches are the byte ranges given by
+// startsAndEnds.
+func lineSearchResult(file, line string, startsAndEnds ...uint32) *vcs.SearchResult {
+	var submatches []*vcs.SearchSubmatch
+	for i := 0; i < len(startsAndEnds); i += 2 {
+		submatches = append(submatches, &vcs.SearchSubmatch{Start: startsAndEnds[i], End: startsAndEnds[i+1]})
+	}
+	return &vcs.SearchResult{
+		File:      file,
+		StartByte: 0,
+		EndByte:   uint32(len(line)),
+		StartLine: 1,
+		EndLine:   1,
+		Match:     []byte(line),
+		Lines:     []*vcs.SearchLine{{Line: 1, Submatches: submatches}},
+	}
+}
+
+// testRepositorySearch is a helper that tests repository search
+// over git and hg repositories specified by the initialization in
+// gitCommands and hgCommands.
+func testRepositorySearch(t *testing.T, gitCommands, hgCommands []string, searchOpt vcs.SearchOptions, wantRes []*vcs.SearchResult) {
+	gitRepo := makeGitRepositoryCmd(t, gitCommands...)
+	tests := map[string]struct {
+		repo interface {
+			vcs.Searcher
+			ResolveRevision(spec string) (vcs.CommitID, error)
+		}
+		searcher    vcs.Searcher // if nil, repo is searched
+		spec        string
 		opt         vcs.SearchOptions
 		wantResults []*vcs.SearchResult
 	}{
 		"git cmd": {
-			repo:        makeGitRepositoryCmd(t, repoInitCmds...),
+			repo:        gitRepo,
+			spec:        "master",
+			opt:         searchOpt,
+			wantResults: wantRes,
+		},
+		"git cmd index": {
+			repo:        gitRepo,
+			searcher:    searchindex.NewSearcher(gitRepo, makeTmpDir(t, "search-index")),
 			spec:        "master",
 			opt:         searchOpt,
 			wantResults: wantRes,
 		},
 		"git go-git": {
-			repo:        makeGitRepositoryGoGit(t, repoInitCmds...),
+			repo:        makeGitRepositoryGoGit(t, gitCommands...),
 			spec:        "master",
 			opt:         searchOpt,
 			wantResults: wantRes,
 		},
+		"hg cmd": {
+			repo:        makeHgRepositoryCmd(t, hgCommands...),
+			spec:        "tip",
+			opt:         searchOpt,
+			wantResults: wantRes,
+		},
+		"hg native": {
+			repo:        makeHgRepositoryNative(t, hgCommands...),
+			spec:        "tip",
+			opt:         searchOpt,
+			wantResults: wantRes,
+		},
 	}
 
 	for label, test := range tests {
-		res, err := test.repo.Search(test.spec, test.opt)
+		if strings.HasPrefix(label, "hg ") {
+			continue // hg broken, see issue #104.
+		}
+
+		commitID, err := test.repo.ResolveRevision(test.spec)
+		if err != nil {
+			t.Errorf("%s: ResolveRevision(%q): %s", label, test.spec, err)
+			continue
+		}
+		searcher := test.searcher
+		if searcher == nil {
+			searcher = test.repo
+		}
+		res, err := searcher.Search(commitID, test.opt)
 		if err != nil {
 			t.Errorf("%s: Search: %s", label, err)
 			continue
@@ -0,0 +1,224 @@
+package searchindex
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/tools/godoc/vfs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/internal"
+)
+
+// indexVersion is the version of the format of index files. Index
+// files of other versions are rebuilt.
+const indexVersion = 2
+
+// An index holds the names of the text files of a commit's tree and,
+// for each trigram (3 consecutive bytes) of their contents, the list
+// of files that contain it. It doesn't hold the contents, which
+// searches read from the commit's FileSystem for the candidate files
+// only.
+//
+// Trigrams are computed on the contents with ASCII letters converted
+// to lower case, so that the same index can be used for
+// case-sensitive and case-insensitive queries.
+type index struct {
+	Version int
+	Files   []indexFile         // sorted by name
+	Posting map[uint32][]uint32 // trigram -> sorted indexes of Files
+}
+
+type indexFile struct {
+	Name string
+	Size int64
+}
+
+// buildIndex builds the index of the tree of repo at the commit. Binary
+// files aren't indexed, because they aren't searched.
+func buildIndex(ctx context.Context, repo vcs.Repository, at vcs.CommitID) (*index, error) {
+	fs, err := fileSystem(ctx, repo, at)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	switch repo := repo.(type) {
+	case vcs.FileListerContext:
+		names, err = repo.ListFilesContext(ctx, at)
+	case vcs.FileLister:
+		names, err = repo.ListFiles(at)
+	default:
+		names, err = listFiles(ctx, fs, ".")
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	idx := &index{Version: indexVersion, Posting: map[uint32][]uint32{}}
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		data, err := vfs.ReadFile(fs, name)
+		if err != nil {
+			return nil, err
+		}
+		if internal.IsBinary(data) {
+			continue
+		}
+		idx.add(name, data)
+	}
+	return idx, nil
+}
+
+// fileSystem returns the FileSystem of repo at the commit.
+func fileSystem(ctx context.Context, repo vcs.Repository, at vcs.CommitID) (vfs.FileSystem, error) {
+	if repoCtx, ok := repo.(vcs.RepositoryContext); ok {
+		return repoCtx.FileSystemContext(ctx, at)
+	}
+	return repo.FileSystem(at)
+}
+
+// listFiles returns the paths of the regular files in the directory
+// dir of fs and its subdirectories. It is used for repositories that
+// don't implement vcs.FileLister.
+func listFiles(ctx context.Context, fs vfs.FileSystem, dir string) ([]string, error) {
+	fis, err := fs.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, fi := range fis {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		name := path.Join(dir, fi.Name())
+		switch {
+		case fi.Mode().IsDir():
+			sub, err := listFiles(ctx, fs, name)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, sub...)
+		case fi.Mode().IsRegular():
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// add adds a file to the index. Files must be added in order of their
+// names.
+func (idx *index) add(name string, data []byte) {
+	file := uint32(len(idx.Files))
+	idx.Files = append(idx.Files, indexFile{Name: name, Size: int64(len(data))})
+	if len(data) < 3 {
+		return
+	}
+	t := uint32(toLower(data[0]))<<8 | uint32(toLower(data[1]))
+	for _, c := range data[2:] {
+		t = (t<<8 | uint32(toLower(c))) & 0xFFFFFF
+		if p := idx.Posting[t]; len(p) == 0 || p[len(p)-1] != file {
+			idx.Posting[t] = append(p, file)
+		}
+	}
+}
+
+// candidates returns the indexes of the files that contain all of the
+// trigrams, in order. If there are no trigrams, all files are
+// candidates.
+func (idx *index) candidates(trigrams []uint32) []uint32 {
+	if len(trigrams) == 0 {
+		all := make([]uint32, len(idx.Files))
+		for i := range all {
+			all[i] = uint32(i)
+		}
+		return all
+	}
+	// Start with the shortest list, so that the intersection is never
+	// longer than it.
+	lists := make([][]uint32, len(trigrams))
+	for i, t := range trigrams {
+		lists[i] = idx.Posting[t]
+	}
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+	files := lists[0]
+	for _, list := range lists[1:] {
+		files = intersect(files, list)
+	}
+	return files
+}
+
+// intersect returns the elements of the sorted lists a and b that are
+// in both.
+func intersect(a, b []uint32) []uint32 {
+	var c []uint32
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			c = append(c, a[i])
+			i++
+			j++
+		}
+	}
+	return c
+}
+
+func toLower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// readIndex reads the index file at filename.
+func readIndex(filename string) (*index, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var idx index
+	if err := gob.NewDecoder(f).Decode(&idx); err != nil {
+		return nil, fmt.Errorf("reading search index %s: %s", filename, err)
+	}
+	if idx.Version != indexVersion {
+		return nil, fmt.Errorf("search index %s has version %d, not %d", filename, idx.Version, indexVersion)
+	}
+	return &idx, nil
+}
+
+// writeIndex writes the index to the file at filename. The file is
+// replaced atomically, so readers never see a partially written
+// index.
+func writeIndex(filename string, idx *index) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(idx); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), filename); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package searchindex
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"unicode"
+	"unicode/utf8"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+// queryTrigrams returns the trigrams (see index) that every file
+// matching the query of opt contains.
+func queryTrigrams(opt vcs.SearchOptions) ([]uint32, error) {
+	var expr string
+	flags := syntax.Perl
+	switch opt.QueryType {
+	case vcs.FixedQuery:
+		expr = regexp.QuoteMeta(opt.Query)
+	case vcs.IgnoreCaseQuery:
+		expr = "(?i)" + regexp.QuoteMeta(opt.Query)
+	case vcs.RegexpQuery:
+		expr = opt.Query
+	case vcs.ExtendedRegexpQuery:
+		expr = opt.Query
+		flags = syntax.POSIX
+	default:
+		return nil, fmt.Errorf("unrecognized QueryType: %q", opt.QueryType)
+	}
+	re, err := syntax.Parse(expr, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[uint32]bool{}
+	var trigrams []uint32
+	for _, lit := range requiredLiterals(re) {
+		for i := 0; i+3 <= len(lit); i++ {
+			t := uint32(lit[i])<<16 | uint32(lit[i+1])<<8 | uint32(lit[i+2])
+			if !seen[t] {
+				seen[t] = true
+				trigrams = append(trigrams, t)
+			}
+		}
+	}
+	return trigrams, nil
+}
+
+// requiredLiterals returns strings (with ASCII letters converted to
+// lower case) that every text matching re contains. It is
+// conservative: it may omit some required strings, but never returns
+// a string that isn't required.
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return literalRuns(re)
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		// Adjacent literals match consecutive text, so they are joined
+		// into one string.
+		var lits []string
+		var run []rune
+		var runFold syntax.Flags
+		flush := func() {
+			if len(run) > 0 {
+				lits = append(lits, literalRuns(&syntax.Regexp{Op: syntax.OpLiteral, Flags: runFold, Rune: run})...)
+				run = nil
+			}
+		}
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral {
+				if fold := sub.Flags & syntax.FoldCase; len(run) > 0 && fold != runFold {
+					flush()
+				}
+				runFold = sub.Flags & syntax.FoldCase
+				run = append(run, sub.Rune...)
+				continue
+			}
+			flush()
+			lits = append(lits, requiredLiterals(sub)...)
+		}
+		flush()
+		return lits
+	}
+	return nil
+}
+
+// literalRuns returns the text matched by a literal, with ASCII letters
+// converted to lower case. If the literal is case-insensitive, runes
+// that match non-ASCII runes (such as 'k', which matches the Kelvin
+// sign) break the text into several strings, because the matching
+// text may contain other bytes than the literal.
+func literalRuns(re *syntax.Regexp) []string {
+	var runs []string
+	var buf []byte
+	for _, r := range re.Rune {
+		if re.Flags&syntax.FoldCase != 0 && !asciiFold(r) {
+			if len(buf) > 0 {
+				runs = append(runs, string(buf))
+				buf = nil
+			}
+			continue
+		}
+		if r < utf8.RuneSelf {
+			buf = append(buf, toLower(byte(r)))
+		} else {
+			buf = append(buf, string(r)...)
+		}
+	}
+	if len(buf) > 0 {
+		runs = append(runs, string(buf))
+	}
+	return runs
+}
+
+// asciiFold reports whether r and all runes that it matches
+// case-insensitively are ASCII.
+func asciiFold(r rune) bool {
+	if r >= utf8.RuneSelf {
+		return false
+	}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
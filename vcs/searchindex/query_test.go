@@ -0,0 +1,73 @@
+package searchindex
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestQueryTrigrams(t *testing.T) {
+	tests := []struct {
+		opt  vcs.SearchOptions
+		want []string
+	}{
+		{vcs.SearchOptions{Query: "ab", QueryType: vcs.FixedQuery}, nil},
+		{vcs.SearchOptions{Query: "a.cD", QueryType: vcs.FixedQuery}, []string{"a.c", ".cd"}},
+		{vcs.SearchOptions{Query: "abc", QueryType: vcs.IgnoreCaseQuery}, []string{"abc"}},
+		{vcs.SearchOptions{Query: "skip", QueryType: vcs.IgnoreCaseQuery}, nil}, // 's' and 'k' match non-ASCII runes
+		{vcs.SearchOptions{Query: "abcd", QueryType: vcs.RegexpQuery, WholeWord: true}, []string{"abc", "bcd"}},
+		{vcs.SearchOptions{Query: `abc.*def|abcx`, QueryType: vcs.RegexpQuery}, []string{"abc"}},
+		{vcs.SearchOptions{Query: `(abc)+x?def`, QueryType: vcs.RegexpQuery}, []string{"abc", "def"}},
+		{vcs.SearchOptions{Query: `(abc)*def`, QueryType: vcs.RegexpQuery}, []string{"def"}},
+		{vcs.SearchOptions{Query: `abc|def`, QueryType: vcs.RegexpQuery}, nil},
+		{vcs.SearchOptions{Query: `(?i)abc`, QueryType: vcs.RegexpQuery}, []string{"abc"}},
+		{vcs.SearchOptions{Query: `ABC(?i)def`, QueryType: vcs.RegexpQuery}, []string{"abc", "def"}},
+		{vcs.SearchOptions{Query: `a[0-9]{2}bcd`, QueryType: vcs.ExtendedRegexpQuery}, []string{"bcd"}},
+		{vcs.SearchOptions{Query: "äbc", QueryType: vcs.FixedQuery}, []string{"\xc3\xa4b", "\xa4bc"}},
+	}
+	for _, test := range tests {
+		trigrams, err := queryTrigrams(test.opt)
+		if err != nil {
+			t.Errorf("%+v: %s", test.opt, err)
+			continue
+		}
+		var got []string
+		for _, tri := range trigrams {
+			got = append(got, string([]byte{byte(tri >> 16), byte(tri >> 8), byte(tri)}))
+		}
+		sort.Strings(got)
+		sort.Strings(test.want)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v: got trigrams %q, want %q", test.opt, got, test.want)
+		}
+	}
+}
+
+func TestIndex_candidates(t *testing.T) {
+	idx := &index{Posting: map[uint32][]uint32{}}
+	idx.add("a", []byte("xyz abc"))
+	idx.add("b", []byte("ABC\ndef"))
+	idx.add("c", []byte("de"))
+
+	tests := []struct {
+		query string
+		want  []uint32
+	}{
+		{"", []uint32{0, 1, 2}},
+		{"abc", []uint32{0, 1}},
+		{"abcdef", nil},
+		{"c\nd", []uint32{1}},
+		{"xyz abc", []uint32{0}},
+	}
+	for _, test := range tests {
+		trigrams, err := queryTrigrams(vcs.SearchOptions{Query: test.query, QueryType: vcs.FixedQuery})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := idx.candidates(trigrams); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got candidates %v, want %v", test.query, got, test.want)
+		}
+	}
+}
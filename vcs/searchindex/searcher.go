@@ -0,0 +1,228 @@
+// Package searchindex implements a vcs.Searcher that answers queries
+// from a trigram index of a commit's tree, instead of reading the
+// whole tree on every query like the backends' searchers do. Only the
+// files that the index selects as candidates are read.
+//
+// The index of a commit is built (using the repository's FileSystem
+// and, if it implements it, FileLister) the first time the commit is
+// searched, and persisted in a directory (usually beside the
+// repository). Commits are immutable, so an index never needs to be
+// updated; indexes of commits that are no longer searched are removed
+// with Searcher.GC.
+package searchindex
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/godoc/vfs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/internal"
+)
+
+// maxCachedIndexes is the number of indexes that a Searcher keeps in
+// memory. An index only holds file names and posting lists, not the
+// files' contents.
+const maxCachedIndexes = 4
+
+// indexSuffix is the suffix of the names of index files, which are
+// named after their commit ID.
+const indexSuffix = ".index"
+
+// A Searcher searches a repository using the indexes that it stores
+// in a directory. It implements vcs.Searcher and vcs.SearcherContext
+// with the same semantics as the backends' searchers.
+type Searcher struct {
+	repo vcs.Repository
+	dir  string
+
+	mu     sync.Mutex
+	cache  map[vcs.CommitID]*cacheEntry
+	cached []vcs.CommitID // the loaded indexes in cache, least recently loaded first
+}
+
+type cacheEntry struct {
+	ready chan struct{} // closed when the index is loaded
+	idx   *index
+	err   error
+}
+
+// NewSearcher returns a Searcher for repo that stores its indexes in
+// dir, which is created if it doesn't exist.
+func NewSearcher(repo vcs.Repository, dir string) *Searcher {
+	return &Searcher{repo: repo, dir: dir, cache: map[vcs.CommitID]*cacheEntry{}}
+}
+
+func (s *Searcher) Search(at vcs.CommitID, opt vcs.SearchOptions) ([]*vcs.SearchResult, error) {
+	return s.SearchContext(context.Background(), at, opt)
+}
+
+func (s *Searcher) SearchContext(ctx context.Context, at vcs.CommitID, opt vcs.SearchOptions) ([]*vcs.SearchResult, error) {
+	m, err := internal.CompileSearch(opt)
+	if err != nil {
+		return nil, err
+	}
+	trigrams, err := queryTrigrams(opt)
+	if err != nil {
+		return nil, err
+	}
+	at, err = s.resolve(ctx, at)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := s.get(ctx, at)
+	if err != nil {
+		return nil, err
+	}
+	fs, err := fileSystem(ctx, s.repo, at)
+	if err != nil {
+		return nil, err
+	}
+
+	page := internal.NewSearchPage(opt)
+	for _, i := range idx.candidates(trigrams) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		f := &idx.Files[i]
+		if !m.MatchPath(f.Name) || !m.MatchSize(f.Size) {
+			continue
+		}
+		if page.Full() {
+			page.Truncate()
+			break
+		}
+		data, err := vfs.ReadFile(fs, f.Name)
+		if err != nil {
+			return nil, err
+		}
+		page.Add(m.SearchFile(f.Name, data, int(opt.ContextLines)))
+	}
+	return page.Results, nil
+}
+
+// Index builds the index of the commit at, unless it is already
+// built. Searches build the indexes that they need, so calling Index
+// is only necessary to build an index ahead of time.
+func (s *Searcher) Index(ctx context.Context, at vcs.CommitID) error {
+	at, err := s.resolve(ctx, at)
+	if err != nil {
+		return err
+	}
+	_, err = s.get(ctx, at)
+	return err
+}
+
+// resolve resolves at to a full commit ID, which indexes are stored
+// under.
+func (s *Searcher) resolve(ctx context.Context, at vcs.CommitID) (vcs.CommitID, error) {
+	if repo, ok := s.repo.(vcs.RepositoryContext); ok {
+		return repo.ResolveRevisionContext(ctx, string(at))
+	}
+	return s.repo.ResolveRevision(string(at))
+}
+
+// get returns the index of the commit at, loading it if it isn't
+// cached.
+func (s *Searcher) get(ctx context.Context, at vcs.CommitID) (*index, error) {
+	for {
+		s.mu.Lock()
+		e, ok := s.cache[at]
+		if !ok {
+			e = &cacheEntry{ready: make(chan struct{})}
+			s.cache[at] = e
+			s.mu.Unlock()
+
+			e.idx, e.err = s.load(ctx, at)
+
+			s.mu.Lock()
+			if e.err != nil {
+				delete(s.cache, at)
+			} else {
+				s.cached = append(s.cached, at)
+				if len(s.cached) > maxCachedIndexes {
+					delete(s.cache, s.cached[0])
+					s.cached = s.cached[1:]
+				}
+			}
+			s.mu.Unlock()
+			close(e.ready)
+			return e.idx, e.err
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-e.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if e.err == nil {
+			return e.idx, nil
+		}
+		// Loading the index failed, possibly because the context of
+		// the search that was loading it is done, so try again.
+	}
+}
+
+// load reads the index of the commit at from its file, or builds and
+// writes it if the file doesn't exist (or can't be read).
+func (s *Searcher) load(ctx context.Context, at vcs.CommitID) (*index, error) {
+	filename := filepath.Join(s.dir, string(at)+indexSuffix)
+	if idx, err := readIndex(filename); err == nil {
+		return idx, nil
+	}
+	idx, err := buildIndex(ctx, s.repo, at)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := writeIndex(filename, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// GC removes the indexes of all commits except the commits in keep,
+// both from memory and from the directory of indexes.
+func (s *Searcher) GC(keep []vcs.CommitID) error {
+	keepSet := make(map[vcs.CommitID]bool, len(keep))
+	for _, commit := range keep {
+		keepSet[commit] = true
+	}
+
+	s.mu.Lock()
+	var cached []vcs.CommitID
+	for _, commit := range s.cached {
+		if keepSet[commit] {
+			cached = append(cached, commit)
+		} else {
+			delete(s.cache, commit)
+		}
+	}
+	s.cached = cached
+	s.mu.Unlock()
+
+	fis, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, fi := range fis {
+		name := fi.Name()
+		if !strings.HasSuffix(name, indexSuffix) || keepSet[vcs.CommitID(strings.TrimSuffix(name, indexSuffix))] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -81,7 +81,7 @@ func TestRepository_Clone_ssh(t *testing.T) {
 				t.Errorf("%s: Tags: %s", label, err)
 			}
 
-			wantTags := []*vcs.Tag{{Name: "t0", CommitID: test.wantCommitID}}
+			wantTags := []*vcs.Tag{{Name: "t0", CommitID: test.wantCommitID, ObjectID: string(test.wantCommitID), TargetType: "commit"}}
 			if !reflect.DeepEqual(tags, wantTags) {
 				t.Errorf("%s: got tags %s, want %s", label, asJSON(tags), asJSON(wantTags))
 			}
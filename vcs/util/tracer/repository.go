@@ -1,6 +1,7 @@
 package tracer
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -137,3 +138,158 @@ func (r repository) FileSystem(at vcs.CommitID) (vfs.FileSystem, error) {
 	}
 	return fileSystem{fs: fs, rec: r.rec}, nil
 }
+
+// ResolveRevisionContext implements the vcs.RepositoryContext interface.
+func (r repository) ResolveRevisionContext(ctx context.Context, spec string) (vcs.CommitID, error) {
+	c, ok := r.r.(vcs.RepositoryContext)
+	if !ok {
+		return r.ResolveRevision(spec)
+	}
+	start := time.Now()
+	rev, err := c.ResolveRevisionContext(ctx, spec)
+	r.rec.Child().Event(GoVCS{
+		Name:      "vcs.RepositoryContext.ResolveRevisionContext",
+		Args:      fmt.Sprintf("%#v", spec),
+		StartTime: start,
+		EndTime:   time.Now(),
+	})
+	return rev, err
+}
+
+// ResolveTagContext implements the vcs.RepositoryContext interface.
+func (r repository) ResolveTagContext(ctx context.Context, name string) (vcs.CommitID, error) {
+	c, ok := r.r.(vcs.RepositoryContext)
+	if !ok {
+		return r.ResolveTag(name)
+	}
+	start := time.Now()
+	tag, err := c.ResolveTagContext(ctx, name)
+	r.rec.Child().Event(GoVCS{
+		Name:      "vcs.RepositoryContext.ResolveTagContext",
+		Args:      fmt.Sprintf("%#v", name),
+		StartTime: start,
+		EndTime:   time.Now(),
+	})
+	return tag, err
+}
+
+// ResolveBranchContext implements the vcs.RepositoryContext interface.
+func (r repository) ResolveBranchContext(ctx context.Context, name string) (vcs.CommitID, error) {
+	c, ok := r.r.(vcs.RepositoryContext)
+	if !ok {
+		return r.ResolveBranch(name)
+	}
+	start := time.Now()
+	branch, err := c.ResolveBranchContext(ctx, name)
+	r.rec.Child().Event(GoVCS{
+		Name:      "vcs.RepositoryContext.ResolveBranchContext",
+		Args:      fmt.Sprintf("%#v", name),
+		StartTime: start,
+		EndTime:   time.Now(),
+	})
+	return branch, err
+}
+
+// BranchesContext implements the vcs.RepositoryContext interface.
+func (r repository) BranchesContext(ctx context.Context, opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
+	c, ok := r.r.(vcs.RepositoryContext)
+	if !ok {
+		return r.Branches(opt)
+	}
+	start := time.Now()
+	branches, err := c.BranchesContext(ctx, opt)
+	r.rec.Child().Event(GoVCS{
+		Name:      "vcs.RepositoryContext.BranchesContext",
+		Args:      fmt.Sprintf("%#v", opt),
+		StartTime: start,
+		EndTime:   time.Now(),
+	})
+	return branches, err
+}
+
+// TagsContext implements the vcs.RepositoryContext interface.
+func (r repository) TagsContext(ctx context.Context) ([]*vcs.Tag, error) {
+	c, ok := r.r.(vcs.RepositoryContext)
+	if !ok {
+		return r.Tags()
+	}
+	start := time.Now()
+	tags, err := c.TagsContext(ctx)
+	r.rec.Child().Event(GoVCS{
+		Name:      "vcs.RepositoryContext.TagsContext",
+		StartTime: start,
+		EndTime:   time.Now(),
+	})
+	return tags, err
+}
+
+// GetCommitContext implements the vcs.RepositoryContext interface.
+func (r repository) GetCommitContext(ctx context.Context, commitID vcs.CommitID) (*vcs.Commit, error) {
+	c, ok := r.r.(vcs.RepositoryContext)
+	if !ok {
+		return r.GetCommit(commitID)
+	}
+	start := time.Now()
+	commit, err := c.GetCommitContext(ctx, commitID)
+	r.rec.Child().Event(GoVCS{
+		Name:      "vcs.RepositoryContext.GetCommitContext",
+		Args:      fmt.Sprintf("%#v", commitID),
+		StartTime: start,
+		EndTime:   time.Now(),
+	})
+	return commit, err
+}
+
+// CommitsContext implements the vcs.RepositoryContext interface.
+func (r repository) CommitsContext(ctx context.Context, opt vcs.CommitsOptions) (commits []*vcs.Commit, total uint, err error) {
+	c, ok := r.r.(vcs.RepositoryContext)
+	if !ok {
+		return r.Commits(opt)
+	}
+	start := time.Now()
+	commits, total, err = c.CommitsContext(ctx, opt)
+	r.rec.Child().Event(GoVCS{
+		Name:      "vcs.RepositoryContext.CommitsContext",
+		Args:      fmt.Sprintf("%#v", opt),
+		StartTime: start,
+		EndTime:   time.Now(),
+	})
+	return commits, total, err
+}
+
+// CommittersContext implements the vcs.RepositoryContext interface.
+func (r repository) CommittersContext(ctx context.Context, opt vcs.CommittersOptions) ([]*vcs.Committer, error) {
+	c, ok := r.r.(vcs.RepositoryContext)
+	if !ok {
+		return r.Committers(opt)
+	}
+	start := time.Now()
+	committers, err := c.CommittersContext(ctx, opt)
+	r.rec.Child().Event(GoVCS{
+		Name:      "vcs.RepositoryContext.CommittersContext",
+		Args:      fmt.Sprintf("%#v", opt),
+		StartTime: start,
+		EndTime:   time.Now(),
+	})
+	return committers, err
+}
+
+// FileSystemContext implements the vcs.RepositoryContext interface.
+func (r repository) FileSystemContext(ctx context.Context, at vcs.CommitID) (vfs.FileSystem, error) {
+	c, ok := r.r.(vcs.RepositoryContext)
+	if !ok {
+		return r.FileSystem(at)
+	}
+	start := time.Now()
+	fs, err := c.FileSystemContext(ctx, at)
+	r.rec.Child().Event(GoVCS{
+		Name:      "vcs.RepositoryContext.FileSystemContext",
+		Args:      fmt.Sprintf("%#v", at),
+		StartTime: start,
+		EndTime:   time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fileSystem{fs: fs, rec: r.rec}, nil
+}
@@ -1,6 +1,7 @@
 package tracer
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -25,6 +26,49 @@ func (GoVCS) Schema() string { return "GoVCS" }
 func (e GoVCS) Start() time.Time { return e.StartTime }
 func (e GoVCS) End() time.Time   { return e.EndTime }
 
+// The wrappers in this package implement both the plain and the
+// Context variant of each interface. When the wrapped repository does
+// not implement the Context variant, the wrapper falls back to the
+// plain method and ignores ctx.
+type (
+	tracedRepository interface {
+		vcs.Repository
+		vcs.RepositoryContext
+	}
+	tracedBlamer interface {
+		vcs.Blamer
+		vcs.BlamerContext
+	}
+	tracedDiffer interface {
+		vcs.Differ
+		vcs.DifferContext
+	}
+	tracedCrossRepoDiffer interface {
+		vcs.CrossRepoDiffer
+		vcs.CrossRepoDifferContext
+	}
+	tracedFileLister interface {
+		vcs.FileLister
+		vcs.FileListerContext
+	}
+	tracedMerger interface {
+		vcs.Merger
+		vcs.MergerContext
+	}
+	tracedCrossRepoMerger interface {
+		vcs.CrossRepoMerger
+		vcs.CrossRepoMergerContext
+	}
+	tracedRemoteUpdater interface {
+		vcs.RemoteUpdater
+		vcs.RemoteUpdaterContext
+	}
+	tracedSearcher interface {
+		vcs.Searcher
+		vcs.SearcherContext
+	}
+)
+
 // Wrap wraps the given VCS repository, returning a repository which emits
 // tracing events.
 func Wrap(r vcs.Repository, rec *appdash.Recorder) vcs.Repository {
@@ -64,92 +108,92 @@ func Wrap(r vcs.Repository, rec *appdash.Recorder) vcs.Repository {
 	switch {
 	case isBlamer && isDiffer && isCrossRepoDiffer && isFileLister && isMerger && isCrossRepoMerger && isRemoteUpdater && isSearcher && isGitcmdCrossRepo:
 		return struct {
-			vcs.Repository
-			vcs.Blamer
-			vcs.Differ
-			vcs.CrossRepoDiffer
-			vcs.FileLister
-			vcs.Merger
-			vcs.CrossRepoMerger
-			vcs.RemoteUpdater
-			vcs.Searcher
+			tracedRepository
+			tracedBlamer
+			tracedDiffer
+			tracedCrossRepoDiffer
+			tracedFileLister
+			tracedMerger
+			tracedCrossRepoMerger
+			tracedRemoteUpdater
+			tracedSearcher
 			gitcmd.CrossRepo
 		}{t, blamer, differ, crossRepoDiffer, fileLister, merger, crossRepoMerger, remoteUpdater, searcher, gitcmdCrossRepo}
 
 	case isBlamer && isDiffer && isCrossRepoDiffer && isFileLister && isMerger && isCrossRepoMerger && isRemoteUpdater && isSearcher:
 		return struct {
-			vcs.Repository
-			vcs.Blamer
-			vcs.Differ
-			vcs.CrossRepoDiffer
-			vcs.FileLister
-			vcs.Merger
-			vcs.CrossRepoMerger
-			vcs.RemoteUpdater
-			vcs.Searcher
+			tracedRepository
+			tracedBlamer
+			tracedDiffer
+			tracedCrossRepoDiffer
+			tracedFileLister
+			tracedMerger
+			tracedCrossRepoMerger
+			tracedRemoteUpdater
+			tracedSearcher
 		}{t, blamer, differ, crossRepoDiffer, fileLister, merger, crossRepoMerger, remoteUpdater, searcher}
 
 	case isBlamer && isDiffer && isCrossRepoDiffer && isFileLister && isMerger && isCrossRepoMerger && isRemoteUpdater:
 		return struct {
-			vcs.Repository
-			vcs.Blamer
-			vcs.Differ
-			vcs.CrossRepoDiffer
-			vcs.FileLister
-			vcs.Merger
-			vcs.CrossRepoMerger
-			vcs.RemoteUpdater
+			tracedRepository
+			tracedBlamer
+			tracedDiffer
+			tracedCrossRepoDiffer
+			tracedFileLister
+			tracedMerger
+			tracedCrossRepoMerger
+			tracedRemoteUpdater
 		}{t, blamer, differ, crossRepoDiffer, fileLister, merger, crossRepoMerger, remoteUpdater}
 
 	case isBlamer && isDiffer && isCrossRepoDiffer && isFileLister && isMerger && isCrossRepoMerger:
 		return struct {
-			vcs.Repository
-			vcs.Blamer
-			vcs.Differ
-			vcs.CrossRepoDiffer
-			vcs.FileLister
-			vcs.Merger
-			vcs.CrossRepoMerger
+			tracedRepository
+			tracedBlamer
+			tracedDiffer
+			tracedCrossRepoDiffer
+			tracedFileLister
+			tracedMerger
+			tracedCrossRepoMerger
 		}{t, blamer, differ, crossRepoDiffer, fileLister, merger, crossRepoMerger}
 
 	case isBlamer && isDiffer && isCrossRepoDiffer && isFileLister && isMerger:
 		return struct {
-			vcs.Repository
-			vcs.Blamer
-			vcs.Differ
-			vcs.CrossRepoDiffer
-			vcs.FileLister
-			vcs.Merger
+			tracedRepository
+			tracedBlamer
+			tracedDiffer
+			tracedCrossRepoDiffer
+			tracedFileLister
+			tracedMerger
 		}{t, blamer, differ, crossRepoDiffer, fileLister, merger}
 
 	case isBlamer && isDiffer && isCrossRepoDiffer && isFileLister:
 		return struct {
-			vcs.Repository
-			vcs.Blamer
-			vcs.Differ
-			vcs.CrossRepoDiffer
-			vcs.FileLister
+			tracedRepository
+			tracedBlamer
+			tracedDiffer
+			tracedCrossRepoDiffer
+			tracedFileLister
 		}{t, blamer, differ, crossRepoDiffer, fileLister}
 
 	case isBlamer && isDiffer && isCrossRepoDiffer:
 		return struct {
-			vcs.Repository
-			vcs.Blamer
-			vcs.Differ
-			vcs.CrossRepoDiffer
+			tracedRepository
+			tracedBlamer
+			tracedDiffer
+			tracedCrossRepoDiffer
 		}{t, blamer, differ, crossRepoDiffer}
 
 	case isBlamer && isDiffer:
 		return struct {
-			vcs.Repository
-			vcs.Blamer
-			vcs.Differ
+			tracedRepository
+			tracedBlamer
+			tracedDiffer
 		}{t, blamer, differ}
 
 	case isBlamer:
 		return struct {
-			vcs.Repository
-			vcs.Blamer
+			tracedRepository
+			tracedBlamer
 		}{t, blamer}
 
 	default:
@@ -176,6 +220,23 @@ func (b blamer) BlameFile(path string, opt *vcs.BlameOptions) ([]*vcs.Hunk, erro
 	return hunks, err
 }
 
+// BlameFileContext implements the vcs.BlamerContext interface.
+func (b blamer) BlameFileContext(ctx context.Context, path string, opt *vcs.BlameOptions) ([]*vcs.Hunk, error) {
+	c, ok := b.b.(vcs.BlamerContext)
+	if !ok {
+		return b.BlameFile(path, opt)
+	}
+	start := time.Now()
+	hunks, err := c.BlameFileContext(ctx, path, opt)
+	b.rec.Child().Event(GoVCS{
+		Name:      "vcs.BlamerContext.BlameFileContext",
+		Args:      fmt.Sprintf("%#v, %#v", path, opt),
+		StartTime: start,
+		EndTime:   time.Now(),
+	})
+	return hunks, err
+}
+
 // differ wraps a vcs.Differ, adding tracing to it.
 type differ struct {
 	d   vcs.Differ
@@ -195,6 +256,23 @@ func (d differ) Diff(base, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.Diff,
 	return diff, err
 }
 
+// DiffContext implements the vcs.DifferContext interface.
+func (d differ) DiffContext(ctx context.Context, base, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.Diff, error) {
+	c, ok := d.d.(vcs.DifferContext)
+	if !ok {
+		return d.Diff(base, head, opt)
+	}
+	start := time.Now()
+	diff, err := c.DiffContext(ctx, base, head, opt)
+	d.rec.Child().Event(GoVCS{
+		Name:      "vcs.DifferContext.DiffContext",
+		Args:      fmt.Sprintf("%#v, %#v, %#v", base, head, opt),
+		StartTime: start,
+		EndTime:   time.Now(),
+	})
+	return diff, err
+}
+
 // crossRepoDiffer wraps a vcs.CrossRepoDiffer, adding tracing to it.
 type crossRepoDiffer struct {
 	c   vcs.CrossRepoDiffer
@@ -214,6 +292,23 @@ func (c crossRepoDiffer) CrossRepoDiff(base vcs.CommitID, headRepo vcs.Repositor
 	return diff, err
 }
 
+// CrossRepoDiffContext implements the vcs.CrossRepoDifferContext interface.
+func (c crossRepoDiffer) CrossRepoDiffContext(ctx context.Context, base vcs.CommitID, headRepo vcs.Repository, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.Diff, error) {
+	cd, ok := c.c.(vcs.CrossRepoDifferContext)
+	if !ok {
+		return c.CrossRepoDiff(base, headRepo, head, opt)
+	}
+	start := time.Now()
+	diff, err := cd.CrossRepoDiffContext(ctx, base, headRepo, head, opt)
+	c.rec.Child().Event(GoVCS{
+		Name:      "vcs.CrossRepoDifferContext.CrossRepoDiffContext",
+		Args:      fmt.Sprintf("%#v, %#v, %#v, %#v", base, headRepo, head, opt),
+		StartTime: start,
+		EndTime:   time.Now(),
+	})
+	return diff, err
+}
+
 // fileLister wraps a vcs.FileListener, adding tracing to it.
 type fileLister struct {
 	f   vcs.FileLister
@@ -233,6 +328,23 @@ func (f fileLister) ListFiles(commit vcs.CommitID) ([]string, error) {
 	return files, err
 }
 
+// ListFilesContext implements the vcs.FileListerContext interface.
+func (f fileLister) ListFilesContext(ctx context.Context, commit vcs.CommitID) ([]string, error) {
+	c, ok := f.f.(vcs.FileListerContext)
+	if !ok {
+		return f.ListFiles(commit)
+	}
+	start := time.Now()
+	files, err := c.ListFilesContext(ctx, commit)
+	f.rec.Child().Event(GoVCS{
+		Name:      "vcs.FileListerContext.ListFilesContext",
+		Args:      fmt.Sprintf("%#v", commit),
+		StartTime: start,
+		EndTime:   time.Now(),
+	})
+	return files, err
+}
+
 // merger wraps a vcs.Merger, adding tracing to it.
 type merger struct {
 	m   vcs.Merger
@@ -252,6 +364,23 @@ func (m merger) MergeBase(a vcs.CommitID, b vcs.CommitID) (vcs.CommitID, error)
 	return commit, err
 }
 
+// MergeBaseContext implements the vcs.MergerContext interface.
+func (m merger) MergeBaseContext(ctx context.Context, a vcs.CommitID, b vcs.CommitID) (vcs.CommitID, error) {
+	c, ok := m.m.(vcs.MergerContext)
+	if !ok {
+		return m.MergeBase(a, b)
+	}
+	start := time.Now()
+	commit, err := c.MergeBaseContext(ctx, a, b)
+	m.rec.Child().Event(GoVCS{
+		Name:      "vcs.MergerContext.MergeBaseContext",
+		Args:      fmt.Sprintf("%#v, %#v", a, b),
+		StartTime: start,
+		EndTime:   time.Now(),
+	})
+	return commit, err
+}
+
 // crossRepoMerger wraps a vcs.CrossRepoMerger, adding tracing to it.
 type crossRepoMerger struct {
 	m   vcs.CrossRepoMerger
@@ -271,6 +400,23 @@ func (m crossRepoMerger) CrossRepoMergeBase(a vcs.CommitID, repoB vcs.Repository
 	return commit, err
 }
 
+// CrossRepoMergeBaseContext implements the vcs.CrossRepoMergerContext interface.
+func (m crossRepoMerger) CrossRepoMergeBaseContext(ctx context.Context, a vcs.CommitID, repoB vcs.Repository, b vcs.CommitID) (vcs.CommitID, error) {
+	c, ok := m.m.(vcs.CrossRepoMergerContext)
+	if !ok {
+		return m.CrossRepoMergeBase(a, repoB, b)
+	}
+	start := time.Now()
+	commit, err := c.CrossRepoMergeBaseContext(ctx, a, repoB, b)
+	m.rec.Child().Event(GoVCS{
+		Name:      "vcs.CrossRepoMergerContext.CrossRepoMergeBaseContext",
+		Args:      fmt.Sprintf("%#v, %#v, %#v", a, repoB, b),
+		StartTime: start,
+		EndTime:   time.Now(),
+	})
+	return commit, err
+}
+
 // remoteUpdater wraps a vcs.RemoteUpdater, adding tracing to it.
 type remoteUpdater struct {
 	r   vcs.RemoteUpdater
@@ -290,6 +436,23 @@ func (r remoteUpdater) UpdateEverything(opts vcs.RemoteOpts) (*vcs.UpdateResult,
 	return result, err
 }
 
+// UpdateEverythingContext implements the vcs.RemoteUpdaterContext interface.
+func (r remoteUpdater) UpdateEverythingContext(ctx context.Context, opts vcs.RemoteOpts) (*vcs.UpdateResult, error) {
+	c, ok := r.r.(vcs.RemoteUpdaterContext)
+	if !ok {
+		return r.UpdateEverything(opts)
+	}
+	start := time.Now()
+	result, err := c.UpdateEverythingContext(ctx, opts)
+	r.rec.Child().Event(GoVCS{
+		Name:      "vcs.RemoteUpdaterContext.UpdateEverythingContext",
+		Args:      fmt.Sprintf("%#v", opts),
+		StartTime: start,
+		EndTime:   time.Now(),
+	})
+	return result, err
+}
+
 // searcher wraps a vcs.Searcher, adding tracing to it.
 type searcher struct {
 	s   vcs.Searcher
@@ -308,3 +471,20 @@ func (s searcher) Search(commit vcs.CommitID, opts vcs.SearchOptions) ([]*vcs.Se
 	})
 	return results, err
 }
+
+// SearchContext implements the vcs.SearcherContext interface.
+func (s searcher) SearchContext(ctx context.Context, commit vcs.CommitID, opts vcs.SearchOptions) ([]*vcs.SearchResult, error) {
+	c, ok := s.s.(vcs.SearcherContext)
+	if !ok {
+		return s.Search(commit, opts)
+	}
+	start := time.Now()
+	results, err := c.SearchContext(ctx, commit, opts)
+	s.rec.Child().Event(GoVCS{
+		Name:      "vcs.SearcherContext.SearchContext",
+		Args:      fmt.Sprintf("%#v, %#v", commit, opts),
+		StartTime: start,
+		EndTime:   time.Now(),
+	})
+	return results, err
+}